@@ -2,25 +2,47 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"net/http"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/vlkhvnn/TestON/internal/discord"
+	"github.com/vlkhvnn/TestON/internal/outbound"
+	"github.com/vlkhvnn/TestON/internal/score"
+	"github.com/vlkhvnn/TestON/internal/shard"
 	"github.com/vlkhvnn/TestON/internal/store"
+	"github.com/vlkhvnn/TestON/internal/watch"
 	"github.com/vlkhvnn/TestON/internal/wikimedia"
 	"go.uber.org/zap"
 )
 
+// drainTimeout bounds how long shutdown waits for queued messages.
+const drainTimeout = 10 * time.Second
+
 type application struct {
 	config config
 	store  store.Storage
 	logger *zap.SugaredLogger
-	bot    discord.Bot
+	bot    *discord.Bot
 }
 
 type config struct {
 	token string
 	db    dbConfig
+	// metricsAddr is where expvar metrics are served; empty disables them.
+	metricsAddr string
+	// shards selects the gateway shards this process runs.
+	shards shard.Config
+	// scoreWeights weigh the signals of the suspicion score of edits.
+	scoreWeights score.Weights
+	// statsRetentionDays is how many days of detailed stats are kept; zero
+	// keeps them forever.
+	statsRetentionDays int
+	// operatorIDs are the Discord users who run the bot.
+	operatorIDs []string
 }
 
 type dbConfig struct {
@@ -34,19 +56,51 @@ func (app *application) run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Every channel message goes through the dispatcher, which queues it
+	// per channel and retries transient failures.
+	outbox := outbound.New(app.bot.Sender(), app.logger)
+	app.bot.SetDispatcher(outbox)
+
+	// The bot loads watches, alerts and feeds, including the feed backfill,
+	// before the stream starts delivering events.
+	if err := app.bot.Start(); err != nil {
+		return err
+	}
+
+	defer app.bot.Stop()
+
+	// Every process ingests the whole stream. The first to store an event
+	// counts it in the stats, and each process notifies only the guilds
+	// its shards serve.
+	notifier := watch.NewNotifier(outbox, app.bot.Watches(), app.logger)
+	notifier.SetLocales(app.bot.ChannelLocalizer)
+	go notifier.Run(ctx)
+
+	feeds := app.bot.Feeds()
+	go feeds.Run(ctx, outbox, app.logger)
+
+	alerts := app.bot.Alerts()
+	go alerts.Run(ctx, outbox, app.logger)
+
+	// Background jobs, such as posting digests, run on their schedules and
+	// carry on from their last runs after a restart.
+	if err := app.addJobs(outbox); err != nil {
+		return err
+	}
+	go app.bot.Jobs().Run(ctx, app.logger)
+
+	scorer := score.NewHeuristic(app.config.scoreWeights)
 	go func() {
-		if err := wikimedia.StartStream(ctx, &app.store, app.logger); err != nil {
+		if err := wikimedia.StartStream(ctx, &app.store, scorer, app.logger, notifier.Handle, feeds.Handle, alerts.Handle); err != nil {
 			app.logger.Errorw("Failed to start Wikimedia stream", "error", err)
 			cancel()
 		}
 	}()
 
-	if err := app.bot.Start(); err != nil {
-		return err
+	if app.config.metricsAddr != "" {
+		go app.serveMetrics(ctx)
 	}
 
-	defer app.bot.Stop()
-
 	app.logger.Info("Application started. Press CTRL-C to exit.")
 
 	sigCh := make(chan os.Signal, 1)
@@ -57,5 +111,34 @@ func (app *application) run() error {
 	}
 
 	app.logger.Info("Shutting down...")
+	// Stop producing messages, then give the queued ones a moment to go
+	// out before the session closes.
+	cancel()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	if err := outbox.Close(drainCtx); err != nil {
+		app.logger.Warnw("Outbound messages were dropped", "error", err)
+	}
 	return nil
 }
+
+// serveMetrics exposes the expvar counters, such as throttled_commands, and
+// the status of every shard at /debug/vars until ctx is cancelled.
+func (app *application) serveMetrics(ctx context.Context) {
+	shards := app.bot.Shards()
+	expvar.Publish("shards", expvar.Func(func() any { return shards.Status() }))
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	srv := &http.Server{Addr: app.config.metricsAddr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	app.logger.Infow("Serving metrics", "addr", app.config.metricsAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Errorw("Metrics server failed", "error", err)
+	}
+}
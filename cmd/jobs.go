@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/outbound"
+	"github.com/vlkhvnn/TestON/internal/scheduler"
+)
+
+const (
+	// pruneBatch is how many rows of a stats table one delete removes.
+	pruneBatch = 5000
+	// pruneSchedule runs the pruning at night, UTC.
+	pruneSchedule = "30 3 * * *"
+)
+
+// addJobs registers the background jobs with the bot's scheduler.
+func (app *application) addJobs(outbox *outbound.Dispatcher) error {
+	jobs := app.bot.Jobs()
+
+	digests := app.bot.Digests()
+	err := jobs.Add(scheduler.Job{
+		Name:     "digests",
+		Schedule: scheduler.Every(time.Minute),
+		Timeout:  time.Minute,
+		Run: func(ctx context.Context) error {
+			return digests.RunDue(ctx, outbox, app.logger)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Digests are claimed before they are posted, so every process may run
+	// that job; pruning is left to the process running shard 0.
+	if app.config.statsRetentionDays > 0 && app.bot.Shards().Runs(0) {
+		schedule, err := scheduler.Parse(pruneSchedule)
+		if err != nil {
+			return err
+		}
+		err = jobs.Add(scheduler.Job{
+			Name:     "prune_stats",
+			Schedule: schedule,
+			Jitter:   10 * time.Minute,
+			Timeout:  30 * time.Minute,
+			Run:      app.pruneStats,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneStats deletes the per-article, per-editor, new page and hourly stats
+// older than the retention, a batch at a time.
+func (app *application) pruneStats(ctx context.Context) error {
+	before := time.Now().UTC().AddDate(0, 0, -app.config.statsRetentionDays)
+	var total int64
+	for {
+		n, err := app.store.Stat.Prune(ctx, before, pruneBatch)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+	}
+	app.logger.Infow("Pruned old stats", "rows", total, "before", before.Format("2006-01-02"))
+	return nil
+}
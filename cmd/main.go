@@ -1,10 +1,14 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/joho/godotenv"
 	"github.com/vlkhvnn/TestON/internal/db"
 	"github.com/vlkhvnn/TestON/internal/discord"
 	"github.com/vlkhvnn/TestON/internal/env"
+	"github.com/vlkhvnn/TestON/internal/score"
+	"github.com/vlkhvnn/TestON/internal/shard"
 	"github.com/vlkhvnn/TestON/internal/store"
 	"go.uber.org/zap"
 )
@@ -25,6 +29,21 @@ func main() {
 			maxIdleConns: env.GetInt("DB_MAX_IDLE_CONNS", 30),
 			maxIdleTime:  env.GetString("DB_MAX_IDLE_TIME", "15m"),
 		},
+		metricsAddr:        env.GetString("METRICS_ADDR", ""),
+		statsRetentionDays: env.GetInt("STATS_RETENTION_DAYS", 365),
+		operatorIDs:        strings.FieldsFunc(env.GetString("OPERATOR_IDS", ""), func(r rune) bool { return r == ',' || r == ' ' }),
+		shards: shard.Config{
+			Count: env.GetInt("SHARD_COUNT", 0),
+		},
+	}
+	shardIDs, err := shard.ParseIDs(env.GetString("SHARD_IDS", ""))
+	if err != nil {
+		logger.Fatalf("Invalid SHARD_IDS: %v", err)
+	}
+	cfg.shards.IDs = shardIDs
+	cfg.scoreWeights, err = score.ParseWeights(env.GetString("SCORE_WEIGHTS", ""))
+	if err != nil {
+		logger.Fatalf("Invalid SCORE_WEIGHTS: %v", err)
 	}
 
 	db, err := db.New(
@@ -43,16 +62,17 @@ func main() {
 
 	store := store.NewStorage(db)
 
-	bot, err := discord.NewBot(cfg.token, store)
+	bot, err := discord.NewBot(cfg.token, store, cfg.shards)
 	if err != nil {
 		logger.Fatalf("Error starting discord bot: %v", err)
 	}
+	bot.SetOperators(cfg.operatorIDs)
 
 	app := application{
 		config: cfg,
 		store:  store,
 		logger: logger,
-		bot:    *bot,
+		bot:    bot,
 	}
 
 	logger.Fatal(app.run())
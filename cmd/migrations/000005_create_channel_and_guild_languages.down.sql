@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS guild_languages;
+DROP TABLE IF EXISTS channel_languages;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS channel_languages (
+    channel_id TEXT PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    lang TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS guild_languages (
+    guild_id TEXT PRIMARY KEY,
+    lang TEXT NOT NULL
+);
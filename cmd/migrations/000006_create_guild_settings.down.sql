@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS guild_languages (
+    guild_id TEXT PRIMARY KEY,
+    lang TEXT NOT NULL
+);
+
+INSERT INTO guild_languages (guild_id, lang)
+SELECT guild_id, lang FROM guild_settings WHERE lang <> ''
+ON CONFLICT (guild_id) DO NOTHING;
+
+DROP TABLE IF EXISTS guild_settings;
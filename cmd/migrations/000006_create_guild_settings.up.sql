@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS guild_settings (
+    guild_id TEXT PRIMARY KEY,
+    lang TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    feed_channels TEXT[] NOT NULL DEFAULT '{}',
+    disabled_commands TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO guild_settings (guild_id, lang)
+SELECT guild_id, lang FROM guild_languages
+ON CONFLICT (guild_id) DO NOTHING;
+
+DROP TABLE IF EXISTS guild_languages;
@@ -0,0 +1,2 @@
+ALTER TABLE guild_settings
+ADD COLUMN IF NOT EXISTS aliases JSONB NOT NULL DEFAULT '{}';
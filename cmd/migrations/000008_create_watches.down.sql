@@ -0,0 +1 @@
+DROP TABLE IF EXISTS watches;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS events_lang_timestamp_idx;
+DROP TABLE IF EXISTS feeds;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS feeds (
+    id SERIAL PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    channel_id TEXT NOT NULL UNIQUE,
+    created_by TEXT NOT NULL,
+    lang TEXT NOT NULL,
+    filters TEXT NOT NULL DEFAULT '',
+    cursor_timestamp BIGINT NOT NULL DEFAULT 0,
+    cursor_event_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS events_lang_timestamp_idx ON events (lang, timestamp);
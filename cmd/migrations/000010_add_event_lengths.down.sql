@@ -0,0 +1,3 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS length_old,
+    DROP COLUMN IF EXISTS length_new;
\ No newline at end of file
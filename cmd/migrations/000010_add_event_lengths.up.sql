@@ -0,0 +1,3 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS length_old BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS length_new BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS article_stats;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS article_stats (
+    id SERIAL PRIMARY KEY,
+    lang TEXT NOT NULL,
+    date DATE NOT NULL,
+    title TEXT NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    UNIQUE(lang, date, title)
+);
+
+CREATE INDEX IF NOT EXISTS article_stats_lang_date_idx ON article_stats (lang, date);
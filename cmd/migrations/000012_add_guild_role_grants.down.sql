@@ -0,0 +1,2 @@
+ALTER TABLE guild_settings
+DROP COLUMN IF EXISTS role_grants;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE guild_settings
+ADD COLUMN IF NOT EXISTS role_grants JSONB NOT NULL DEFAULT '{}';
\ No newline at end of file
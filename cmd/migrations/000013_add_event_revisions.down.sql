@@ -0,0 +1,6 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS event_type,
+    DROP COLUMN IF EXISTS namespace,
+    DROP COLUMN IF EXISTS rev_old,
+    DROP COLUMN IF EXISTS rev_new,
+    DROP COLUMN IF EXISTS log_id;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS namespace INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rev_old BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rev_new BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS log_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE guild_settings
+DROP COLUMN IF EXISTS local_stats_days;
+
+DROP TABLE IF EXISTS stats_hourly;
\ No newline at end of file
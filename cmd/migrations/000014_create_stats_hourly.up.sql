@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS stats_hourly (
+    lang TEXT NOT NULL,
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (lang, hour)
+);
+
+ALTER TABLE guild_settings
+ADD COLUMN IF NOT EXISTS local_stats_days BOOLEAN NOT NULL DEFAULT FALSE;
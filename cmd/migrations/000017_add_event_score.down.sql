@@ -0,0 +1,2 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS score;
\ No newline at end of file
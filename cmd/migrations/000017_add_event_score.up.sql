@@ -0,0 +1,2 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS new_pages;
+DROP TABLE IF EXISTS editor_stats;
\ No newline at end of file
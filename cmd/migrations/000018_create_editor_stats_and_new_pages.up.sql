@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS editor_stats (
+    id SERIAL PRIMARY KEY,
+    lang TEXT NOT NULL,
+    date DATE NOT NULL,
+    username TEXT NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    UNIQUE(lang, date, username)
+);
+
+CREATE INDEX IF NOT EXISTS editor_stats_lang_date_idx ON editor_stats (lang, date);
+
+CREATE TABLE IF NOT EXISTS new_pages (
+    id SERIAL PRIMARY KEY,
+    lang TEXT NOT NULL,
+    date DATE NOT NULL,
+    title TEXT NOT NULL,
+    username TEXT NOT NULL,
+    UNIQUE(lang, date, title)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS digests;
\ No newline at end of file
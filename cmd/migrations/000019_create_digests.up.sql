@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS digests (
+    id SERIAL PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    channel_id TEXT NOT NULL UNIQUE,
+    created_by TEXT NOT NULL,
+    period TEXT NOT NULL,
+    langs TEXT[] NOT NULL,
+    hour INT NOT NULL,
+    minute INT NOT NULL,
+    next_run TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS digests_next_run_idx ON digests (next_run);
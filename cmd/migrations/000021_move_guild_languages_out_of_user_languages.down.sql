@@ -0,0 +1,3 @@
+-- The moved rows cannot be told apart from languages set with
+-- !setServerLang, so they stay in guild_settings.
+SELECT 1;
\ No newline at end of file
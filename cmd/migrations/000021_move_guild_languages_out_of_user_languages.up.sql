@@ -0,0 +1,26 @@
+-- Before channel and guild defaults existed, !setLang in a server saved the
+-- language under the server's ID as if it were a user's. Move those rows to
+-- the guild settings, unless the guild has set a language since, and drop
+-- them from user_languages. Discord IDs are unique across users and guilds,
+-- so a user_languages row keyed by a known guild ID is one of them. Guilds
+-- the tables below do not know are moved by the bot when it joins them.
+CREATE TEMPORARY TABLE known_guilds AS
+SELECT guild_id FROM guild_settings
+UNION SELECT guild_id FROM channel_languages
+UNION SELECT guild_id FROM watches
+UNION SELECT guild_id FROM feeds
+UNION SELECT guild_id FROM alerts
+UNION SELECT guild_id FROM digests;
+
+INSERT INTO guild_settings (guild_id, lang)
+SELECT u.user_id, u.lang FROM user_languages u
+JOIN known_guilds g ON g.guild_id = u.user_id
+WHERE u.user_id <> ''
+ON CONFLICT (guild_id) DO UPDATE SET lang = EXCLUDED.lang
+WHERE guild_settings.lang = '';
+
+DELETE FROM user_languages u
+USING known_guilds g
+WHERE g.guild_id = u.user_id AND u.user_id <> '';
+
+DROP TABLE known_guilds;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS events_wiki_event_id_idx;
\ No newline at end of file
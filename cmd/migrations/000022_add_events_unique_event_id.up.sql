@@ -0,0 +1,6 @@
+-- Several processes ingest the same stream; the first to store an event
+-- updates the stats, the others find it stored already.
+DELETE FROM events a USING events b
+WHERE a.wiki = b.wiki AND a.event_id = b.event_id AND a.id > b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_wiki_event_id_idx ON events (wiki, event_id);
@@ -0,0 +1,50 @@
+// Command sitematrix rebuilds the embedded site matrix snapshot from a saved
+// Wikimedia API response, so refreshing it does not need network access:
+//
+//	curl -o sitematrix-api.json 'https://meta.wikimedia.org/w/api.php?action=sitematrix&format=json'
+//	go run ./cmd/sitematrix -in sitematrix-api.json
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+)
+
+func main() {
+	in := flag.String("in", "", "saved action=sitematrix API response")
+	out := flag.String("out", "internal/sitematrix/sitematrix.json", "snapshot to write")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("open input: %v", err)
+	}
+	defer f.Close()
+
+	m, err := sitematrix.ParseAPI(f)
+	if err != nil {
+		log.Fatalf("parse input: %v", err)
+	}
+
+	w, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("create output: %v", err)
+	}
+	if err := m.Write(w); err != nil {
+		w.Close()
+		log.Fatalf("write snapshot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("write snapshot: %v", err)
+	}
+
+	log.Printf("wrote %d languages to %s", len(m.Languages), *out)
+}
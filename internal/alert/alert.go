@@ -0,0 +1,270 @@
+// Package alert watches the ingested events for unusual activity and posts
+// alerts to the channels that subscribed to them.
+package alert
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/watch"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWindow is how far back the current rate of a spike is measured.
+	DefaultWindow = 5 * time.Minute
+	// DefaultHalfLife is how quickly the baseline forgets old activity.
+	DefaultHalfLife = time.Hour
+	// DefaultWarmup is how much history a baseline needs before spikes
+	// against it are reported.
+	DefaultWarmup = 30 * time.Minute
+	// DefaultCooldown is the least time between two alerts of one
+	// subscription.
+	DefaultCooldown = 30 * time.Minute
+
+	queueSize = 1000
+)
+
+// Sender queues messages for delivery, such as *outbound.Dispatcher.
+type Sender interface {
+	Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error
+}
+
+// LocaleFunc returns the localizer for messages posted to a channel of a
+// guild.
+type LocaleFunc func(ctx context.Context, guildID, channelID string) i18n.Localizer
+
+// message is an alert waiting to be sent. It is formatted when it is sent,
+// as looking up the channel's language may hit the database.
+type message struct {
+	guildID   string
+	channelID string
+	format    func(loc i18n.Localizer) string
+}
+
+type key struct {
+	lang string
+	// target is a normalized page title, or empty for the whole wiki.
+	target string
+}
+
+type subscription struct {
+	alert *models.Alert
+	// firing is set from an alert until activity falls back below the
+	// threshold, so one spike is reported once however long it lasts.
+	firing bool
+	last   time.Time
+}
+
+// series tracks the activity on one wiki or page that alerts subscribe to.
+type series struct {
+	rate *Rate
+	subs []*subscription
+}
+
+// Manager holds the alert subscriptions and the activity they watch. Spikes
+// are only measured on wikis and pages with subscriptions, so that state is
+// bounded by the number of subscriptions. Edit wars are followed on every
+// page so that they can be listed, within a bounded number of pages and
+// edits per page. Handle only measures and enqueues, so ingestion is never
+// blocked by Discord; Run does the sending.
+type Manager struct {
+	window    time.Duration
+	halfLife  time.Duration
+	warmup    time.Duration
+	cooldown  time.Duration
+	warWindow time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	spikes map[key]*series
+	wars   map[key][]*warSubscription
+	pages  map[key]*page
+
+	queue  chan message
+	locale LocaleFunc
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		window:    DefaultWindow,
+		halfLife:  DefaultHalfLife,
+		warmup:    DefaultWarmup,
+		cooldown:  DefaultCooldown,
+		warWindow: DefaultWarWindow,
+		now:       time.Now,
+		spikes:    make(map[key]*series),
+		wars:      make(map[key][]*warSubscription),
+		pages:     make(map[key]*page),
+		queue:     make(chan message, queueSize),
+		locale:    func(context.Context, string, string) i18n.Localizer { return i18n.Localizer{} },
+	}
+}
+
+// SetLocales makes alerts use the language fn returns for their channel
+// instead of English. It must be called before Run.
+func (m *Manager) SetLocales(fn LocaleFunc) {
+	m.locale = fn
+}
+
+// Load replaces the manager's subscriptions with alerts.
+func (m *Manager) Load(alerts []*models.Alert) {
+	m.mu.Lock()
+	m.spikes = make(map[key]*series)
+	m.wars = make(map[key][]*warSubscription)
+	m.mu.Unlock()
+	for _, a := range alerts {
+		m.Add(a)
+	}
+}
+
+// Add subscribes the alert's channel, or updates an existing subscription
+// with the same ID.
+func (m *Manager) Add(a *models.Alert) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch a.Kind {
+	case models.AlertKindSpike:
+		m.addSpike(a)
+	case models.AlertKindEditWar:
+		m.addWar(a)
+	}
+}
+
+func (m *Manager) addSpike(a *models.Alert) {
+	k := keyFor(a)
+	s, ok := m.spikes[k]
+	if !ok {
+		s = &series{rate: NewRate(m.window, m.halfLife, m.now())}
+		m.spikes[k] = s
+	}
+	for _, sub := range s.subs {
+		if sub.alert.ID == a.ID {
+			sub.alert = a
+			return
+		}
+	}
+	s.subs = append(s.subs, &subscription{alert: a})
+}
+
+func (m *Manager) addWar(a *models.Alert) {
+	k := keyFor(a)
+	for _, sub := range m.wars[k] {
+		if sub.alert.ID == a.ID {
+			sub.alert = a
+			return
+		}
+	}
+	m.wars[k] = append(m.wars[k], &warSubscription{alert: a, reported: make(map[string]int64)})
+}
+
+// Remove drops the channel's subscription with the given ID.
+func (m *Manager) Remove(channelID string, id int64) {
+	m.remove(func(a *models.Alert) bool { return a.ChannelID == channelID && a.ID == id })
+}
+
+// RemoveChannel drops every subscription of the channel.
+func (m *Manager) RemoveChannel(channelID string) {
+	m.remove(func(a *models.Alert) bool { return a.ChannelID == channelID })
+}
+
+func (m *Manager) remove(match func(*models.Alert) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, s := range m.spikes {
+		kept := s.subs[:0]
+		for _, sub := range s.subs {
+			if !match(sub.alert) {
+				kept = append(kept, sub)
+			}
+		}
+		s.subs = kept
+		if len(s.subs) == 0 {
+			// Nobody is interested in the activity any more.
+			delete(m.spikes, k)
+		}
+	}
+	for k, subs := range m.wars {
+		kept := subs[:0]
+		for _, sub := range subs {
+			if !match(sub.alert) {
+				kept = append(kept, sub)
+			}
+		}
+		if len(kept) == 0 {
+			delete(m.wars, k)
+		} else {
+			m.wars[k] = kept
+		}
+	}
+}
+
+// Len returns the number of subscriptions.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, s := range m.spikes {
+		n += len(s.subs)
+	}
+	for _, subs := range m.wars {
+		n += len(subs)
+	}
+	return n
+}
+
+// Handle is called for every ingested event.
+func (m *Manager) Handle(lang string, event *models.RecentChangeEvent) {
+	t := time.Unix(event.Timestamp, 0)
+
+	m.mu.Lock()
+	var out []message
+	if s, ok := m.spikes[key{lang: lang}]; ok {
+		out = append(out, m.observe(s, lang, event, t)...)
+	}
+	if event.Type == "edit" || event.Type == "new" {
+		if s, ok := m.spikes[key{lang: lang, target: watch.NormalizeTitle(event.Title)}]; ok {
+			out = append(out, m.observe(s, lang, event, t)...)
+		}
+		if war := m.trackWar(lang, event); war != nil {
+			out = append(out, m.reportWar(war, event)...)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, msg := range out {
+		select {
+		case m.queue <- msg:
+		default:
+			// Alerts are rare; a full queue means Discord is far behind and
+			// a late alert would not help.
+		}
+	}
+}
+
+// Run sends queued alerts until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context, sender Sender, logger *zap.SugaredLogger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-m.queue:
+			content := msg.format(m.locale(ctx, msg.guildID, msg.channelID))
+			if err := sender.Enqueue(msg.channelID, &discordgo.MessageSend{Content: content}); err != nil {
+				logger.Errorw("Failed to queue alert", "channel", msg.channelID, "error", err)
+			}
+		}
+	}
+}
+
+func keyFor(a *models.Alert) key {
+	if a.Target == "" {
+		return key{lang: a.Lang}
+	}
+	return key{lang: a.Lang, target: watch.NormalizeTitle(a.Target)}
+}
@@ -0,0 +1,317 @@
+package alert
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"go.uber.org/zap"
+)
+
+var start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestManager() *Manager {
+	m := NewManager()
+	m.now = func() time.Time { return start }
+	return m
+}
+
+// edits feeds n edits of title on lang, spread over the minute after t.
+func edits(m *Manager, lang, title string, t time.Time, n int) {
+	for i := 0; i < n; i++ {
+		m.Handle(lang, &models.RecentChangeEvent{
+			Type:       "edit",
+			Title:      title,
+			ServerName: lang + ".wikipedia.org",
+			Timestamp:  t.Add(time.Duration(i) * time.Minute / time.Duration(n)).Unix(),
+		})
+	}
+}
+
+// steady feeds perMinute edits a minute from minute from to minute to.
+func steady(m *Manager, lang, title string, from, to, perMinute int) {
+	for i := from; i < to; i++ {
+		edits(m, lang, title, start.Add(time.Duration(i)*time.Minute), perMinute)
+	}
+}
+
+func drain(m *Manager) []message {
+	var out []message
+	for {
+		select {
+		case msg := <-m.queue:
+			out = append(out, msg)
+		default:
+			return out
+		}
+	}
+}
+
+// text formats an alert in English.
+func text(msg message) string {
+	return msg.format(i18n.Localizer{})
+}
+
+func TestRateBaselineExcludesWindow(t *testing.T) {
+	r := NewRate(5*time.Minute, time.Hour, start)
+	for i := 0; i < 60; i++ {
+		r.Add(start.Add(time.Duration(i) * time.Minute))
+		r.Add(start.Add(time.Duration(i)*time.Minute + 30*time.Second))
+	}
+	assert.InDelta(t, 2, r.Baseline(), 0.001)
+	assert.InDelta(t, 2, r.PerMinute(), 0.001)
+	assert.Equal(t, 55*time.Minute, r.Observed())
+
+	for i := 0; i < 40; i++ {
+		r.Add(start.Add(60 * time.Minute))
+	}
+	assert.Equal(t, 48, r.Count())
+	assert.InDelta(t, 9.6, r.PerMinute(), 0.001)
+	assert.InDelta(t, 2, r.Baseline(), 0.001, "the spike is not in its own baseline")
+
+	// An event older than the window is ignored.
+	r.Add(start)
+	assert.Equal(t, 48, r.Count())
+}
+
+func TestRateDecaysWhileIdle(t *testing.T) {
+	r := NewRate(5*time.Minute, time.Hour, start)
+	for i := 0; i < 120; i++ {
+		r.Add(start.Add(time.Duration(i) * time.Minute))
+	}
+	assert.InDelta(t, 1, r.Baseline(), 0.001)
+
+	// Two hours later the baseline has lost three quarters of its weight.
+	r.Add(start.Add(119*time.Minute + 2*time.Hour))
+	assert.InDelta(t, 0.25, r.Baseline(), 0.02)
+	assert.Equal(t, 1, r.Count())
+}
+
+func TestRateStartsAsAverage(t *testing.T) {
+	r := NewRate(time.Minute, time.Hour, start)
+	for i := 0; i < 10; i++ {
+		r.Add(start.Add(time.Duration(i) * time.Minute))
+	}
+	r.Add(start.Add(10 * time.Minute))
+	// The baseline is not dragged towards zero at first.
+	assert.InDelta(t, 1, r.Baseline(), 0.001)
+}
+
+func TestSpikeAlertsOncePerSpike(t *testing.T) {
+	m := newTestManager()
+	m.Add(&models.Alert{ID: 1, ChannelID: "c1", Kind: models.AlertKindSpike, Lang: "en", Threshold: 3})
+
+	steady(m, "en", "Some Page", 0, 60, 2)
+	assert.Empty(t, drain(m))
+
+	steady(m, "en", "Breaking News", 60, 63, 30)
+	alerts := drain(m)
+	require.Len(t, alerts, 1, "a spike lasting several minutes is reported once")
+	assert.Equal(t, "c1", alerts[0].channelID)
+	assert.Contains(t, text(alerts[0]), "Edit spike on **en**: 30 changes in the last 5 minutes, 3.0× the usual rate.")
+	assert.Equal(t, "📈 Всплеск правок в **en**: 30 правок за последние 5 мин., в 3.0 раза чаще обычного.",
+		alerts[0].format(i18n.Default().Localizer("ru")))
+
+	// Activity calms down, which re-arms the alert, but the next spike
+	// comes before the cooldown is over.
+	steady(m, "en", "Some Page", 63, 75, 2)
+	steady(m, "en", "Breaking News", 75, 76, 40)
+	assert.Empty(t, drain(m))
+
+	steady(m, "en", "Some Page", 76, 95, 2)
+	steady(m, "en", "Breaking News", 95, 96, 60)
+	assert.Len(t, drain(m), 1)
+}
+
+func TestSpikeNeedsWarmup(t *testing.T) {
+	m := newTestManager()
+	m.Add(&models.Alert{ID: 1, ChannelID: "c1", Kind: models.AlertKindSpike, Lang: "en", Threshold: 2})
+
+	steady(m, "en", "Some Page", 0, 10, 1)
+	steady(m, "en", "Some Page", 10, 11, 50)
+	assert.Empty(t, drain(m), "no baseline yet")
+}
+
+func TestPageSpike(t *testing.T) {
+	m := newTestManager()
+	m.Load([]*models.Alert{
+		{ID: 1, ChannelID: "c1", Kind: models.AlertKindSpike, Lang: "en", Target: "breaking_News", Threshold: 4},
+		{ID: 2, ChannelID: "c2", Kind: models.AlertKindSpike, Lang: "de", Target: "Breaking News", Threshold: 4},
+	})
+
+	// The page is quiet: a few edits an hour, elsewhere on the wiki.
+	steady(m, "en", "Other", 0, 40, 1)
+	edits(m, "en", "Breaking News", start.Add(35*time.Minute), 1)
+	edits(m, "en", "Breaking News", start.Add(40*time.Minute), 4)
+	assert.Empty(t, drain(m), "too few edits to be a spike")
+
+	edits(m, "en", "Breaking News", start.Add(41*time.Minute), 3)
+	alerts := drain(m)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "c1", alerts[0].channelID)
+	assert.Contains(t, text(alerts[0]), "[Breaking News](<https://en.wikipedia.org/wiki/Breaking_News>) (en)")
+}
+
+func TestRemoveDropsUnwatchedSeries(t *testing.T) {
+	m := newTestManager()
+	m.Add(&models.Alert{ID: 1, ChannelID: "c1", Kind: models.AlertKindSpike, Lang: "en", Threshold: 3})
+	m.Add(&models.Alert{ID: 2, ChannelID: "c2", Kind: models.AlertKindSpike, Lang: "en", Threshold: 3})
+	m.Add(&models.Alert{ID: 3, ChannelID: "c2", Kind: models.AlertKindSpike, Lang: "de", Threshold: 3})
+	// Saving an alert again updates its threshold.
+	m.Add(&models.Alert{ID: 1, ChannelID: "c1", Kind: models.AlertKindSpike, Lang: "en", Threshold: 5})
+	require.Len(t, m.spikes, 2)
+	assert.Len(t, m.spikes[key{lang: "en"}].subs, 2)
+	assert.Equal(t, 5.0, m.spikes[key{lang: "en"}].subs[0].alert.Threshold)
+
+	m.Add(&models.Alert{ID: 4, ChannelID: "c2", Kind: models.AlertKindEditWar, Lang: "en", Threshold: 3})
+	assert.Equal(t, 4, m.Len())
+
+	m.RemoveChannel("c2")
+	assert.Len(t, m.spikes, 1)
+	assert.Empty(t, m.wars)
+	m.Remove("c1", 1)
+	assert.Empty(t, m.spikes)
+}
+
+// change feeds one edit of title by user, at seconds after start.
+func change(m *Manager, title, user, comment string, seconds int, before, after int64) {
+	revise(m, title, user, comment, seconds, 0, 0, before, after)
+}
+
+// revise is change for an edit with revision IDs.
+func revise(m *Manager, title, user, comment string, seconds int, parent, rev, before, after int64) {
+	m.Handle("en", &models.RecentChangeEvent{
+		Type:       "edit",
+		Title:      title,
+		User:       user,
+		Comment:    comment,
+		ServerName: "en.wikipedia.org",
+		Timestamp:  start.Add(time.Duration(seconds) * time.Second).Unix(),
+		Revision:   models.Revision{Old: parent, New: rev},
+		Length:     models.EventLength{Old: before, New: after},
+	})
+}
+
+func TestEditWarAlertsOncePerWar(t *testing.T) {
+	m := newTestManager()
+	m.Load([]*models.Alert{
+		{ID: 1, ChannelID: "c1", Kind: models.AlertKindEditWar, Lang: "en", Threshold: 3},
+		{ID: 2, ChannelID: "c2", Kind: models.AlertKindEditWar, Lang: "en", Threshold: 5},
+		{ID: 3, ChannelID: "c3", Kind: models.AlertKindEditWar, Lang: "en", Target: "contested_page", Threshold: 3},
+	})
+
+	change(m, "Contested page", "Alice", "add a section", 0, 1000, 1200)
+	change(m, "Contested page", "Bob", "Undid revision 5 by Alice", 60, 1200, 1000)
+	// Reverts without a summary saying so are recognised by the page going
+	// back to its earlier size.
+	change(m, "Contested page", "Alice", "", 120, 1000, 1200)
+	assert.Empty(t, drain(m))
+
+	change(m, "Contested page", "Bob", "rv", 180, 1200, 1000)
+	alerts := drain(m)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "c1", alerts[0].channelID)
+	assert.Equal(t, "c3", alerts[1].channelID)
+	assert.Equal(t, "⚔️ Edit war on [Contested page](<https://en.wikipedia.org/wiki/Contested_page>) (en): "+
+		"3 reverts by 2 users in the last 60 minutes.", text(alerts[0]))
+	assert.Equal(t, "⚔️ Война правок в [Contested page](<https://en.wikipedia.org/wiki/Contested_page>) (en): "+
+		"3 отката за последние 60 мин., участников: 2.", alerts[0].format(i18n.Default().Localizer("ru")))
+
+	change(m, "Contested page", "Alice", "", 240, 1000, 1200)
+	change(m, "Contested page", "Bob", "Reverted edits by Alice", 300, 1200, 1000)
+	alerts = drain(m)
+	require.Len(t, alerts, 1, "the war is reported once to each channel")
+	assert.Equal(t, "c2", alerts[0].channelID)
+	assert.Contains(t, text(alerts[0]), "5 reverts by 2 users")
+}
+
+func TestEditWarRevertsFollowRevisions(t *testing.T) {
+	m := newTestManager()
+
+	revise(m, "Page", "Alice", "add a section", 0, 9, 10, 1000, 1200)
+	revise(m, "Page", "Carol", "copyedit", 60, 10, 11, 1200, 1250)
+	// The undo names the reverted revision, though the size does not match.
+	revise(m, "Page", "Bob", "Undid revision 10 by Alice", 120, 11, 12, 1250, 1050)
+	// Going back to the size before Bob's edit reverts Bob.
+	revise(m, "Page", "Alice", "", 180, 12, 13, 1050, 1250)
+	// Undoing one's own edit is not a revert, whatever the summary says.
+	revise(m, "Page", "Dave", "expand", 240, 13, 14, 1250, 1500)
+	revise(m, "Page", "Dave", "Undid revision 14 by Dave", 300, 14, 15, 1500, 1250)
+
+	w := m.pages[key{lang: "en", target: "Page"}].war("en", 0)
+	assert.Equal(t, 2, w.Reverts)
+	assert.Equal(t, 2, w.Users)
+}
+
+func TestEditWarsListsActivePages(t *testing.T) {
+	m := newTestManager()
+
+	for i := 0; i < 3; i++ {
+		change(m, "War", "Alice", "", i*120, 1000, 1200)
+		change(m, "War", "Bob", "revert", i*120+60, 1200, 1000)
+	}
+	// One user reverting several times is not a war.
+	for i := 0; i < 4; i++ {
+		change(m, "Cleanup", "Carol", "rvv", i*60, 800, 700)
+	}
+	// Ordinary edits by several users are not reverts.
+	for i, user := range []string{"Alice", "Bob", "Carol", "Dave"} {
+		change(m, "Busy", user, "copyedit", i*60, int64(1000+i), int64(1001+i))
+	}
+
+	m.now = func() time.Time { return start.Add(10 * time.Minute) }
+	wars := m.EditWars("en")
+	require.Len(t, wars, 1)
+	assert.Equal(t, War{Lang: "en", Title: "War", Reverts: 5, Users: 2, Last: start.Add(300 * time.Second)}, wars[0])
+	assert.Empty(t, m.EditWars("de"))
+
+	m.now = func() time.Time { return start.Add(2 * time.Hour) }
+	assert.Empty(t, m.EditWars("en"), "old reverts do not count")
+}
+
+func TestEditWarStateIsBounded(t *testing.T) {
+	m := newTestManager()
+	for i := 0; i < 3*maxPageEdits; i++ {
+		change(m, "Busy", "Alice", "copyedit", i, int64(i), int64(i+1))
+	}
+	assert.Len(t, m.pages[key{lang: "en", target: "Busy"}].edits, maxPageEdits)
+}
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *recordingSender) Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, channelID+": "+m.Content)
+	return nil
+}
+
+func TestRunSendsAlerts(t *testing.T) {
+	m := newTestManager()
+	sender := &recordingSender{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.SetLocales(func(ctx context.Context, guildID, channelID string) i18n.Localizer {
+		if guildID == "g1" {
+			return i18n.Default().Localizer("es")
+		}
+		return i18n.Localizer{}
+	})
+	go m.Run(ctx, sender, zap.NewNop().Sugar())
+
+	m.queue <- message{guildID: "g1", channelID: "c1", format: func(loc i18n.Localizer) string { return "spike " + loc.Lang() }}
+	assert.Eventually(t, func() bool {
+		sender.mu.Lock()
+		defer sender.mu.Unlock()
+		return len(sender.sent) == 1 && sender.sent[0] == "c1: spike es"
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,252 @@
+package alert
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/watch"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+const (
+	// DefaultWarWindow is how far back reverts count towards an edit war.
+	DefaultWarWindow = time.Hour
+	// MinWarReverts is how many reverts make an edit war, unless an alert
+	// asks for more.
+	MinWarReverts = 3
+	// MaxWarReverts is the most reverts a page's history holds, and so the
+	// highest useful alert threshold.
+	MaxWarReverts = maxPageEdits
+
+	// maxPageEdits bounds the edits remembered per page.
+	maxPageEdits = 20
+	// maxWarPages bounds the pages remembered across all wikis.
+	maxWarPages = 20000
+	// maxWarsListed is the most wars EditWars returns.
+	maxWarsListed = 10
+)
+
+// revertComment matches the summaries MediaWiki writes for undo and
+// rollback and the usual ways editors describe a manual revert. The stream
+// does not carry change tags, so summaries are the closest thing.
+var revertComment = regexp.MustCompile(`(?i)\b(undid|undo|undone|revert|reverted|reverting|rv|rvv|rollback|rolled back)\b`)
+
+// undoneRevision finds the revision an undo summary names, as in "Undid
+// revision 1234 by Example".
+var undoneRevision = regexp.MustCompile(`(?i)\bundid revision (\d+)\b`)
+
+// War is a page whose edits two or more users keep reverting.
+type War struct {
+	Lang  string
+	Title string
+	// Reverts counts the reverts within the window, and Users the people
+	// who made them.
+	Reverts int
+	Users   int
+	Last    time.Time
+}
+
+type pageEdit struct {
+	user string
+	at   int64
+	// rev is the revision the edit made and parent the one it was made on.
+	rev    int64
+	parent int64
+	before int64
+	after  int64
+	revert bool
+}
+
+// page is the recent history of one page, newest edit last.
+type page struct {
+	title string
+	edits []pageEdit
+}
+
+// warSubscription is an edit war alert and the pages it reported, so each
+// war is reported once rather than on every revert.
+type warSubscription struct {
+	alert    *models.Alert
+	reported map[string]int64
+}
+
+// trackWar adds an edit to its page's history. When the edit is a revert
+// it returns the page's war, which may not have enough reverts to count
+// yet; otherwise it returns nil.
+func (m *Manager) trackWar(lang string, event *models.RecentChangeEvent) *War {
+	k := key{lang: lang, target: watch.NormalizeTitle(event.Title)}
+	p, ok := m.pages[k]
+	if !ok {
+		if !m.roomForPage(event.Timestamp) {
+			return nil
+		}
+		p = &page{}
+		m.pages[k] = p
+	}
+	p.title = event.Title
+
+	edit := pageEdit{
+		user:   event.User,
+		at:     event.Timestamp,
+		rev:    event.Revision.New,
+		parent: event.Revision.Old,
+		before: event.Length.Old,
+		after:  event.Length.New,
+	}
+	edit.revert = p.isRevert(edit, event.Comment, m.since(event.Timestamp))
+	p.edits = append(p.edits, edit)
+	if len(p.edits) > maxPageEdits {
+		p.edits = p.edits[len(p.edits)-maxPageEdits:]
+	}
+	if !edit.revert {
+		return nil
+	}
+	war := p.war(lang, m.since(event.Timestamp))
+	return &war
+}
+
+// isRevert reports whether edit reverts someone else's recent edit. When
+// the reverted edit can be found its author decides, so undoing one's own
+// edit does not count; otherwise a summary that says revert is enough.
+func (p *page) isRevert(edit pageEdit, comment string, since int64) bool {
+	if prev, ok := p.reverted(edit, comment, since); ok {
+		return prev.user != edit.user
+	}
+	return revertComment.MatchString(comment)
+}
+
+// reverted finds the recent edit that edit reverts: the revision an undo
+// summary names, or else the latest edit whose parent revision edit takes
+// the page back to. The stream carries revision IDs but not content, so
+// going back to a revision means going back to its size, on top of that
+// edit or a later one.
+func (p *page) reverted(edit pageEdit, comment string, since int64) (pageEdit, bool) {
+	if m := undoneRevision.FindStringSubmatch(comment); m != nil {
+		rev, _ := strconv.ParseInt(m[1], 10, 64)
+		for i := len(p.edits) - 1; i >= 0 && p.edits[i].at > since; i-- {
+			if p.edits[i].rev == rev {
+				return p.edits[i], true
+			}
+		}
+	}
+	if edit.before == edit.after {
+		return pageEdit{}, false
+	}
+	for i := len(p.edits) - 1; i >= 0 && p.edits[i].at > since; i-- {
+		prev := p.edits[i]
+		if edit.parent != 0 && edit.parent < prev.rev {
+			continue
+		}
+		if prev.before == edit.after && prev.before != prev.after {
+			return prev, true
+		}
+	}
+	return pageEdit{}, false
+}
+
+// war sums up the reverts on the page since the given time.
+func (p *page) war(lang string, since int64) War {
+	w := War{Lang: lang, Title: p.title}
+	users := make(map[string]bool)
+	for _, e := range p.edits {
+		if !e.revert || e.at <= since {
+			continue
+		}
+		w.Reverts++
+		users[e.user] = true
+		w.Last = time.Unix(e.at, 0).UTC()
+	}
+	w.Users = len(users)
+	return w
+}
+
+func (w War) active(minReverts int) bool {
+	return w.Reverts >= minReverts && w.Users >= 2
+}
+
+// roomForPage makes room for one more page by forgetting pages without
+// recent edits, and reports whether there is room.
+func (m *Manager) roomForPage(now int64) bool {
+	if len(m.pages) < maxWarPages {
+		return true
+	}
+	since := m.since(now)
+	for k, p := range m.pages {
+		if p.edits[len(p.edits)-1].at <= since {
+			delete(m.pages, k)
+		}
+	}
+	return len(m.pages) < maxWarPages
+}
+
+// since returns the Unix time at which the war window ending at now starts.
+func (m *Manager) since(now int64) int64 {
+	return now - int64(m.warWindow.Seconds())
+}
+
+// reportWar returns the alerts the war triggers: one for every subscription
+// on its wiki or page whose threshold it reaches, unless that subscription
+// reported a war on the page within the window.
+func (m *Manager) reportWar(war *War, event *models.RecentChangeEvent) []message {
+	title := watch.NormalizeTitle(war.Title)
+	now := war.Last.Unix()
+	var out []message
+	for _, k := range []key{{lang: war.Lang}, {lang: war.Lang, target: title}} {
+		for _, sub := range m.wars[k] {
+			if !war.active(int(sub.alert.Threshold)) {
+				continue
+			}
+			if at, ok := sub.reported[title]; ok && at > m.since(now) {
+				continue
+			}
+			for t, at := range sub.reported {
+				if at <= m.since(now) {
+					delete(sub.reported, t)
+				}
+			}
+			sub.reported[title] = now
+			link := wikilink.For(war.Lang, event).Page
+			minutes := int(m.warWindow.Minutes())
+			out = append(out, message{
+				guildID:   sub.alert.GuildID,
+				channelID: sub.alert.ChannelID,
+				format: func(loc i18n.Localizer) string {
+					return loc.N("alert.editwar_fired", war.Reverts, war.Title, link, war.Lang, war.Users, minutes)
+				},
+			})
+		}
+	}
+	return out
+}
+
+// EditWars returns the pages on lang that are in an edit war now, the most
+// reverted first.
+func (m *Manager) EditWars(lang string) []War {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := m.since(m.now().Unix())
+	var wars []War
+	for k, p := range m.pages {
+		if k.lang != lang {
+			continue
+		}
+		if w := p.war(lang, since); w.active(MinWarReverts) {
+			wars = append(wars, w)
+		}
+	}
+	sort.Slice(wars, func(i, j int) bool {
+		if wars[i].Reverts != wars[j].Reverts {
+			return wars[i].Reverts > wars[j].Reverts
+		}
+		return wars[i].Last.After(wars[j].Last)
+	})
+	if len(wars) > maxWarsListed {
+		wars = wars[:maxWarsListed]
+	}
+	return wars
+}
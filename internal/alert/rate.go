@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"math"
+	"time"
+)
+
+// Rate counts events per minute over a sliding window, and keeps a baseline
+// of the usual count per minute. Minutes only reach the baseline once they
+// leave the window, so a spike does not raise its own baseline while it is
+// being measured.
+type Rate struct {
+	buckets []int
+	// minute is the newest minute in the window, in minutes since the
+	// epoch; first is the minute counting started.
+	minute int64
+	first  int64
+	total  int
+
+	baseline float64
+	// folded is how many minutes the baseline was built from.
+	folded int
+	alpha  float64
+}
+
+// NewRate starts counting at start, with a window of the given length and
+// a baseline that forgets half its weight every halfLife.
+func NewRate(window, halfLife time.Duration, start time.Time) *Rate {
+	n := int(window / time.Minute)
+	if n < 1 {
+		n = 1
+	}
+	m := minuteOf(start)
+	return &Rate{
+		buckets: make([]int, n),
+		minute:  m,
+		first:   m,
+		alpha:   1 - math.Pow(2, -1/halfLife.Minutes()),
+	}
+}
+
+func minuteOf(t time.Time) int64 {
+	return int64(math.Floor(float64(t.Unix()) / 60))
+}
+
+// Add counts an event at t. Events older than the window are ignored.
+func (r *Rate) Add(t time.Time) {
+	m := minuteOf(t)
+	if m <= r.minute-int64(len(r.buckets)) {
+		return
+	}
+	r.advance(m)
+	r.buckets[r.index(m)]++
+	r.total++
+}
+
+// advance moves the window forward to minute m, folding the minutes that
+// leave it into the baseline.
+func (r *Rate) advance(m int64) {
+	n := int64(len(r.buckets))
+	if m-r.minute > n {
+		// Every bucket leaves the window; the minutes in between had no
+		// events.
+		for i := int64(1); i <= n; i++ {
+			r.evict(r.minute + i)
+		}
+		if idle := m - r.minute - n; idle > 0 {
+			r.foldIdle(idle)
+		}
+		r.minute = m
+		return
+	}
+	for r.minute < m {
+		r.minute++
+		r.evict(r.minute)
+	}
+}
+
+// evict empties the bucket that minute m reuses, folding the minute it held.
+func (r *Rate) evict(m int64) {
+	i := r.index(m)
+	if m-int64(len(r.buckets)) >= r.first {
+		r.fold(float64(r.buckets[i]))
+	}
+	r.total -= r.buckets[i]
+	r.buckets[i] = 0
+}
+
+func (r *Rate) index(m int64) int {
+	n := int64(len(r.buckets))
+	return int(((m % n) + n) % n)
+}
+
+// fold adds one minute to the baseline. Until the baseline has seen as many
+// minutes as its half-life weighs, it is a plain average, so it does not
+// start out biased towards zero.
+func (r *Rate) fold(count float64) {
+	r.folded++
+	weight := math.Max(1/float64(r.folded), r.alpha)
+	r.baseline += weight * (count - r.baseline)
+}
+
+// foldIdle adds k minutes without events to the baseline.
+func (r *Rate) foldIdle(k int64) {
+	for ; k > 0 && 1/float64(r.folded+1) > r.alpha; k-- {
+		r.fold(0)
+	}
+	if k > 0 {
+		r.folded += int(k)
+		r.baseline *= math.Pow(1-r.alpha, float64(k))
+	}
+}
+
+// Count returns the number of events in the window.
+func (r *Rate) Count() int {
+	return r.total
+}
+
+// PerMinute returns the average number of events per minute in the window,
+// counting only minutes since counting started.
+func (r *Rate) PerMinute() float64 {
+	minutes := r.minute - r.first + 1
+	if n := int64(len(r.buckets)); minutes > n {
+		minutes = n
+	}
+	return float64(r.total) / float64(minutes)
+}
+
+// Baseline returns the usual number of events per minute.
+func (r *Rate) Baseline() float64 {
+	return r.baseline
+}
+
+// Observed returns how long the baseline has been built over.
+func (r *Rate) Observed() time.Duration {
+	return time.Duration(r.folded) * time.Minute
+}
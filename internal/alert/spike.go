@@ -0,0 +1,63 @@
+package alert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+const (
+	// minEvents is the fewest events in the window that can be a spike.
+	minEvents = 5
+	// minBaseline, in events per minute, keeps the near-zero baseline of a
+	// quiet page from turning any few edits into a spike.
+	minBaseline = 0.2
+)
+
+// observe counts event in the series and returns the alerts it triggers:
+// one for every subscription whose threshold the current rate reaches,
+// unless that subscription is still in the spike it last reported or
+// cooling down.
+func (m *Manager) observe(s *series, lang string, event *models.RecentChangeEvent, t time.Time) []message {
+	s.rate.Add(t)
+	if s.rate.Observed() < m.warmup {
+		return nil
+	}
+
+	count := s.rate.Count()
+	ratio := s.rate.PerMinute() / math.Max(s.rate.Baseline(), minBaseline)
+	var out []message
+	for _, sub := range s.subs {
+		if count < minEvents || ratio < sub.alert.Threshold {
+			sub.firing = false
+			continue
+		}
+		if sub.firing || t.Sub(sub.last) < m.cooldown {
+			continue
+		}
+		sub.firing, sub.last = true, t
+		where := m.spikeWhere(sub.alert, lang, event)
+		minutes := int(m.window.Minutes())
+		out = append(out, message{
+			guildID:   sub.alert.GuildID,
+			channelID: sub.alert.ChannelID,
+			format: func(loc i18n.Localizer) string {
+				return loc.N("alert.spike_fired", count, where, minutes, strconv.FormatFloat(ratio, 'f', 1, 64))
+			},
+		})
+	}
+	return out
+}
+
+// spikeWhere names the wiki or links the page an alert watches.
+func (m *Manager) spikeWhere(a *models.Alert, lang string, event *models.RecentChangeEvent) string {
+	if a.Target == "" {
+		return fmt.Sprintf("**%s**", lang)
+	}
+	return fmt.Sprintf("[%s](<%s>) (%s)", event.Title, wikilink.For(lang, event).Page, lang)
+}
@@ -0,0 +1,317 @@
+// Package chart renders simple line and bar charts to PNG using only the
+// standard library, so charts can be attached to Discord messages without
+// a browser or font files.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 400
+
+	textScale  = 2
+	yTicks     = 4
+	maxXLabels = 8
+	margin     = 16
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	foreground = color.RGBA{0x23, 0x27, 0x2a, 0xff}
+	gridColor  = color.RGBA{0xe3, 0xe5, 0xe8, 0xff}
+	axisColor  = color.RGBA{0x99, 0xaa, 0xb5, 0xff}
+	seriesRGBA = color.RGBA{0x34, 0x98, 0xdb, 0xff}
+)
+
+// Point is one labelled value: a day in a line chart or an article in a
+// bar chart. The built-in font only has ASCII and accented Latin letters,
+// so text in other scripts should be labelled by number instead, with the
+// full text shown next to the chart.
+type Point struct {
+	Label string
+	Value float64
+}
+
+// Chart describes what to draw. Width and Height default to DefaultWidth
+// and DefaultHeight. Without a Title the plot starts at the top.
+type Chart struct {
+	Title  string
+	Points []Point
+	Width  int
+	Height int
+}
+
+func (c Chart) size() (int, int) {
+	w, h := c.Width, c.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return w, h
+}
+
+func (c Chart) maxValue() float64 {
+	max := 0.0
+	for _, p := range c.Points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	return max
+}
+
+func newCanvas(c Chart) *image.RGBA {
+	w, h := c.size()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fillRect(img, 0, 0, w, h, background)
+	if c.Title != "" {
+		drawText(img, margin, margin, fitText(c.Title, textScale, w-2*margin), textScale, foreground)
+	}
+	return img
+}
+
+// plotTop is where the plot area starts, below the title if there is one.
+func plotTop(c Chart) int {
+	if c.Title == "" {
+		return margin + textHeight(textScale)/2
+	}
+	return margin*2 + textHeight(textScale)*3/2
+}
+
+// Line draws the points left to right as a line with a value axis starting
+// at zero. It suits daily counts.
+func Line(c Chart) *image.RGBA {
+	img := newCanvas(c)
+	w, h := c.size()
+
+	top := plotTop(c)
+	bottom := h - margin - textHeight(textScale) - 8
+	axisMax, step := niceScale(c.maxValue(), yTicks)
+
+	labelWidth := 0
+	for v := 0.0; v <= axisMax; v += step {
+		if lw := textWidth(FormatValue(v), textScale); lw > labelWidth {
+			labelWidth = lw
+		}
+	}
+	left := margin + labelWidth + 8
+	right := w - margin
+
+	y := func(v float64) int {
+		return bottom - int(math.Round(v/axisMax*float64(bottom-top)))
+	}
+	for v := 0.0; v <= axisMax; v += step {
+		ty := y(v)
+		fillRect(img, left, ty, right-left, 1, gridColor)
+		label := FormatValue(v)
+		drawText(img, left-8-textWidth(label, textScale), ty-textHeight(textScale)/2, label, textScale, foreground)
+	}
+	fillRect(img, left, top, 1, bottom-top+1, axisColor)
+	fillRect(img, left, bottom, right-left, 1, axisColor)
+
+	n := len(c.Points)
+	if n == 0 {
+		return img
+	}
+	x := func(i int) int {
+		if n == 1 {
+			return (left + right) / 2
+		}
+		return left + int(math.Round(float64(i)*float64(right-left-1)/float64(n-1)))
+	}
+
+	every := (n + maxXLabels - 1) / maxXLabels
+	for i, p := range c.Points {
+		if i%every != 0 && i != n-1 {
+			continue
+		}
+		// The last label replaces the regular one before it if they would
+		// overlap.
+		if i != n-1 && n-1-i < every {
+			continue
+		}
+		lw := textWidth(p.Label, textScale)
+		lx := x(i) - lw/2
+		if lx < left {
+			lx = left
+		} else if lx+lw > right {
+			lx = right - lw
+		}
+		fillRect(img, x(i), bottom, 1, 4, axisColor)
+		drawText(img, lx, bottom+8, p.Label, textScale, foreground)
+	}
+
+	for i := 1; i < n; i++ {
+		drawLine(img, x(i-1), y(c.Points[i-1].Value), x(i), y(c.Points[i].Value), seriesRGBA)
+	}
+	for i, p := range c.Points {
+		fillRect(img, x(i)-2, y(p.Value)-2, 5, 5, seriesRGBA)
+	}
+	return img
+}
+
+// Bar draws one horizontal bar per point, top to bottom, with the label on
+// the left and the value after the bar. It suits rankings. Without a
+// Height the image is just tall enough for its bars.
+func Bar(c Chart) *image.RGBA {
+	maxRow := textHeight(textScale) * 3
+	n := len(c.Points)
+	if c.Height <= 0 && n > 0 {
+		c.Height = plotTop(c) + n*maxRow + margin
+	}
+	img := newCanvas(c)
+	w, h := c.size()
+	if n == 0 {
+		return img
+	}
+
+	top := plotTop(c)
+	rowHeight := (h - margin - top) / n
+	if rowHeight > maxRow {
+		rowHeight = maxRow
+	}
+	barHeight := rowHeight * 2 / 3
+	if barHeight < 1 {
+		barHeight = 1
+	}
+
+	// Labels take what they need, up to two fifths of the width.
+	labelWidth := 0
+	for _, p := range c.Points {
+		if lw := textWidth(p.Label, textScale); lw > labelWidth {
+			labelWidth = lw
+		}
+	}
+	if max := (w - 2*margin) * 2 / 5; labelWidth > max {
+		labelWidth = max
+	}
+	left := margin + labelWidth + 8
+	valueWidth := 0
+	for _, p := range c.Points {
+		if vw := textWidth(FormatValue(p.Value), textScale); vw > valueWidth {
+			valueWidth = vw
+		}
+	}
+	right := w - margin - valueWidth - 8
+	max := c.maxValue()
+
+	for i, p := range c.Points {
+		rowTop := top + i*rowHeight
+		textY := rowTop + (barHeight-textHeight(textScale))/2
+		label := fitText(p.Label, textScale, labelWidth)
+		drawText(img, left-8-textWidth(label, textScale), textY, label, textScale, foreground)
+
+		length := 0
+		if max > 0 {
+			length = int(math.Round(p.Value / max * float64(right-left)))
+		}
+		fillRect(img, left, rowTop, length, barHeight, seriesRGBA)
+		drawText(img, left+length+8, textY, FormatValue(p.Value), textScale, foreground)
+	}
+	fillRect(img, left, top, 1, n*rowHeight, axisColor)
+	return img
+}
+
+// PNG encodes img.
+func PNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FormatValue prints whole numbers as integers and large ones with a K or M
+// suffix, so axis labels stay short.
+func FormatValue(v float64) string {
+	switch {
+	case math.Abs(v) >= 1e6:
+		return trimZero(fmt.Sprintf("%.1f", v/1e6)) + "M"
+	case math.Abs(v) >= 1e4:
+		return trimZero(fmt.Sprintf("%.1f", v/1e3)) + "K"
+	case v == math.Trunc(v):
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return trimZero(fmt.Sprintf("%.1f", v))
+}
+
+func trimZero(s string) string {
+	return strings.TrimSuffix(s, ".0")
+}
+
+// niceScale picks an axis maximum of at least max that divides into ticks
+// steps of 1, 2 or 5 times a power of ten.
+func niceScale(max float64, ticks int) (float64, float64) {
+	if max <= 0 {
+		return float64(ticks), 1
+	}
+	raw := max / float64(ticks)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := 10 * mag
+	for _, m := range []float64{1, 2, 5} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	return step * math.Ceil(max/step), step
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.Color) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.Set(px, py, c)
+		}
+	}
+}
+
+// drawLine draws a two pixel wide line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		fillRect(img, x0, y0, 2, 2, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package chart
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// assertGolden compares img pixel by pixel with testdata/<name>.png. Run
+// go test ./internal/chart -update to accept a deliberate change.
+func assertGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+	data, err := PNG(img)
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden image; run with -update")
+	want, err := png.Decode(bytes.NewReader(golden))
+	require.NoError(t, err)
+
+	require.Equal(t, want.Bounds(), img.Bounds())
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			wr, wg, wb, wa := want.At(x, y).RGBA()
+			gr, gg, gb, ga := img.At(x, y).RGBA()
+			if wr != gr || wg != gg || wb != gb || wa != ga {
+				t.Fatalf("%s differs from golden image at (%d, %d)", name, x, y)
+			}
+		}
+	}
+}
+
+func TestLineGolden(t *testing.T) {
+	points := []Point{}
+	values := []float64{1200, 1850, 1630, 2410, 2290, 980, 1105, 1990, 2700, 2555, 2380, 1410, 1320, 2050}
+	for i, v := range values {
+		points = append(points, Point{Label: fmt.Sprintf("02-%02d", i+1), Value: v})
+	}
+	assertGolden(t, "line", Line(Chart{Title: "Daily edits on en", Points: points}))
+}
+
+func TestLineSinglePointGolden(t *testing.T) {
+	assertGolden(t, "line_single", Line(Chart{
+		Title:  "Daily edits on de",
+		Points: []Point{{Label: "02-04", Value: 7}},
+		Width:  400,
+		Height: 200,
+	}))
+}
+
+func TestBarGolden(t *testing.T) {
+	assertGolden(t, "bar", Bar(Chart{
+		Title: "Top articles on en, 2025-02-04",
+		Points: []Point{
+			{Label: "Main Page", Value: 412},
+			{Label: "2025 in film", Value: 268},
+			{Label: "Deaths in February 2025", Value: 201},
+			{Label: "A title that is much too long to fit next to its bar", Value: 97},
+			{Label: "Zürich", Value: 12},
+		},
+	}))
+}
+
+func TestBarNumberedGolden(t *testing.T) {
+	assertGolden(t, "bar_numbered", Bar(Chart{
+		Points: []Point{{Label: "1.", Value: 97}, {Label: "2.", Value: 40}, {Label: "3.", Value: 3}},
+	}))
+}
+
+func TestEmptyCharts(t *testing.T) {
+	assert.Equal(t, image.Rect(0, 0, DefaultWidth, DefaultHeight), Line(Chart{Title: "Nothing"}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 300, 100), Bar(Chart{Width: 300, Height: 100}).Bounds())
+}
+
+func TestNiceScale(t *testing.T) {
+	for _, tc := range []struct {
+		max, wantMax, wantStep float64
+	}{
+		{0, 4, 1},
+		{3, 3, 1},
+		{7, 8, 2},
+		{2700, 3000, 1000},
+		{412, 600, 200},
+		{1001, 1500, 500},
+	} {
+		gotMax, gotStep := niceScale(tc.max, 4)
+		assert.Equal(t, tc.wantMax, gotMax, "max for %v", tc.max)
+		assert.Equal(t, tc.wantStep, gotStep, "step for %v", tc.max)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "0", FormatValue(0))
+	assert.Equal(t, "950", FormatValue(950))
+	assert.Equal(t, "2.5", FormatValue(2.5))
+	assert.Equal(t, "12K", FormatValue(12000))
+	assert.Equal(t, "12.5K", FormatValue(12500))
+	assert.Equal(t, "1.2M", FormatValue(1234567))
+}
+
+func TestFitText(t *testing.T) {
+	assert.Equal(t, "Short", fitText("Short", 1, 100))
+	fitted := fitText("A much longer label", 1, 60)
+	assert.LessOrEqual(t, textWidth(fitted, 1), 60)
+	assert.Equal(t, "..", fitted[len(fitted)-2:])
+}
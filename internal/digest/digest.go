@@ -0,0 +1,297 @@
+// Package digest posts daily and weekly summaries of the ingested edits to
+// the channels that subscribed to them.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxLangs is the most wikis one digest covers.
+	MaxLangs = 5
+	// DefaultHour is the local hour digests are posted at unless another
+	// time is asked for.
+	DefaultHour = 9
+
+	// maxLateness is how late a digest is still posted, such as after a
+	// restart; runs missed for longer are skipped.
+	maxLateness = 6 * time.Hour
+	topLimit    = 5
+	dateLayout  = "2006-01-02"
+	maxFieldLen = 1024
+	colorDigest = 0x9b59b6
+)
+
+// Stats is the part of the stats store digests are made from.
+type Stats interface {
+	GetRange(ctx context.Context, lang string, from, to string) ([]models.DailyCount, error)
+	GetHours(ctx context.Context, lang string, from, to time.Time) ([]models.HourlyCount, error)
+	TopArticles(ctx context.Context, lang string, from, to string, limit int) ([]models.ArticleCount, error)
+	TopEditors(ctx context.Context, lang string, from, to string, limit int) ([]models.EditorCount, error)
+	NewPages(ctx context.Context, lang string, from, to string, limit int) ([]models.NewPage, int, error)
+}
+
+// Store finds due digests and claims their runs.
+type Store interface {
+	Due(ctx context.Context, now time.Time) ([]*models.Digest, error)
+	Claim(ctx context.Context, id int64, due, next time.Time) (bool, error)
+}
+
+// Sender queues messages for delivery, such as *outbound.Dispatcher.
+type Sender interface {
+	Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error
+}
+
+// ZoneFunc returns the timezone of a guild's digest times.
+type ZoneFunc func(ctx context.Context, guildID string) *time.Location
+
+// LocaleFunc returns the localizer for digests posted to a channel of a
+// guild.
+type LocaleFunc func(ctx context.Context, guildID, channelID string) i18n.Localizer
+
+// Scheduler posts digests when they are due. The schedule lives in the
+// store, so it survives restarts, and every run is claimed in the store
+// before it is posted, so several instances never post one digest twice.
+type Scheduler struct {
+	store  Store
+	stats  Stats
+	zone   ZoneFunc
+	locale LocaleFunc
+	now    func() time.Time
+}
+
+func NewScheduler(store Store, stats Stats) *Scheduler {
+	return &Scheduler{
+		store: store,
+		stats: stats,
+		zone:  func(context.Context, string) *time.Location { return time.UTC },
+		locale: func(context.Context, string, string) i18n.Localizer {
+			return i18n.Localizer{}
+		},
+		now: time.Now,
+	}
+}
+
+// SetZones makes digests run at their time in the zone fn returns for the
+// digest's guild instead of UTC. It must be called before Run.
+func (s *Scheduler) SetZones(fn ZoneFunc) {
+	s.zone = fn
+}
+
+// SetLocales makes digests use the language fn returns for their channel
+// instead of English. It must be called before Run.
+func (s *Scheduler) SetLocales(fn LocaleFunc) {
+	s.locale = fn
+}
+
+// Next returns the first time after t at which d is due, in zone. Weekly
+// digests are due on Mondays.
+func Next(d *models.Digest, t time.Time, zone *time.Location) time.Time {
+	local := t.In(zone)
+	for i := 0; ; i++ {
+		run := time.Date(local.Year(), local.Month(), local.Day()+i, d.Hour, d.Minute, 0, 0, zone)
+		if !run.After(t) || (d.Period == models.DigestWeekly && run.Weekday() != time.Monday) {
+			continue
+		}
+		return run
+	}
+}
+
+// RunDue posts the digests that are due. It is meant to run every minute
+// as a scheduled job; a digest that fails to build or queue is logged and
+// does not stop the others.
+func (s *Scheduler) RunDue(ctx context.Context, sender Sender, logger *zap.SugaredLogger) error {
+	now := s.now()
+	due, err := s.store.Due(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, d := range due {
+		next := Next(d, now, s.zone(ctx, d.GuildID))
+		claimed, err := s.store.Claim(ctx, d.ID, d.NextRun, next)
+		if err != nil {
+			logger.Errorw("Failed to claim digest", "channel", d.ChannelID, "error", err)
+			continue
+		}
+		if !claimed {
+			// Another instance is posting it.
+			continue
+		}
+		if now.Sub(d.NextRun) > maxLateness {
+			logger.Infow("Skipped overdue digest", "channel", d.ChannelID, "due", d.NextRun)
+			continue
+		}
+		embed, err := s.Build(ctx, d, d.NextRun)
+		if err != nil {
+			logger.Errorw("Failed to build digest", "channel", d.ChannelID, "error", err)
+			continue
+		}
+		if err := sender.Enqueue(d.ChannelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}); err != nil {
+			logger.Errorw("Failed to queue digest", "channel", d.ChannelID, "error", err)
+		}
+	}
+	return nil
+}
+
+// period returns the days a digest posted at t covers, from local midnight
+// in zone to local midnight before t, and the start of the period before
+// it, which ends at from.
+func period(d *models.Digest, t time.Time, zone *time.Location) (from, to, prevFrom time.Time) {
+	local := t.In(zone)
+	to = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, zone)
+	days := 1
+	if d.Period == models.DigestWeekly {
+		days = 7
+	}
+	from = to.AddDate(0, 0, -days)
+	return from, to, from.AddDate(0, 0, -days)
+}
+
+// Build makes the digest d posts at t: the day, or for weekly digests the
+// seven days, before t in the guild's zone, in the channel's language.
+func (s *Scheduler) Build(ctx context.Context, d *models.Digest, t time.Time) (*discordgo.MessageEmbed, error) {
+	zone := s.zone(ctx, d.GuildID)
+	loc := s.locale(ctx, d.GuildID, d.ChannelID)
+	from, to, prevFrom := period(d, t, zone)
+	first, last := from.Format(dateLayout), to.AddDate(0, 0, -1).Format(dateLayout)
+	embed := &discordgo.MessageEmbed{
+		Title: loc.T("digest.title_daily", first),
+		Color: colorDigest,
+	}
+	compare := "digest.compare_daily"
+	if d.Period == models.DigestWeekly {
+		embed.Title = loc.T("digest.title_weekly", first, last)
+		compare = "digest.compare_weekly"
+	}
+	if zone != time.UTC {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: loc.T("digest.zone", zone.String())}
+	}
+	for _, lang := range d.Langs {
+		field, err := s.section(ctx, loc, lang, from, to, prevFrom, compare)
+		if err != nil {
+			return nil, err
+		}
+		embed.Fields = append(embed.Fields, field)
+	}
+	return embed, nil
+}
+
+// section sums up one wiki's activity from one local midnight to another.
+// Top articles, editors and new pages are only stored per UTC day, so they
+// cover the UTC days with the same dates.
+func (s *Scheduler) section(ctx context.Context, loc i18n.Localizer, lang string, from, to, prevFrom time.Time, compare string) (*discordgo.MessageEmbedField, error) {
+	total, err := s.total(ctx, lang, from, to)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := s.total(ctx, lang, prevFrom, from)
+	if err != nil {
+		return nil, err
+	}
+	first, last := from.Format(dateLayout), to.AddDate(0, 0, -1).Format(dateLayout)
+	articles, err := s.stats.TopArticles(ctx, lang, first, last, topLimit)
+	if err != nil {
+		return nil, err
+	}
+	editors, err := s.stats.TopEditors(ctx, lang, first, last, topLimit)
+	if err != nil {
+		return nil, err
+	}
+	pages, created, err := s.stats.NewPages(ctx, lang, first, last, topLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	name := loc.N("digest.changes", total, lang)
+	if prev > 0 {
+		name = loc.T(compare, name, change(loc, total, prev))
+	}
+
+	host := wikilink.Host(lang, "")
+	var lines []string
+	if len(articles) > 0 {
+		items := make([]string, len(articles))
+		for i, a := range articles {
+			items[i] = fmt.Sprintf("[%s](%s) (%d)", a.Title, wikilink.Page(host, a.Title), a.Count)
+		}
+		lines = append(lines, loc.T("digest.top_articles", strings.Join(items, " · ")))
+	}
+	if len(editors) > 0 {
+		items := make([]string, len(editors))
+		for i, e := range editors {
+			items[i] = fmt.Sprintf("%s (%d)", e.User, e.Count)
+		}
+		lines = append(lines, loc.T("digest.top_editors", strings.Join(items, " · ")))
+	}
+	if created > 0 {
+		items := make([]string, len(pages))
+		for i, p := range pages {
+			items[i] = fmt.Sprintf("[%s](%s)", p.Title, wikilink.Page(host, p.Title))
+		}
+		lines = append(lines, loc.T("digest.new_pages", created, strings.Join(items, " · ")))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, loc.T("digest.nothing"))
+	}
+	return &discordgo.MessageEmbedField{Name: name, Value: truncate(strings.Join(lines, "\n"), maxFieldLen)}, nil
+}
+
+// total counts the changes on lang from one local midnight to another.
+// UTC days are stored as such; other zones sum the hourly counts, each hour
+// towards the day it starts in.
+func (s *Scheduler) total(ctx context.Context, lang string, from, to time.Time) (int, error) {
+	total := 0
+	if from.Location() == time.UTC {
+		counts, err := s.stats.GetRange(ctx, lang, from.Format(dateLayout), to.AddDate(0, 0, -1).Format(dateLayout))
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range counts {
+			total += c.Count
+		}
+		return total, nil
+	}
+
+	hours, err := s.stats.GetHours(ctx, lang, from.Truncate(time.Hour), to)
+	if err != nil {
+		return 0, err
+	}
+	for _, h := range hours {
+		if !h.Hour.Before(from) {
+			total += h.Count
+		}
+	}
+	return total, nil
+}
+
+// change describes the relative change from prev to total, which must be
+// above zero.
+func change(loc i18n.Localizer, total, prev int) string {
+	pct := float64(total-prev) / float64(prev) * 100
+	switch {
+	case total > prev:
+		return loc.T("digest.up", strconv.FormatFloat(pct, 'f', 1, 64))
+	case total < prev:
+		return loc.T("digest.down", strconv.FormatFloat(-pct, 'f', 1, 64))
+	}
+	return loc.T("digest.same")
+}
+
+// truncate cuts s to at most n runes, ending with an ellipsis when cut.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,207 @@
+package digest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"go.uber.org/zap"
+)
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	daily := &models.Digest{Period: models.DigestDaily, Hour: 9}
+	weekly := &models.Digest{Period: models.DigestWeekly, Hour: 9, Minute: 30}
+
+	// Wednesday 2025-02-05 08:00 UTC is 09:00 in Berlin.
+	at := time.Date(2025, 2, 5, 7, 59, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 2, 5, 9, 0, 0, 0, berlin), Next(daily, at, berlin))
+	assert.Equal(t, time.Date(2025, 2, 6, 9, 0, 0, 0, berlin), Next(daily, at.Add(time.Minute), berlin))
+	assert.Equal(t, time.Date(2025, 2, 6, 9, 0, 0, 0, time.UTC), Next(daily, at.Add(2*time.Hour), time.UTC))
+	assert.Equal(t, time.Date(2025, 2, 10, 9, 30, 0, 0, berlin), Next(weekly, at, berlin))
+
+	// Across the change to summer time the digest stays at 09:00 local.
+	march := time.Date(2025, 3, 29, 9, 0, 0, 0, berlin)
+	next := Next(daily, march, berlin)
+	assert.Equal(t, time.Date(2025, 3, 30, 9, 0, 0, 0, berlin), next)
+	assert.Equal(t, 23*time.Hour, next.Sub(march))
+}
+
+func TestPeriod(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	daily := &models.Digest{Period: models.DigestDaily}
+	weekly := &models.Digest{Period: models.DigestWeekly}
+
+	at := time.Date(2025, 2, 10, 8, 30, 0, 0, time.UTC)
+	from, to, prevFrom := period(daily, at, time.UTC)
+	assert.Equal(t, time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC), prevFrom)
+
+	from, to, prevFrom = period(weekly, at, time.UTC)
+	assert.Equal(t, time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2025, 1, 27, 0, 0, 0, 0, time.UTC), prevFrom)
+
+	// 09:00 on 2025-02-10 in Auckland is still 2025-02-09 in UTC; the
+	// digest covers the local day before.
+	from, to, _ = period(daily, time.Date(2025, 2, 10, 9, 0, 0, 0, auckland), auckland)
+	assert.Equal(t, time.Date(2025, 2, 9, 0, 0, 0, 0, auckland), from)
+	assert.Equal(t, time.Date(2025, 2, 10, 0, 0, 0, 0, auckland), to)
+
+	// The week of the change to summer time is an hour short.
+	from, to, _ = period(weekly, time.Date(2025, 3, 31, 9, 0, 0, 0, berlin), berlin)
+	assert.Equal(t, time.Date(2025, 3, 24, 0, 0, 0, 0, berlin), from)
+	assert.Equal(t, 7*24*time.Hour-time.Hour, to.Sub(from))
+}
+
+func newTestStats() *store.MockStatStore {
+	stats := &store.MockStatStore{
+		Stats: map[string]int{"en_2025-02-08": 80, "en_2025-02-09": 100, "de_2025-02-09": 7},
+		Articles: map[string]int{
+			"en_2025-02-09_Main Page": 12,
+			"en_2025-02-09_Berlin":    4,
+			"en_2025-02-08_Old News":  40,
+		},
+		Editors: map[string]int{"en_2025-02-09_Alice": 9, "en_2025-02-09_Bob": 3},
+	}
+	ctx := context.Background()
+	stats.AddNewPage(ctx, "en", "2025-02-09", "First", "Alice")
+	stats.AddNewPage(ctx, "en", "2025-02-09", "Second", "Bob")
+	return stats
+}
+
+func TestBuild(t *testing.T) {
+	s := NewScheduler(&store.MockDigestStore{}, newTestStats())
+	d := &models.Digest{Period: models.DigestDaily, Langs: []string{"en", "de", "fr"}}
+
+	embed, err := s.Build(context.Background(), d, time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "📰 Daily digest for 2025-02-09", embed.Title)
+	assert.Nil(t, embed.Footer, "UTC days need no note")
+	require.Len(t, embed.Fields, 3)
+
+	en := embed.Fields[0]
+	assert.Equal(t, "en: 100 changes (▲ 25.0% on the day before)", en.Name)
+	assert.Equal(t, "**Top articles:** [Main Page](https://en.wikipedia.org/wiki/Main_Page) (12) · "+
+		"[Berlin](https://en.wikipedia.org/wiki/Berlin) (4)\n"+
+		"**Top editors:** Alice (9) · Bob (3)\n"+
+		"**New pages:** 2, latest [Second](https://en.wikipedia.org/wiki/Second) · [First](https://en.wikipedia.org/wiki/First)", en.Value)
+	assert.Equal(t, "de: 7 changes", embed.Fields[1].Name)
+	assert.Equal(t, "fr: 0 changes", embed.Fields[2].Name)
+	assert.Equal(t, "Nothing was recorded.", embed.Fields[2].Value)
+
+	d.Period = models.DigestWeekly
+	embed, err = s.Build(context.Background(), d, time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "📰 Weekly digest for 2025-02-03 to 2025-02-09", embed.Title)
+	assert.Equal(t, "en: 180 changes", embed.Fields[0].Name)
+}
+
+func TestBuildInGuildZoneAndLanguage(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	require.NoError(t, err)
+	stats := newTestStats()
+	// Auckland is 13 hours ahead of UTC in February, so its 2025-02-09
+	// runs from 11:00 UTC on the 8th to 11:00 UTC on the 9th.
+	stats.Hours = map[string]map[time.Time]int{"en": {
+		time.Date(2025, 2, 8, 10, 0, 0, 0, time.UTC): 4,
+		time.Date(2025, 2, 8, 11, 0, 0, 0, time.UTC): 5,
+		time.Date(2025, 2, 9, 10, 0, 0, 0, time.UTC): 3,
+		time.Date(2025, 2, 9, 11, 0, 0, 0, time.UTC): 100,
+	}}
+	s := NewScheduler(&store.MockDigestStore{}, stats)
+	s.SetZones(func(context.Context, string) *time.Location { return auckland })
+	s.SetLocales(func(context.Context, string, string) i18n.Localizer { return i18n.Default().Localizer("ru") })
+	d := &models.Digest{Period: models.DigestDaily, Langs: []string{"en"}}
+
+	embed, err := s.Build(context.Background(), d, time.Date(2025, 2, 10, 9, 0, 0, 0, auckland))
+	require.NoError(t, err)
+	assert.Equal(t, "📰 Сводка за 2025-02-09", embed.Title)
+	require.Len(t, embed.Fields, 1)
+	assert.Equal(t, "en: 8 правок (▲ 100.0% к предыдущему дню)", embed.Fields[0].Name)
+	assert.Contains(t, embed.Fields[0].Value, "**Популярные статьи:** [Main Page]")
+	require.NotNil(t, embed.Footer)
+	assert.Contains(t, embed.Footer.Text, "Pacific/Auckland")
+}
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent map[string][]*discordgo.MessageSend
+}
+
+func (r *recordingSender) Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent == nil {
+		r.sent = make(map[string][]*discordgo.MessageSend)
+	}
+	r.sent[channelID] = append(r.sent[channelID], m)
+	return nil
+}
+
+func TestRunDuePostsOnceAcrossInstances(t *testing.T) {
+	digests := &store.MockDigestStore{}
+	ctx := context.Background()
+	due := time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, digests.Save(ctx, &models.Digest{ChannelID: "c1", Period: models.DigestDaily, Langs: []string{"en"}, Hour: 9, NextRun: due}))
+	require.NoError(t, digests.Save(ctx, &models.Digest{ChannelID: "c2", Period: models.DigestDaily, Langs: []string{"en"}, Hour: 9, NextRun: due.Add(time.Hour)}))
+
+	sender := &recordingSender{}
+	now := due.Add(30 * time.Second)
+	var instances []*Scheduler
+	for i := 0; i < 2; i++ {
+		s := NewScheduler(digests, newTestStats())
+		s.now = func() time.Time { return now }
+		instances = append(instances, s)
+	}
+	var wg sync.WaitGroup
+	for _, s := range instances {
+		wg.Add(1)
+		go func(s *Scheduler) {
+			defer wg.Done()
+			assert.NoError(t, s.RunDue(ctx, sender, zap.NewNop().Sugar()))
+		}(s)
+	}
+	wg.Wait()
+
+	require.Len(t, sender.sent["c1"], 1)
+	assert.Equal(t, "📰 Daily digest for 2025-02-09", sender.sent["c1"][0].Embeds[0].Title)
+	assert.Empty(t, sender.sent["c2"], "not due yet")
+	d, err := digests.Get(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, due.AddDate(0, 0, 1), d.NextRun)
+
+	// Running again in the same minute posts nothing new.
+	require.NoError(t, instances[0].RunDue(ctx, sender, zap.NewNop().Sugar()))
+	assert.Len(t, sender.sent["c1"], 1)
+}
+
+func TestRunDueSkipsLongOverdueDigests(t *testing.T) {
+	digests := &store.MockDigestStore{}
+	ctx := context.Background()
+	due := time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, digests.Save(ctx, &models.Digest{ChannelID: "c1", Period: models.DigestDaily, Langs: []string{"en"}, Hour: 9, NextRun: due}))
+
+	// The bot was down for two days.
+	s := NewScheduler(digests, newTestStats())
+	s.now = func() time.Time { return due.Add(50 * time.Hour) }
+	sender := &recordingSender{}
+	assert.NoError(t, s.RunDue(ctx, sender, zap.NewNop().Sugar()))
+
+	assert.Empty(t, sender.sent)
+	d, err := digests.Get(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 2, 13, 9, 0, 0, 0, time.UTC), d.NextRun, "missed runs are not caught up")
+}
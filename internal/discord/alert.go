@@ -0,0 +1,176 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/alert"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"github.com/vlkhvnn/TestON/internal/watch"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+const (
+	// maxAlertsPerChannel keeps a single channel from flooding itself.
+	maxAlertsPerChannel = 25
+	// Spike thresholds are multiples of the usual rate. Below the minimum,
+	// ordinary ups and downs would alert.
+	minSpikeThreshold = 1.5
+	maxSpikeThreshold = 100
+)
+
+func (b *Bot) handleAlert(ctx context.Context, req *request, action, target, value string) {
+	switch action {
+	case "spike":
+		b.addSpikeAlert(ctx, req, target, value)
+	case "editwar":
+		b.addEditWarAlert(ctx, req, target, value)
+	case "list":
+		b.listAlerts(ctx, req)
+	case "remove":
+		b.removeAlert(ctx, req, target)
+	}
+}
+
+func (b *Bot) addSpikeAlert(ctx context.Context, req *request, target, value string) {
+	threshold, err := strconv.ParseFloat(value, 64)
+	if target == "" || err != nil || threshold < minSpikeThreshold || threshold > maxSpikeThreshold {
+		req.Error(req.T("alert.usage_spike", req.prefix, minSpikeThreshold, float64(maxSpikeThreshold)))
+		return
+	}
+	b.addAlert(ctx, req, models.AlertKindSpike, target, threshold)
+}
+
+// addEditWarAlert subscribes the channel to edit wars. The threshold is the
+// number of reverts, the least that counts as a war by default.
+func (b *Bot) addEditWarAlert(ctx context.Context, req *request, target, value string) {
+	reverts := alert.MinWarReverts
+	var err error
+	if value != "" {
+		reverts, err = strconv.Atoi(value)
+	}
+	if target == "" || err != nil || reverts < alert.MinWarReverts || reverts > alert.MaxWarReverts {
+		req.Error(req.T("alert.usage_editwar", req.prefix, alert.MinWarReverts, alert.MaxWarReverts))
+		return
+	}
+	b.addAlert(ctx, req, models.AlertKindEditWar, target, float64(reverts))
+}
+
+func (b *Bot) addAlert(ctx context.Context, req *request, kind, target string, threshold float64) {
+	existing, err := b.store.Alert.ListByChannel(ctx, req.ChannelID)
+	if err != nil {
+		req.Error(req.T("alert.error", err))
+		return
+	}
+	if len(existing) >= maxAlertsPerChannel {
+		req.Error(req.N("alert.too_many", len(existing)))
+		return
+	}
+
+	lang, title := b.alertTarget(ctx, req, target)
+	a := &models.Alert{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		CreatedBy: req.UserID,
+		Kind:      kind,
+		Lang:      lang,
+		Target:    title,
+		Threshold: threshold,
+	}
+	if err := b.store.Alert.Save(ctx, a); err != nil {
+		req.Error(req.T("alert.save_failed", err))
+		return
+	}
+	b.alerts.Add(a)
+	req.Reply(req.T("alert.added", describeAlert(req.loc, a)))
+}
+
+// alertTarget reads an alert's target: a language code for a whole
+// wiki, a page title as "language:title", or a bare title on the wiki in
+// the requester's language.
+func (b *Bot) alertTarget(ctx context.Context, req *request, target string) (lang, title string) {
+	matrix := sitematrix.Default()
+	if code := sitematrix.Normalize(target); matrix.Valid(code) {
+		return code, ""
+	}
+	if prefix, rest, ok := strings.Cut(target, ":"); ok && strings.TrimSpace(rest) != "" {
+		if code := sitematrix.Normalize(prefix); matrix.Valid(code) {
+			return code, watch.NormalizeTitle(rest)
+		}
+	}
+	lang, _ = b.resolveLang(ctx, req)
+	return lang, watch.NormalizeTitle(target)
+}
+
+func (b *Bot) listAlerts(ctx context.Context, req *request) {
+	alerts, err := b.store.Alert.ListByChannel(ctx, req.ChannelID)
+	if err != nil {
+		req.Error(req.T("alert.error", err))
+		return
+	}
+	if len(alerts) == 0 {
+		req.Reply(req.T("alert.none", req.prefix))
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(req.T("alert.header") + "\n")
+	for _, a := range alerts {
+		sb.WriteString("• " + describeAlert(req.loc, a) + "\n")
+	}
+	req.Reply(sb.String())
+}
+
+func (b *Bot) removeAlert(ctx context.Context, req *request, value string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(value, "#"), 10, 64)
+	if err != nil {
+		req.Error(req.T("alert.usage_remove", req.prefix))
+		return
+	}
+	if err := b.store.Alert.Delete(ctx, req.ChannelID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			req.Error(req.T("alert.missing", id))
+			return
+		}
+		req.Error(req.T("alert.remove_failed", err))
+		return
+	}
+	b.alerts.Remove(req.ChannelID, id)
+	req.Reply(req.T("alert.removed", id))
+}
+
+func describeAlert(loc i18n.Localizer, a *models.Alert) string {
+	if a.Target == "" {
+		return loc.T("alert."+a.Kind+"_wiki", a.ID, a.Lang, a.Threshold)
+	}
+	return loc.T("alert."+a.Kind+"_page", a.ID, a.Target, a.Lang, a.Threshold)
+}
+
+// editWars lists the pages in an edit war on lang right now.
+func (b *Bot) editWars(ctx context.Context, req *request, lang string) {
+	if lang == "" {
+		lang, _ = b.resolveLang(ctx, req)
+	}
+	wars := b.alerts.EditWars(lang)
+	if len(wars) == 0 {
+		req.Reply(req.T("editwars.none", lang))
+		return
+	}
+
+	host := wikilink.Host(lang, "")
+	var sb strings.Builder
+	for _, w := range wars {
+		users := req.N("editwars.users", w.Users)
+		sb.WriteString(req.N("editwars.line", w.Reverts, w.Title, wikilink.Page(host, w.Title), users, w.Last.Unix()) + "\n")
+	}
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{{
+		Title:       req.T("editwars.title", lang),
+		Description: sb.String(),
+		Color:       colorEditWar,
+	}})
+}
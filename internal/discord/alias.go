@@ -0,0 +1,73 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (b *Bot) handleAlias(ctx context.Context, req *request, action, name, expansion string) {
+	switch action {
+	case "list":
+		gs, err := b.settings.Get(ctx, req.GuildID)
+		if err != nil {
+			req.Error(req.T("alias.error", err))
+			return
+		}
+		if len(gs.Aliases) == 0 {
+			req.Reply(req.T("alias.none"))
+			return
+		}
+		names := make([]string, 0, len(gs.Aliases))
+		for n := range gs.Aliases {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		var sb strings.Builder
+		sb.WriteString(req.T("alias.header") + "\n")
+		for _, n := range names {
+			sb.WriteString(fmt.Sprintf("`%s%s` → `%s%s`\n", req.prefix, n, req.prefix, gs.Aliases[n]))
+		}
+		req.Reply(sb.String())
+
+	case "add":
+		if name == "" || expansion == "" {
+			req.Error(req.T("alias.usage_add", req.prefix))
+			return
+		}
+		name = strings.TrimPrefix(name, req.prefix)
+		if _, builtin := b.commands.lookup(name); builtin {
+			req.Error(req.T("alias.is_command", name))
+			return
+		}
+		expansion = strings.TrimPrefix(expansion, req.prefix)
+		target := tokenize(expansion)
+		if len(target) == 0 {
+			req.Error(req.T("alias.usage_add", req.prefix))
+			return
+		}
+		if _, ok := b.commands.lookup(target[0]); !ok {
+			req.Error(req.T("error.unknown_command", target[0], req.prefix))
+			return
+		}
+		if _, err := b.settings.SetAlias(ctx, req.GuildID, name, expansion); err != nil {
+			req.Error(req.T("alias.update_failed", err))
+			return
+		}
+		req.Reply(req.T("alias.added", req.prefix, strings.ToLower(name), req.prefix, expansion))
+
+	case "remove":
+		if name == "" {
+			req.Error(req.T("alias.usage_remove", req.prefix))
+			return
+		}
+		name = strings.TrimPrefix(name, req.prefix)
+		if _, err := b.settings.RemoveAlias(ctx, req.GuildID, name); err != nil {
+			req.Error(req.T("alias.update_failed", err))
+			return
+		}
+		req.Reply(req.T("alias.removed", req.prefix, strings.ToLower(name)))
+	}
+}
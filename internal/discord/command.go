@@ -0,0 +1,316 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+)
+
+type argType int
+
+const (
+	argString argType = iota
+	argInt
+	argLang
+	argDate
+	// argRest consumes all remaining tokens and must be the last argument.
+	argRest
+)
+
+var (
+	errArgMismatch = errors.New("argument does not match")
+	langTokenRe    = regexp.MustCompile(`^[A-Za-z][A-Za-z-]*$`)
+)
+
+// argSpec describes one positional argument. Optional arguments whose type
+// does not match the token are skipped, so "!recent 5" fills the limit and
+// leaves the language unset.
+type argSpec struct {
+	Name        string
+	Type        argType
+	Required    bool
+	Description string
+	// Choices restricts string arguments to fixed values. For other types
+	// they are accepted in addition to the typed values (e.g. "reset").
+	Choices []string
+}
+
+func (a argSpec) usage() string {
+	name := a.Name
+	if a.Type == argString && len(a.Choices) > 0 {
+		name = strings.Join(a.Choices, "|")
+	} else if len(a.Choices) > 0 {
+		name += "|" + strings.Join(a.Choices, "|")
+	}
+	if a.Type == argRest {
+		name += "..."
+	}
+	if a.Required {
+		return "<" + name + ">"
+	}
+	return "[" + name + "]"
+}
+
+// parse checks a single value. Values of the wrong shape return
+// errArgMismatch; values of the right shape that are still unusable return
+// an *argError with a message for the user.
+func (a argSpec) parse(value string) (string, error) {
+	for _, c := range a.Choices {
+		if strings.EqualFold(value, c) {
+			return c, nil
+		}
+	}
+
+	switch a.Type {
+	case argString:
+		if len(a.Choices) > 0 {
+			return "", errArgMismatch
+		}
+		return value, nil
+	case argInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return "", errArgMismatch
+		}
+		return value, nil
+	case argLang:
+		if !langTokenRe.MatchString(value) {
+			return "", errArgMismatch
+		}
+		code := sitematrix.Normalize(value)
+		if !sitematrix.Default().Valid(code) {
+			return "", unknownLangError(code)
+		}
+		return code, nil
+	case argDate:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "", errArgMismatch
+		}
+		return value, nil
+	}
+	return value, nil
+}
+
+func (a argSpec) mismatchError(value string) *argError {
+	switch a.Type {
+	case argInt:
+		return newArgError("args.not_number", value)
+	case argLang:
+		return newArgError("args.not_lang", value)
+	case argDate:
+		return newArgError("args.bad_date")
+	}
+	if len(a.Choices) > 0 {
+		return newArgError("args.not_choice", value, strings.Join(a.Choices, ", "))
+	}
+	return newArgError("args.invalid", value, a.Name)
+}
+
+// argError is a message for the user about their arguments. It holds a
+// catalog key so it can be shown in the requester's language.
+type argError struct {
+	key  string
+	args []any
+}
+
+func newArgError(key string, args ...any) *argError {
+	return &argError{key: key, args: args}
+}
+
+// Error returns the English message.
+func (e *argError) Error() string {
+	return e.localize(i18n.Localizer{})
+}
+
+func (e *argError) localize(loc i18n.Localizer) string {
+	return loc.T(e.key, e.args...)
+}
+
+// args holds parsed argument values by name.
+type args map[string]string
+
+func (a args) String(name string) string {
+	return a[name]
+}
+
+func (a args) Int(name string, fallback int) int {
+	v, err := strconv.Atoi(a[name])
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func (a args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+type commandHandler func(ctx context.Context, req *request, a args)
+
+type command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []argSpec
+	// Permission is the Discord permission bit the invoking member needs.
+	// Zero means anyone may run the command.
+	Permission int64
+	// GuildOnly commands are rejected in direct messages.
+	GuildOnly bool
+	// DMOnly commands are rejected in guilds.
+	DMOnly bool
+	// RateLimit overrides defaultRateLimits.
+	RateLimit *rateLimits
+	Handler   commandHandler
+}
+
+// availableIn reports whether the command can run where req was made.
+func (c *command) availableIn(req *request) bool {
+	if req.GuildID == "" {
+		return !c.GuildOnly
+	}
+	return !c.DMOnly
+}
+
+func (c *command) usage(prefix string) string {
+	parts := []string{prefix + c.Name}
+	for _, a := range c.Args {
+		parts = append(parts, a.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseArgs matches tokens against the command's argument schema.
+func (c *command) parseArgs(tokens []string) (args, error) {
+	parsed := make(args)
+	i := 0
+	for _, spec := range c.Args {
+		if spec.Type == argRest {
+			if i < len(tokens) {
+				parsed[spec.Name] = strings.Join(tokens[i:], " ")
+				i = len(tokens)
+			} else if spec.Required {
+				return nil, newArgError("args.missing", spec.Name)
+			}
+			continue
+		}
+		if i >= len(tokens) {
+			if spec.Required {
+				return nil, newArgError("args.missing", spec.Name)
+			}
+			continue
+		}
+
+		v, err := spec.parse(tokens[i])
+		if errors.Is(err, errArgMismatch) {
+			if !spec.Required {
+				continue
+			}
+			return nil, spec.mismatchError(tokens[i])
+		} else if err != nil {
+			return nil, err
+		}
+		parsed[spec.Name] = v
+		i++
+	}
+	if i < len(tokens) {
+		return nil, newArgError("args.too_many")
+	}
+	return parsed, nil
+}
+
+// parseNamedArgs validates already named values, as delivered by slash
+// command options.
+func (c *command) parseNamedArgs(values map[string]string) (args, error) {
+	parsed := make(args)
+	for _, spec := range c.Args {
+		value, ok := values[spec.Name]
+		if !ok || value == "" {
+			if spec.Required {
+				return nil, newArgError("args.missing", spec.Name)
+			}
+			continue
+		}
+		if spec.Type == argRest {
+			parsed[spec.Name] = value
+			continue
+		}
+		v, err := spec.parse(value)
+		if errors.Is(err, errArgMismatch) {
+			return nil, spec.mismatchError(value)
+		} else if err != nil {
+			return nil, err
+		}
+		parsed[spec.Name] = v
+	}
+	return parsed, nil
+}
+
+// registry resolves command names and aliases case-insensitively.
+type registry struct {
+	commands []*command
+	byName   map[string]*command
+}
+
+func newRegistry(commands []*command) *registry {
+	r := &registry{byName: make(map[string]*command)}
+	for _, c := range commands {
+		r.register(c)
+	}
+	return r
+}
+
+func (r *registry) register(c *command) {
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		key := strings.ToLower(name)
+		if _, dup := r.byName[key]; dup {
+			panic(fmt.Sprintf("discord: command name %q registered twice", name))
+		}
+		r.byName[key] = c
+	}
+	r.commands = append(r.commands, c)
+	sort.Slice(r.commands, func(i, j int) bool {
+		return r.commands[i].Name < r.commands[j].Name
+	})
+}
+
+func (r *registry) lookup(name string) (*command, bool) {
+	c, ok := r.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// tokenize splits a message into fields, keeping double-quoted phrases
+// together: `watch en "Main Page"` has three tokens.
+func tokenize(content string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes, hasToken := false, false
+	for _, r := range content {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasToken = true
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\n'):
+			if hasToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				hasToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			hasToken = true
+		}
+	}
+	if hasToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
@@ -0,0 +1,353 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+// builtinCommands returns every command the bot understands. The registry,
+// !help and the slash command handlers are all driven by this list.
+func (b *Bot) builtinCommands() []*command {
+	return []*command{
+		{
+			Name:        "setLang",
+			Description: "Set your own language preference.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Required: true, Choices: []string{"reset"}, Description: "Language code, or reset to clear it."},
+			},
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.setLang(ctx, req, langScopeUser, a.String("language"))
+			},
+		},
+		{
+			Name:        "setChannelLang",
+			Description: "Set the default language for this channel.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Required: true, Choices: []string{"reset"}, Description: "Language code, or reset to clear it."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.setLang(ctx, req, langScopeChannel, a.String("language"))
+			},
+		},
+		{
+			Name:        "setGuildLang",
+			Aliases:     []string{"setServerLang"},
+			Description: "Set the default language for this server.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Required: true, Choices: []string{"reset"}, Description: "Language code, or reset to clear it."},
+			},
+			Permission: discordgo.PermissionManageServer,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.setLang(ctx, req, langScopeGuild, a.String("language"))
+			},
+		},
+		{
+			Name:        "lang",
+			Description: "Show the language that applies to you here and where it comes from.",
+			Handler: func(ctx context.Context, req *request, a args) {
+				lang, source := b.resolveLang(ctx, req)
+				req.Reply(req.T("lang.current", lang, req.T("lang.source."+string(source))))
+			},
+		},
+		{
+			Name:        "languages",
+			Aliases:     []string{"langs"},
+			Description: "List supported language codes, optionally only those with a given project.",
+			Args: []argSpec{
+				{Name: "project", Type: argString, Description: "Project code such as wiki or wiktionary."},
+			},
+			Handler: func(ctx context.Context, req *request, a args) {
+				sendLanguages(req, a.String("project"))
+			},
+		},
+		{
+			Name:        "recent",
+			Description: "Show recent changes.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Description: "Defaults to your language."},
+				{Name: "limit", Type: argInt, Description: "Between 1 and 100, default 10."},
+			},
+			RateLimit: queryRateLimits,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.recent(ctx, req, a.String("language"), a.Int("limit", 10))
+			},
+		},
+		{
+			Name:        "stats",
+			Description: "Show the number of changes on a date or over a range of dates.",
+			Args: []argSpec{
+				{Name: "date", Type: argDate, Required: true, Description: "Date as yyyy-mm-dd, in UTC."},
+				{Name: "to", Type: argDate, Description: "Last date of a range, at most 92 days."},
+				{Name: "language", Type: argLang, Description: "Defaults to your language."},
+				{Name: "chart", Type: argString, Choices: []string{"chart"}, Description: "Attach a line chart of a range."},
+			},
+			RateLimit: queryRateLimits,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.stats(ctx, req, a.String("date"), a.String("to"), a.String("language"), a.Has("chart"))
+			},
+		},
+		{
+			Name:        "top",
+			Description: "Show the most changed articles on a date or over a range of dates.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Description: "Defaults to your language."},
+				{Name: "date", Type: argDate, Description: "Date as yyyy-mm-dd, in UTC; default today."},
+				{Name: "to", Type: argDate, Description: "Last date of a range, at most 92 days."},
+				{Name: "chart", Type: argString, Choices: []string{"chart"}, Description: "Attach a bar chart."},
+			},
+			RateLimit: queryRateLimits,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.top(ctx, req, a.String("language"), a.String("date"), a.String("to"), a.Has("chart"))
+			},
+		},
+		{
+			Name:        "watch",
+			Description: "Post to this channel, or to you in direct messages, every time a page is changed.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Required: true},
+				{Name: "title", Type: argRest, Required: true, Description: "Page title; quotes are optional."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.addWatch(ctx, req, models.WatchKindPage, a.String("language"), a.String("title"))
+			},
+		},
+		{
+			Name:        "unwatch",
+			Description: "Stop watching a page in this channel.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Required: true},
+				{Name: "title", Type: argRest, Required: true},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.removeWatch(ctx, req, models.WatchKindPage, a.String("language"), a.String("title"))
+			},
+		},
+		{
+			Name:        "watchuser",
+			Description: "Post to this channel, or to you in direct messages, every time an editor makes a change.",
+			Args: []argSpec{
+				{Name: "username", Type: argString, Required: true, Description: "Quote names that contain spaces."},
+				{Name: "language", Type: argLang, Choices: []string{"all"}, Description: "Only match one wiki; default all."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.addWatch(ctx, req, models.WatchKindUser, a.String("language"), a.String("username"))
+			},
+		},
+		{
+			Name:        "unwatchuser",
+			Description: "Stop watching an editor in this channel.",
+			Args: []argSpec{
+				{Name: "username", Type: argString, Required: true},
+				{Name: "language", Type: argLang, Choices: []string{"all"}},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.removeWatch(ctx, req, models.WatchKindUser, a.String("language"), a.String("username"))
+			},
+		},
+		{
+			Name:        "watchlist",
+			Description: "List the pages and editors watched in this channel.",
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.watchlist(ctx, req)
+			},
+		},
+		{
+			Name:        "feed",
+			Description: "Stream matching edits to this channel as they happen.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"start", "stop", "status"}},
+				{Name: "language", Type: argLang, Description: "Language to stream, for start."},
+				{Name: "filters", Type: argRest, Description: "Conditions such as type=edit minor=false user!=SomeBot title~Election."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handleFeed(ctx, req, a.String("action"), a.String("language"), a.String("filters"))
+			},
+		},
+		{
+			Name:        "alert",
+			Aliases:     []string{"alerts"},
+			Description: "Alert this channel when edit activity on a wiki or page jumps above its usual rate, or when a page turns into an edit war.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"spike", "editwar", "list", "remove"}},
+				{Name: "target", Type: argString, Description: "Language code or page title for spike and editwar; quote titles with spaces. The alert ID for remove."},
+				{Name: "threshold", Type: argString, Description: "For spike, how many times the usual rate, e.g. 3; for editwar, how many reverts, 3 by default."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handleAlert(ctx, req, a.String("action"), a.String("target"), a.String("threshold"))
+			},
+		},
+		{
+			Name:        "digest",
+			Description: "Post a daily or weekly summary of edits to this channel.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"subscribe", "unsubscribe", "preview"}},
+				{Name: "schedule", Type: argRest, Description: "For subscribe: daily or weekly, a time as hh:mm and language codes, e.g. daily 09:00 en de."},
+			},
+			Permission: discordgo.PermissionManageChannels,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handleDigest(ctx, req, a.String("action"), a.String("schedule"))
+			},
+		},
+		{
+			Name:        "jobs",
+			Description: "Show the bot's background jobs and how their last runs went.",
+			Permission:  discordgo.PermissionAdministrator,
+			GuildOnly:   true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.listJobs(req)
+			},
+		},
+		{
+			Name:        "editwars",
+			Description: "List the pages where edits are being reverted back and forth.",
+			Args: []argSpec{
+				{Name: "language", Type: argLang, Description: "Defaults to your language."},
+			},
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.editWars(ctx, req, a.String("language"))
+			},
+		},
+		{
+			Name:        "config",
+			Description: "Show or change server settings.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"show", "set", "reset"}},
+				{Name: "key", Type: argString, Description: "Setting to change or reset."},
+				{Name: "value", Type: argRest, Description: "New value for set."},
+			},
+			Permission: discordgo.PermissionManageServer,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handleConfig(ctx, req, a.String("action"), a.String("key"), a.String("value"))
+			},
+		},
+		{
+			Name:        "mysettings",
+			Aliases:     []string{"me"},
+			Description: "Show or change your personal settings.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"show", "set", "reset"}},
+				{Name: "key", Type: argString, Description: "Setting to change or reset."},
+				{Name: "value", Type: argRest, Description: "New value for set."},
+			},
+			DMOnly: true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handlePersonal(ctx, req, a.String("action"), a.String("key"), a.String("value"))
+			},
+		},
+		{
+			Name:        "alias",
+			Description: "Manage custom command aliases for this server.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"add", "remove", "list"}},
+				{Name: "name", Type: argString, Description: "Alias name, without the prefix."},
+				{Name: "command", Type: argRest, Description: "Command line the alias expands to, e.g. recent en 20."},
+			},
+			Permission: discordgo.PermissionManageServer,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handleAlias(ctx, req, a.String("action"), a.String("name"), a.String("command"))
+			},
+		},
+		{
+			Name:        "perms",
+			Aliases:     []string{"permissions"},
+			Description: "Grant roles access to commands, or list the grants on this server.",
+			Args: []argSpec{
+				{Name: "action", Type: argString, Required: true, Choices: []string{"grant", "revoke", "list"}},
+				{Name: "command", Type: argString, Description: "Command name, without the prefix."},
+				{Name: "role", Type: argString, Description: "Role mention or ID."},
+			},
+			Permission: discordgo.PermissionManageServer,
+			GuildOnly:  true,
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.handlePerms(ctx, req, a.String("action"), a.String("command"), a.String("role"))
+			},
+		},
+		{
+			Name:        "help",
+			Aliases:     []string{"commands"},
+			Description: "List commands, or show details for one command.",
+			Args: []argSpec{
+				{Name: "command", Type: argString},
+			},
+			Handler: func(ctx context.Context, req *request, a args) {
+				b.help(req, a.String("command"))
+			},
+		},
+	}
+}
+
+func (b *Bot) help(req *request, name string) {
+	if name != "" {
+		cmd, ok := b.commands.lookup(strings.TrimPrefix(name, req.prefix))
+		if !ok {
+			req.Error(req.T("error.unknown_command", name, req.prefix))
+			return
+		}
+		req.Reply(commandHelp(req, cmd))
+		return
+	}
+
+	lines := []string{req.T("help.header") + "\n"}
+	for _, cmd := range b.commands.commands {
+		if !cmd.availableIn(req) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("`%s` %s\n", cmd.usage(req.prefix), cmd.Description))
+	}
+	lines = append(lines, req.T("help.footer", req.prefix))
+
+	// The list outgrows one message, so it is split between lines.
+	var sb strings.Builder
+	for _, line := range lines {
+		if sb.Len()+len(line) > 2000 {
+			req.Reply(sb.String())
+			sb.Reset()
+		}
+		sb.WriteString(line)
+	}
+	req.Reply(sb.String())
+}
+
+// commandHelp describes one command. Descriptions are written in English;
+// the surrounding text follows the requester's language.
+func commandHelp(req *request, cmd *command) string {
+	prefix := req.prefix
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("`%s`\n%s\n", cmd.usage(prefix), cmd.Description))
+	for _, a := range cmd.Args {
+		if a.Description != "" {
+			sb.WriteString(fmt.Sprintf("• `%s` %s\n", a.Name, a.Description))
+		}
+	}
+	if len(cmd.Aliases) > 0 {
+		aliases := make([]string, len(cmd.Aliases))
+		for i, alias := range cmd.Aliases {
+			aliases[i] = prefix + alias
+		}
+		sb.WriteString(req.T("help.aliases", strings.Join(aliases, ", ")) + "\n")
+	}
+	if cmd.GuildOnly {
+		sb.WriteString(req.T("help.guild_only") + "\n")
+	}
+	if cmd.DMOnly {
+		sb.WriteString(req.T("help.dm_only") + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
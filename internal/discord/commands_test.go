@@ -0,0 +1,615 @@
+package discord
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/scheduler"
+	"github.com/vlkhvnn/TestON/internal/shard"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"go.uber.org/zap"
+)
+
+func newTestBot(t *testing.T) (*Bot, store.Storage) {
+	t.Helper()
+	mockStorage := store.Storage{
+		Event:        &store.MockEventStore{},
+		Lang:         &store.MockLangStore{},
+		Stat:         &store.MockStatStore{Stats: map[string]int{"de_2025-02-04": 7}},
+		Settings:     &store.MockSettingsStore{},
+		UserSettings: &store.MockUserSettingsStore{},
+		Watch:        &store.MockWatchStore{},
+		Feed:         &store.MockFeedStore{},
+		Alert:        &store.MockAlertStore{},
+		Digest:       &store.MockDigestStore{},
+		Job:          &store.MockJobStore{},
+	}
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+	// Tests send commands much faster than people do; rate limits have
+	// their own tests.
+	b.throttle = nil
+	return b, mockStorage
+}
+
+func sendCommand(b *Bot, content string) []string {
+	ms := &MockSession{}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   content,
+			ChannelID: "channel1",
+			Author:    &discordgo.User{ID: "user1"},
+			GuildID:   "guild1",
+		},
+	})
+	return ms.messages
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"watch", "en", "Main Page"}, tokenize(`watch en "Main Page"`))
+	assert.Equal(t, []string{"recent", "5"}, tokenize("  recent   5 "))
+	assert.Equal(t, []string{"a", ""}, tokenize(`a ""`))
+	assert.Empty(t, tokenize("   "))
+}
+
+func TestParseArgsSkipsOptionalMismatches(t *testing.T) {
+	b, _ := newTestBot(t)
+	recent, ok := b.commands.lookup("recent")
+	require.True(t, ok)
+
+	a, err := recent.parseArgs([]string{"5"})
+	require.NoError(t, err)
+	assert.False(t, a.Has("language"))
+	assert.Equal(t, 5, a.Int("limit", 10))
+
+	a, err = recent.parseArgs([]string{"RU", "20"})
+	require.NoError(t, err)
+	assert.Equal(t, "ru", a.String("language"))
+	assert.Equal(t, 20, a.Int("limit", 10))
+
+	_, err = recent.parseArgs([]string{"en", "20", "extra"})
+	assert.EqualError(t, err, "Too many arguments.")
+}
+
+func TestRegistryLookup(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	cmd, ok := b.commands.lookup("SETLANG")
+	require.True(t, ok)
+	assert.Equal(t, "setLang", cmd.Name)
+
+	cmd, ok = b.commands.lookup("setServerLang")
+	require.True(t, ok)
+	assert.Equal(t, "setGuildLang", cmd.Name)
+
+	_, ok = b.commands.lookup("nope")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		newRegistry([]*command{{Name: "a"}, {Name: "b", Aliases: []string{"A"}}})
+	})
+}
+
+func TestUnknownCommandIsIgnored(t *testing.T) {
+	b, _ := newTestBot(t)
+	assert.Empty(t, sendCommand(b, "!play despacito"))
+	assert.Empty(t, sendCommand(b, "hello !recent"))
+}
+
+func TestHelpCommand(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendCommand(b, "!help")
+	require.NotEmpty(t, reply)
+	all := strings.Join(reply, "")
+	for _, usage := range []string{
+		"`!recent [language] [limit]`",
+		"`!stats <date> [to] [language] [chart]`",
+		"`!setLang <language|reset>`",
+		"`!config <show|set|reset> [key] [value...]`",
+		"`!jobs`",
+	} {
+		assert.Contains(t, all, usage)
+	}
+
+	reply = sendCommand(b, "!help setGuildLang")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Aliases: !setServerLang")
+	assert.Contains(t, reply[0], "Only available in servers.")
+
+	reply = sendCommand(b, "!commands !recent")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "`limit` Between 1 and 100")
+
+	reply = sendCommand(b, "!help nope")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Unknown command 'nope'")
+}
+
+func TestHelpFitsInMessages(t *testing.T) {
+	b, _ := newTestBot(t)
+	sendCommand(b, "!config set prefix !!!!!")
+
+	reply := sendCommand(b, "!!!!!help")
+	require.Greater(t, len(reply), 1)
+	for _, msg := range reply {
+		assert.LessOrEqual(t, utf8.RuneCountInString(msg), 2000)
+	}
+	all := strings.Join(reply, "")
+	for _, cmd := range b.commands.commands {
+		if !cmd.DMOnly {
+			assert.Contains(t, all, "`!!!!!"+cmd.Name, "every command is listed")
+		}
+	}
+	assert.True(t, strings.HasSuffix(all, "!!!!!help [command]` for details."), "the footer comes last")
+}
+
+func TestRecentCommand(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendCommand(b, "!recent de")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "No recent changes for language: de", reply[0])
+
+	reply = sendCommand(b, "!recent de many")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Too many arguments.\nUsage: !recent [language] [limit]")
+}
+
+func TestStatsCommandUsage(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendCommand(b, "!stats")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Missing date.\nUsage: !stats <date> [to] [language] [chart]", reply[0])
+
+	reply = sendCommand(b, "!stats 04-02-2025")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Invalid date format")
+
+	ms := &MockSession{}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   "!stats 2025-02-04 de",
+			ChannelID: "channel1",
+			Author:    &discordgo.User{ID: "user1"},
+			GuildID:   "guild1",
+		},
+	})
+	require.Len(t, ms.embeds, 1)
+	embed := ms.embeds[0][0]
+	assert.Equal(t, "On 2025-02-04, there were 7 changes for language 'de'.", embed.Description)
+	assert.Equal(t, "7", embed.Fields[1].Value)
+}
+
+func TestSetLangCommands(t *testing.T) {
+	b, storage := newTestBot(t)
+	langs := storage.Lang.(*store.MockLangStore)
+
+	reply := sendCommand(b, "!setLang")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Usage: !setLang <language|reset>")
+
+	reply = sendCommand(b, "!setlang FR")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "fr", langs.Langs["user1"])
+
+	reply = sendCommand(b, "!setLang RESET")
+	require.Len(t, reply, 1)
+	assert.Empty(t, langs.Langs)
+
+	ms := &MockSession{}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   "!setChannelLang de",
+			ChannelID: "dm1",
+			Author:    &discordgo.User{ID: "user1"},
+		},
+	})
+	require.Len(t, ms.messages, 1)
+	assert.Contains(t, ms.messages[0], "only be used in a server")
+}
+
+func TestConfigCommandUsage(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendCommand(b, "!config")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Missing action.")
+
+	reply = sendCommand(b, "!config delete")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "'delete' is not one of: show, set, reset.")
+
+	reply = sendCommand(b, "!config set prefix")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Usage: !config set [key] [value]")
+}
+
+func TestGuildPrefixAndMention(t *testing.T) {
+	b, _ := newTestBot(t)
+	b.userID = "bot1"
+
+	reply := sendCommand(b, "!config set prefix ?")
+	require.Len(t, reply, 1)
+
+	assert.Empty(t, sendCommand(b, "!lang"), "the default prefix no longer applies")
+
+	reply = sendCommand(b, "?lang")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Current language")
+
+	reply = sendCommand(b, "<@bot1> lang")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Current language")
+
+	reply = sendCommand(b, "<@!bot1> stats")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Usage: ?stats <date> [to] [language] [chart]")
+
+	assert.Empty(t, sendCommand(b, "<@someone> lang"))
+}
+
+func TestCustomAliases(t *testing.T) {
+	b, storage := newTestBot(t)
+
+	reply := sendCommand(b, "!alias add rc recent de")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "`!rc` now runs `!recent de`.", reply[0])
+	assert.Equal(t, "recent de", storage.Settings.(*store.MockSettingsStore).Settings["guild1"].Aliases["rc"])
+
+	reply = sendCommand(b, "!RC 5")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "No recent changes for language: de", reply[0])
+
+	reply = sendCommand(b, "!alias add stats recent")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "'stats' is already a command")
+
+	reply = sendCommand(b, "!alias add x play music")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Unknown command 'play'")
+
+	reply = sendCommand(b, "!alias list")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "`!rc` → `!recent de`")
+
+	reply = sendCommand(b, "!alias remove rc")
+	require.Len(t, reply, 1)
+	assert.Empty(t, sendCommand(b, "!rc"))
+
+	reply = sendCommand(b, "!alias remove rc")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "unknown alias")
+}
+
+func TestWatchCommands(t *testing.T) {
+	b, storage := newTestBot(t)
+
+	reply := sendCommand(b, `!watch en "main Page"`)
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Watching 'Main Page' (en) in this channel.", reply[0])
+	require.Len(t, storage.Watch.(*store.MockWatchStore).Watches, 1)
+
+	matched := b.Watches().Match("en", &models.RecentChangeEvent{Title: "Main Page"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, "channel1", matched[0].ChannelID)
+
+	reply = sendCommand(b, "!watch en Main Page")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "already watches")
+
+	reply = sendCommand(b, "!watch en")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Missing title.")
+
+	reply = sendCommand(b, "!watchlist")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "• 'Main Page' (en)")
+
+	reply = sendCommand(b, "!unwatch en Main_Page")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Stopped watching 'Main Page' (en).", reply[0])
+	assert.Empty(t, b.Watches().Match("en", &models.RecentChangeEvent{Title: "Main Page"}))
+
+	reply = sendCommand(b, "!unwatch en Main Page")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "does not watch")
+}
+
+func TestWatchUserCommands(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendCommand(b, `!watchuser "some editor"`)
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Watching user 'Some editor' (all wikis) in this channel.", reply[0])
+
+	reply = sendCommand(b, "!watchuser Vandal de")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Watching user 'Vandal' (de) in this channel.", reply[0])
+
+	assert.Len(t, b.Watches().Match("commons", &models.RecentChangeEvent{User: "Some editor"}), 1)
+	assert.Len(t, b.Watches().Match("de", &models.RecentChangeEvent{User: "Vandal"}), 1)
+	assert.Empty(t, b.Watches().Match("en", &models.RecentChangeEvent{User: "Vandal"}))
+
+	reply = sendCommand(b, "!watchlist")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "• user 'Vandal' (de)")
+
+	reply = sendCommand(b, `!unwatchuser "Some editor" all`)
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Stopped watching user 'Some editor' (all wikis).", reply[0])
+	assert.Empty(t, b.Watches().Match("en", &models.RecentChangeEvent{User: "Some editor"}))
+}
+
+func TestFeedCommands(t *testing.T) {
+	b, storage := newTestBot(t)
+
+	reply := sendCommand(b, "!feed start en type=edit user!=Some Bot")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Streaming edits on en to this channel, filters: type=edit user!=Some Bot.", reply[0])
+	saved := storage.Feed.(*store.MockFeedStore).Feeds["channel1"]
+	require.NotNil(t, saved)
+	assert.Equal(t, "type=edit user!=Some Bot", saved.Filters)
+
+	b.Feeds().Handle("en", &models.RecentChangeEvent{ID: "1", Type: "edit", Title: "Paris", User: "Alice"})
+	b.Feeds().Handle("en", &models.RecentChangeEvent{ID: "2", Type: "edit", Title: "Rome", User: "Some Bot"})
+	assert.Equal(t, 1, b.Feeds().Pending("channel1"))
+
+	reply = sendCommand(b, "!feed status")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Live feed of en in this channel, filters: type=edit user!=Some Bot, 1 edit waiting.", reply[0])
+
+	reply = sendCommand(b, "!feed start en size>10")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], `Invalid filter: unknown key "size"`)
+
+	reply = sendCommand(b, "!feed start")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Usage: !feed start <language> [filters...]", reply[0])
+
+	reply = sendCommand(b, "!feed stop")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Stopped the live feed in this channel.", reply[0])
+	_, ok := b.Feeds().Get("channel1")
+	assert.False(t, ok)
+
+	reply = sendCommand(b, "!feed stop")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "No live feed runs in this channel.", reply[0])
+}
+
+func TestFeedRespectsFeedChannels(t *testing.T) {
+	b, _ := newTestBot(t)
+	setFeedChannels := func(ids ...string) {
+		_, err := b.settings.Update(context.Background(), "guild1", func(gs *models.GuildSettings) error {
+			gs.FeedChannels = ids
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	setFeedChannels("123")
+	reply := sendCommand(b, "!feed start en")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "not allowed in this channel")
+
+	setFeedChannels("123", "channel1")
+	reply = sendCommand(b, "!feed start en")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Streaming edits on en to this channel, no filters.", reply[0])
+}
+
+func sendDM(b *Bot, content string) []string {
+	ms := &MockSession{}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   content,
+			ChannelID: "dm1",
+			Author:    &discordgo.User{ID: "user1"},
+		},
+	})
+	return ms.messages
+}
+
+func TestDirectMessageCommands(t *testing.T) {
+	b, storage := newTestBot(t)
+
+	help := sendDM(b, "!help")
+	require.Len(t, help, 1)
+	assert.Contains(t, help[0], "`!mysettings <show|set|reset> [key] [value...]`")
+	assert.Contains(t, help[0], "`!watch <language> <title...>`")
+	assert.NotContains(t, help[0], "`!config")
+	assert.NotContains(t, sendCommand(b, "!help")[0], "`!mysettings")
+
+	assert.Equal(t, []string{"This command can only be used in a server."}, sendDM(b, "!config show"))
+	assert.Equal(t, []string{"This command can only be used in direct messages with the bot."}, sendCommand(b, "!me show"))
+
+	reply := sendDM(b, `!watch en "Main Page"`)
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Watching 'Main Page' (en)")
+	watches, err := storage.Watch.ListByChannel(context.Background(), "dm1")
+	require.NoError(t, err)
+	require.Len(t, watches, 1)
+	assert.Empty(t, watches[0].GuildID)
+	assert.Equal(t, "user1", watches[0].CreatedBy)
+}
+
+func TestPersonalSettings(t *testing.T) {
+	b, storage := newTestBot(t)
+
+	reply := sendDM(b, "!mysettings set timezone Asia/Tokyo")
+	assert.Equal(t, []string{"Set **timezone** to Asia/Tokyo."}, reply)
+	reply = sendDM(b, "!mysettings set stats_days local")
+	assert.Equal(t, []string{"Set **stats_days** to local."}, reply)
+	us, err := storage.UserSettings.Get(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", us.Timezone)
+
+	reply = sendDM(b, "!mysettings show")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "**timezone**: Asia/Tokyo")
+	assert.Contains(t, reply[0], "**stats_days**: local")
+
+	// Personal settings apply in direct messages only.
+	dm := &request{UserID: "user1"}
+	assert.Equal(t, "Asia/Tokyo", b.requestZone(context.Background(), dm).String())
+	assert.Equal(t, "Asia/Tokyo", b.statsZone(context.Background(), dm).String())
+	guild := &request{GuildID: "guild1", UserID: "user1"}
+	assert.Equal(t, time.UTC, b.requestZone(context.Background(), guild))
+
+	reply = sendDM(b, "!mysettings set prefix ?")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Keys: timezone, stats_days")
+
+	reply = sendDM(b, "!mysettings reset")
+	assert.Equal(t, []string{"Your personal settings have been reset to defaults."}, reply)
+	assert.Equal(t, time.UTC, b.requestZone(context.Background(), dm))
+}
+
+func TestAlertCommand(t *testing.T) {
+	b, storage := newTestBot(t)
+	require.NoError(t, storage.Lang.SetUserLang(context.Background(), "user1", "de"))
+
+	assert.Equal(t, []string{"Alerting this channel: #1 edit spike on en at 3× the usual rate."}, sendCommand(b, "!alert spike en 3"))
+	assert.Equal(t, []string{"Alerting this channel: #2 edit spike on 'Berlin' (de) at 4.5× the usual rate."}, sendCommand(b, "!alert spike berlin 4.5"))
+	assert.Equal(t, []string{"Alerting this channel: #3 edit spike on 'Main Page' (fr) at 10× the usual rate."}, sendCommand(b, `!alert spike "fr:Main Page" 10`))
+	// Subscribing again changes the threshold.
+	sendCommand(b, "!alert spike en 5")
+
+	reply := sendCommand(b, "!alert spike en 1")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Usage: !alert spike <language|title> <threshold>")
+	assert.Contains(t, sendCommand(b, "!alert spike en")[0], "Usage:")
+
+	reply = sendCommand(b, "!alerts list")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "• #1 edit spike on en at 5× the usual rate")
+	assert.Contains(t, reply[0], "• #2 edit spike on 'Berlin' (de)")
+	assert.Equal(t, 3, b.alerts.Len())
+
+	assert.Equal(t, []string{"Removed alert #2."}, sendCommand(b, "!alert remove #2"))
+	assert.Equal(t, []string{"This channel has no alert #2."}, sendCommand(b, "!alert remove 2"))
+	assert.Equal(t, 2, b.alerts.Len())
+}
+
+func TestDigestCommand(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx := context.Background()
+	require.NoError(t, storage.Lang.SetUserLang(ctx, "user1", "de"))
+
+	reply := sendCommand(b, "!digest subscribe daily 07:05 en fr")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "This channel gets a daily digest of en, fr at 07:05, next on <t:")
+	d, err := storage.Digest.Get(ctx, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "fr"}, d.Langs)
+	assert.Equal(t, 7, d.Hour)
+	assert.Equal(t, 5, d.Minute)
+	assert.True(t, d.NextRun.After(time.Now()))
+	assert.Equal(t, "guild1", d.GuildID)
+
+	// Subscribing again replaces the schedule; languages default to the
+	// requester's.
+	assert.Contains(t, sendCommand(b, "!digest subscribe weekly")[0], "weekly digest of de at 09:00")
+	d, err = storage.Digest.Get(ctx, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Monday, d.NextRun.Weekday())
+
+	assert.Contains(t, sendCommand(b, "!digest subscribe hourly")[0], "Usage: !digest subscribe <daily|weekly> [hh:mm] [languages]")
+	assert.Contains(t, sendCommand(b, "!digest subscribe daily 25:00")[0], "Usage:")
+	assert.Contains(t, sendCommand(b, "!digest subscribe daily en de fr es it ja")[0], "Usage:")
+	assert.Contains(t, sendCommand(b, "!digest subscribe daily xx")[0], "Unknown language code 'xx'")
+
+	msg := recentMessage(t, b, "!digest preview")
+	require.Len(t, msg.Embeds, 1)
+	assert.Contains(t, msg.Embeds[0].Title, "Weekly digest for")
+	assert.Equal(t, "de: 0 changes", msg.Embeds[0].Fields[0].Name)
+
+	assert.Equal(t, []string{"This channel no longer gets a digest."}, sendCommand(b, "!digest unsubscribe"))
+	assert.Equal(t, []string{"This channel has no digest. Subscribe with !digest subscribe daily."}, sendCommand(b, "!digest unsubscribe"))
+	msg = recentMessage(t, b, "!digest preview")
+	assert.Contains(t, msg.Embeds[0].Title, "Daily digest for")
+}
+
+func TestJobsCommand(t *testing.T) {
+	b, _ := newTestBot(t)
+	assert.Equal(t, []string{"No background jobs are scheduled."}, sendCommand(b, "!jobs"))
+
+	require.NoError(t, b.Jobs().Add(scheduler.Job{
+		Name:     "prune",
+		Schedule: scheduler.Every(time.Hour),
+		Run:      func(context.Context) error { return nil },
+	}))
+	msg := recentMessage(t, b, "!jobs")
+	require.Len(t, msg.Embeds, 1)
+	require.Len(t, msg.Embeds[0].Fields, 1)
+	field := msg.Embeds[0].Fields[0]
+	assert.Equal(t, "prune · @every 1h0m0s", field.Name)
+	assert.Contains(t, field.Value, "Next run <t:")
+	assert.Contains(t, field.Value, "Not run yet")
+}
+
+func TestJobsCommandShowsErrorsToOperators(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, storage.Job.SaveRun(ctx, &models.JobRun{
+		Name:     "prune",
+		LastRun:  time.Now().Add(-time.Minute),
+		Duration: time.Second,
+		Error:    "pq: " + strings.Repeat("x", 2000),
+	}))
+	require.NoError(t, b.Jobs().Add(scheduler.Job{
+		Name:     "prune",
+		Schedule: scheduler.Every(time.Hour),
+		Run:      func(context.Context) error { return nil },
+	}))
+	// Loads the failed run and returns, as ctx is done.
+	b.Jobs().Run(ctx, zap.NewNop().Sugar())
+
+	field := recentMessage(t, b, "!jobs").Embeds[0].Fields[0]
+	assert.Contains(t, field.Value, "failed after 1s; the error is in the bot's logs")
+	assert.NotContains(t, field.Value, "pq:")
+
+	b.SetOperators([]string{"user1"})
+	field = recentMessage(t, b, "!jobs").Embeds[0].Fields[0]
+	assert.Contains(t, field.Value, "failed after 1s: pq: xxx")
+	assert.LessOrEqual(t, len([]rune(field.Value)), 1024)
+}
+
+func TestEditWarCommands(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	assert.Equal(t, []string{"Alerting this channel: #1 edit wars on en from 3 reverts."}, sendCommand(b, "!alert editwar en"))
+	assert.Equal(t, []string{"Alerting this channel: #2 edit war on 'Berlin' (de) from 5 reverts."}, sendCommand(b, "!alert editwar de:Berlin 5"))
+	assert.Contains(t, sendCommand(b, "!alert editwar en 2")[0], "Usage: !alert editwar <language|title> [reverts]")
+	assert.Contains(t, sendCommand(b, "!alert editwar en 3.5")[0], "Usage:")
+	assert.Equal(t, 2, b.alerts.Len())
+
+	assert.Equal(t, []string{"No edit wars on en in the last hour."}, sendCommand(b, "!editwars en"))
+
+	now := time.Now().Add(-10 * time.Minute)
+	for i, user := range []string{"Alice", "Bob", "Alice", "Bob"} {
+		b.alerts.Handle("en", &models.RecentChangeEvent{
+			Type:       "edit",
+			Title:      "Contested",
+			User:       user,
+			Comment:    "Undid revision",
+			ServerName: "en.wikipedia.org",
+			Timestamp:  now.Add(time.Duration(i) * time.Minute).Unix(),
+		})
+	}
+	msg := recentMessage(t, b, "!editwars en")
+	require.Len(t, msg.Embeds, 1)
+	assert.Equal(t, "Edit wars on 'en' in the last hour", msg.Embeds[0].Title)
+	assert.Contains(t, msg.Embeds[0].Description,
+		"[Contested](https://en.wikipedia.org/wiki/Contested) — 4 reverts by 2 users, last <t:")
+}
@@ -0,0 +1,122 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vlkhvnn/TestON/internal/settings"
+)
+
+func (b *Bot) handleConfig(ctx context.Context, req *request, action, key, value string) {
+	switch action {
+	case "show":
+		gs, err := b.settings.Get(ctx, req.GuildID)
+		if err != nil {
+			req.Error(req.T("config.error", err))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString(req.T("config.header") + "\n")
+		for _, key := range settings.Keys {
+			value := settings.Value(gs, key)
+			if value == "" {
+				value = req.T("config.default")
+			}
+			sb.WriteString(fmt.Sprintf("**%s**: %s\n", key, value))
+		}
+		req.Reply(sb.String())
+
+	case "set":
+		if key == "" || value == "" {
+			req.Error(req.T("config.usage_set", req.prefix, strings.Join(settings.Keys, ", ")))
+			return
+		}
+		gs, err := b.settings.Set(ctx, req.GuildID, key, value)
+		if err != nil {
+			req.Error(configError(req, err))
+			return
+		}
+		req.Reply(req.T("config.set", key, settings.Value(gs, key)))
+
+	case "reset":
+		if key == "" {
+			if err := b.settings.Reset(ctx, req.GuildID); err != nil {
+				req.Error(configError(req, err))
+				return
+			}
+			req.Reply(req.T("config.reset_all"))
+			return
+		}
+		if _, err := b.settings.ResetKey(ctx, req.GuildID, key); err != nil {
+			req.Error(configError(req, err))
+			return
+		}
+		req.Reply(req.T("config.reset_key", key))
+	}
+}
+
+// handlePersonal is !config for the user's own settings, which apply in
+// direct messages.
+func (b *Bot) handlePersonal(ctx context.Context, req *request, action, key, value string) {
+	switch action {
+	case "show":
+		us, err := b.personal.Get(ctx, req.UserID)
+		if err != nil {
+			req.Error(req.T("config.error", err))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString(req.T("personal.header") + "\n")
+		for _, key := range settings.PersonalKeys {
+			value := settings.PersonalValue(us, key)
+			if value == "" {
+				value = req.T("config.default")
+			}
+			sb.WriteString(fmt.Sprintf("**%s**: %s\n", key, value))
+		}
+		req.Reply(sb.String())
+
+	case "set":
+		if key == "" || value == "" {
+			req.Error(req.T("personal.usage_set", req.prefix, strings.Join(settings.PersonalKeys, ", ")))
+			return
+		}
+		us, err := b.personal.Set(ctx, req.UserID, key, value)
+		if err != nil {
+			req.Error(settingsError(req, err, settings.PersonalKeys))
+			return
+		}
+		req.Reply(req.T("config.set", key, settings.PersonalValue(us, key)))
+
+	case "reset":
+		if key == "" {
+			if err := b.personal.Reset(ctx, req.UserID); err != nil {
+				req.Error(settingsError(req, err, settings.PersonalKeys))
+				return
+			}
+			req.Reply(req.T("personal.reset_all"))
+			return
+		}
+		if _, err := b.personal.Set(ctx, req.UserID, key, ""); err != nil {
+			req.Error(settingsError(req, err, settings.PersonalKeys))
+			return
+		}
+		req.Reply(req.T("config.reset_key", key))
+	}
+}
+
+func configError(req *request, err error) string {
+	return settingsError(req, err, settings.Keys)
+}
+
+// settingsError explains a failed change to one of keys.
+func settingsError(req *request, err error, keys []string) string {
+	switch {
+	case errors.Is(err, settings.ErrUnknownKey):
+		return req.T("config.unknown_key", err, strings.Join(keys, ", "))
+	default:
+		return req.T("config.update_failed", err)
+	}
+}
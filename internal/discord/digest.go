@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/digest"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+func (b *Bot) handleDigest(ctx context.Context, req *request, action, schedule string) {
+	switch action {
+	case "subscribe":
+		b.subscribeDigest(ctx, req, schedule)
+	case "unsubscribe":
+		b.unsubscribeDigest(ctx, req)
+	case "preview":
+		b.previewDigest(ctx, req)
+	}
+}
+
+func (b *Bot) subscribeDigest(ctx context.Context, req *request, schedule string) {
+	d, ok := parseDigestSchedule(schedule)
+	if !ok {
+		req.Error(req.T("digest.usage", req.prefix, digest.MaxLangs))
+		return
+	}
+	for i, lang := range d.Langs {
+		code := sitematrix.Normalize(lang)
+		if !sitematrix.Default().Valid(code) {
+			req.Error(unknownLangError(code).localize(req.loc))
+			return
+		}
+		d.Langs[i] = code
+	}
+	if len(d.Langs) == 0 {
+		lang, _ := b.resolveLang(ctx, req)
+		d.Langs = []string{lang}
+	}
+
+	d.GuildID, d.ChannelID, d.CreatedBy = req.GuildID, req.ChannelID, req.UserID
+	d.NextRun = digest.Next(d, time.Now(), b.guildZone(ctx, req.GuildID))
+	if err := b.store.Digest.Save(ctx, d); err != nil {
+		req.Error(req.T("digest.save_failed", err))
+		return
+	}
+	req.Reply(req.T("digest.subscribed", req.T("digest."+d.Period), strings.Join(d.Langs, ", "), d.Hour, d.Minute, d.NextRun.Unix()))
+}
+
+// parseDigestSchedule reads "daily" or "weekly", then optionally a time of
+// day as hh:mm and up to digest.MaxLangs language codes, such as
+// "daily 09:00 en de".
+func parseDigestSchedule(value string) (*models.Digest, bool) {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 || (fields[0] != models.DigestDaily && fields[0] != models.DigestWeekly) {
+		return nil, false
+	}
+	d := &models.Digest{Period: fields[0], Hour: digest.DefaultHour}
+	fields = fields[1:]
+	if len(fields) > 0 && strings.Contains(fields[0], ":") {
+		t, err := time.Parse("15:04", fields[0])
+		if err != nil {
+			return nil, false
+		}
+		d.Hour, d.Minute = t.Hour(), t.Minute()
+		fields = fields[1:]
+	}
+	if len(fields) > digest.MaxLangs {
+		return nil, false
+	}
+	d.Langs = fields
+	return d, true
+}
+
+func (b *Bot) unsubscribeDigest(ctx context.Context, req *request) {
+	if err := b.store.Digest.Delete(ctx, req.ChannelID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			req.Reply(req.T("digest.none", req.prefix))
+			return
+		}
+		req.Error(req.T("digest.remove_failed", err))
+		return
+	}
+	req.Reply(req.T("digest.unsubscribed"))
+}
+
+// previewDigest shows the digest the channel would get now, or a daily
+// digest of the requester's wiki if it has none.
+func (b *Bot) previewDigest(ctx context.Context, req *request) {
+	d, err := b.store.Digest.Get(ctx, req.ChannelID)
+	if errors.Is(err, store.ErrNotFound) {
+		lang, _ := b.resolveLang(ctx, req)
+		d, err = &models.Digest{GuildID: req.GuildID, ChannelID: req.ChannelID, Period: models.DigestDaily, Langs: []string{lang}}, nil
+	}
+	if err != nil {
+		req.Error(req.T("digest.error", err))
+		return
+	}
+	embed, err := b.digests.Build(ctx, d, time.Now())
+	if err != nil {
+		req.Error(req.T("digest.error", err))
+		return
+	}
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{embed})
+}
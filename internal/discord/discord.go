@@ -3,184 +3,403 @@ package discord
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
-	"net/url"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/alert"
+	"github.com/vlkhvnn/TestON/internal/digest"
+	"github.com/vlkhvnn/TestON/internal/feed"
 	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/outbound"
+	"github.com/vlkhvnn/TestON/internal/scheduler"
+	"github.com/vlkhvnn/TestON/internal/settings"
+	"github.com/vlkhvnn/TestON/internal/shard"
 	"github.com/vlkhvnn/TestON/internal/store"
+	"github.com/vlkhvnn/TestON/internal/watch"
 )
 
-var guildDefaultLang = make(map[string]string)
-
 // for testing
 type Sender interface {
 	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
 }
 
 type Bot struct {
-	session *discordgo.Session
-	store   store.Storage
+	shards   *shard.Manager
+	store    store.Storage
+	settings *settings.Service
+	// personal holds users' own settings, which apply in direct messages.
+	personal *settings.Personal
+	commands *registry
+	watches  *watch.Matcher
+	feeds    *feed.Manager
+	alerts   *alert.Manager
+	digests  *digest.Scheduler
+	jobs     *scheduler.Scheduler
+	// outbox queues channel messages; without one they go straight to the
+	// session.
+	outbox *outbound.Dispatcher
+	// components routes message component clicks by custom ID prefix.
+	components map[string]componentHandler
+	pages      *pageCache
+	throttle   *throttle
+	// userID is the bot's own user ID, known once the session is open.
+	userID string
+	// operators are the users who run the bot, see SetOperators.
+	operators map[string]bool
 }
 
-func NewBot(token string, storage store.Storage) (*Bot, error) {
-	dg, err := discordgo.New("Bot " + token)
+// NewBot creates the bot with a session for every shard in shards. The
+// shards connect when Start is called.
+func NewBot(token string, storage store.Storage, shards shard.Config) (*Bot, error) {
+	manager, err := shard.New(token, shards)
 	if err != nil {
 		return nil, err
 	}
 	bot := &Bot{
-		session: dg,
-		store:   storage,
+		shards:   manager,
+		store:    storage,
+		settings: settings.New(storage.Settings),
+		personal: settings.NewPersonal(storage.UserSettings),
+		watches:  watch.NewMatcher(),
+		feeds:    feed.NewManager(storage.Event, storage.Feed),
+		alerts:   alert.NewManager(),
+		digests:  digest.NewScheduler(storage.Digest, storage.Stat),
+		jobs:     scheduler.New(storage.Job),
+		pages:    newPageCache(),
+		throttle: newThrottle(),
+	}
+	bot.feeds.SetZones(bot.guildZone)
+	bot.feeds.SetLocales(bot.ChannelLocalizer)
+	bot.alerts.SetLocales(bot.ChannelLocalizer)
+	bot.digests.SetZones(bot.guildZone)
+	bot.digests.SetLocales(bot.ChannelLocalizer)
+	bot.commands = newRegistry(bot.builtinCommands())
+	bot.components = map[string]componentHandler{
+		"recent": bot.handleRecentComponent,
 	}
-	dg.AddHandler(bot.messageHandler)
+	manager.AddHandler(bot.messageHandler)
+	manager.AddHandler(bot.interactionHandler)
+	manager.AddHandler(bot.guildCreateHandler)
 	return bot, nil
 }
 
-func (b *Bot) Start() error {
-	if err := b.session.Open(); err != nil {
-		return err
-	}
-	log.Println("Discord bot started.")
-	return nil
+// Sender returns the session used to post messages outside of commands.
+func (b *Bot) Sender() Sender {
+	return b.shards.Session()
 }
 
-func (b *Bot) Stop() {
-	b.session.Close()
+// Shards returns the manager of the bot's gateway connections.
+func (b *Bot) Shards() *shard.Manager {
+	return b.shards
 }
 
-func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	b.HandleMessage(s, m)
+// Watches returns the matcher holding all channel watches.
+func (b *Bot) Watches() *watch.Matcher {
+	return b.watches
 }
 
-func (b *Bot) HandleMessage(s Sender, m *discordgo.MessageCreate) {
-	// In production, s is a *discordgo.Session with State populated.
-	if sess, ok := s.(*discordgo.Session); ok && sess.State != nil {
-		if m.Author.ID == sess.State.User.ID {
-			return
-		}
-	}
+// Feeds returns the manager running the live feed channels.
+func (b *Bot) Feeds() *feed.Manager {
+	return b.feeds
+}
 
-	guildID := m.GuildID
-	if guildID == "" {
-		guildID = m.Author.ID
-	}
+// Alerts returns the manager of the alert subscriptions.
+func (b *Bot) Alerts() *alert.Manager {
+	return b.alerts
+}
 
-	parts := strings.Fields(m.Content)
-	if len(parts) == 0 {
-		return
+// SetOperators names the users who run the bot. Only they see the errors of
+// background jobs in the jobs command, as those come from the database and
+// other internals. It must be called before Start.
+func (b *Bot) SetOperators(userIDs []string) {
+	b.operators = make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		b.operators[id] = true
 	}
+}
 
-	ctx := context.Background()
+// Digests returns the scheduler posting the channel digests.
+func (b *Bot) Digests() *digest.Scheduler {
+	return b.digests
+}
 
-	switch parts[0] {
-	case "!setLang":
-		if len(parts) < 2 {
-			s.ChannelMessageSend(m.ChannelID, "Usage: !setLang [language_code]")
-			return
-		}
-		lang := parts[1]
+// Jobs returns the scheduler of the background jobs, which the jobs command
+// reports on.
+func (b *Bot) Jobs() *scheduler.Scheduler {
+	return b.jobs
+}
 
-		err := b.store.Lang.SetUserLang(ctx, guildID, lang)
-		if err != nil {
-			s.ChannelMessageSend(m.ChannelID, "Failed to set language preference.")
-			return
-		}
+// SetDispatcher makes the bot send channel messages through d, and drop the
+// feeds and watches of channels d finds deleted. It must be called before
+// Start.
+func (b *Bot) SetDispatcher(d *outbound.Dispatcher) {
+	b.outbox = d
+	d.OnFailure(b.sendFailed)
+}
 
-		guildDefaultLang[guildID] = lang
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Default language set to '%s' for this session.", lang))
-
-	case "!recent":
-		var lang string
-		limit := 10
-		if len(parts) >= 2 {
-			if num, err := strconv.Atoi(parts[1]); err == nil {
-				limit = num
-			} else {
-				lang = parts[1]
-			}
+// sendFailed cleans up after a deleted channel, so its feed, watches and
+// alerts stop producing messages that cannot be delivered. A channel the
+// bot lost access to keeps them, as access is often given back, such as
+// after a permission override was changed by mistake.
+func (b *Bot) sendFailed(channelID string, err error) {
+	if !outbound.ChannelDeleted(err) {
+		if outbound.ChannelGone(err) {
+			log.Printf("Cannot post to channel %s, keeping its subscriptions: %v", channelID, err)
 		}
-		if len(parts) >= 3 {
-			if num, err := strconv.Atoi(parts[2]); err == nil {
-				limit = num
-			}
+		return
+	}
+	ctx := context.Background()
+	if _, ok := b.feeds.Get(channelID); ok {
+		b.feeds.Stop(channelID)
+		if err := b.store.Feed.Delete(ctx, channelID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to delete feed of unreachable channel %s: %v", channelID, err)
 		}
-		if limit < 1 {
-			limit = 1
-		} else if limit > 100 {
-			limit = 100
+		log.Printf("Stopped the feed in unreachable channel %s", channelID)
+	}
+	b.removeChannelAlerts(ctx, channelID)
+	if err := b.store.Digest.Delete(ctx, channelID); err == nil {
+		log.Printf("Removed the digest of unreachable channel %s", channelID)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to delete digest of unreachable channel %s: %v", channelID, err)
+	}
+	watches, err := b.store.Watch.ListByChannel(ctx, channelID)
+	if err != nil {
+		log.Printf("Failed to list watches of unreachable channel %s: %v", channelID, err)
+		return
+	}
+	for _, w := range watches {
+		if err := b.store.Watch.Delete(ctx, channelID, w.Kind, w.Lang, w.Target); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to delete watch of unreachable channel %s: %v", channelID, err)
+			continue
 		}
-		if lang == "" {
-			lang, _ = b.store.Lang.GetUserLang(ctx, guildID)
-			if lang == "" {
-				lang = "en"
-			}
+		b.watches.Remove(channelID, w.Kind, w.Lang, w.Target)
+	}
+	if len(watches) > 0 {
+		log.Printf("Removed %d watches in unreachable channel %s", len(watches), channelID)
+	}
+}
+
+func (b *Bot) removeChannelAlerts(ctx context.Context, channelID string) {
+	alerts, err := b.store.Alert.ListByChannel(ctx, channelID)
+	if err != nil {
+		log.Printf("Failed to list alerts of unreachable channel %s: %v", channelID, err)
+		return
+	}
+	for _, a := range alerts {
+		if err := b.store.Alert.Delete(ctx, channelID, a.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to delete alert of unreachable channel %s: %v", channelID, err)
 		}
-		events, err := b.store.Event.GetRecent(ctx, lang, limit)
-		if err != nil {
-			if errors.Is(err, store.ErrNotFound) {
-				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("No recent changes for language: %s", lang))
-				return
-			}
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error retrieving recent changes: %v", err))
-			return
+	}
+	b.alerts.RemoveChannel(channelID)
+	if len(alerts) > 0 {
+		log.Printf("Removed %d alerts in unreachable channel %s", len(alerts), channelID)
+	}
+}
+
+func (b *Bot) Start() error {
+	if err := b.load(context.Background()); err != nil {
+		return err
+	}
+
+	if err := b.shards.Open(); err != nil {
+		return err
+	}
+	session := b.shards.Session()
+	b.userID = session.State.User.ID
+	// Slash commands are global; with several processes, the one running
+	// shard 0 keeps them up to date.
+	if b.shards.Runs(0) {
+		if err := syncCommands(session, b.userID, slashCommands()); err != nil {
+			log.Printf("Failed to sync slash commands: %v", err)
 		}
-		b.sendRecentChanges(s, m, lang, events)
+	}
+	log.Println("Discord bot started.")
+	return nil
+}
 
-	case "!stats":
-		if len(parts) < 2 {
-			s.ChannelMessageSend(m.ChannelID, "Usage: !stats [yyyy-mm-dd] [optional: language_code]")
-			return
+// load registers the watches, alerts and feeds of the guilds this process
+// serves. Every process sees every ingested event, so with several
+// processes each delivers to its own guilds only.
+func (b *Bot) load(ctx context.Context) error {
+	watches, err := b.store.Watch.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	var ownWatches []*models.Watch
+	for _, w := range watches {
+		if b.shards.Serves(w.GuildID) {
+			ownWatches = append(ownWatches, w)
 		}
-		dateStr := parts[1]
-		var lang string
-		if len(parts) >= 3 {
-			lang = parts[2]
-		} else {
-			lang, _ = b.store.Lang.GetUserLang(ctx, guildID)
-			if lang == "" {
-				lang = "en"
-			}
+	}
+	b.watches.Load(ownWatches)
+
+	alerts, err := b.store.Alert.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	var ownAlerts []*models.Alert
+	for _, a := range alerts {
+		if b.shards.Serves(a.GuildID) {
+			ownAlerts = append(ownAlerts, a)
 		}
-		_, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			s.ChannelMessageSend(m.ChannelID, "Invalid date format. Please use yyyy-mm-dd.")
-			return
+	}
+	b.alerts.Load(ownAlerts)
+
+	feeds, err := b.store.Feed.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	var ownFeeds []*models.Feed
+	for _, f := range feeds {
+		if b.shards.Serves(f.GuildID) {
+			ownFeeds = append(ownFeeds, f)
 		}
-		count, err := b.store.Stat.Get(ctx, lang, dateStr)
-		if err != nil {
-			if errors.Is(err, store.ErrNotFound) {
-				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("No stats found for %s on %s", lang, dateStr))
-				return
-			}
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error retrieving stats: %v", err))
+	}
+	return b.feeds.Load(ctx, ownFeeds)
+}
+
+func (b *Bot) Stop() {
+	b.shards.Close()
+}
+
+func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if b.outbox == nil {
+		b.HandleMessage(s, m)
+		return
+	}
+	b.HandleMessage(dispatchedSession{Session: s, outbox: b.outbox}, m)
+}
+
+// dispatchedSession sends channel messages through the dispatcher and uses
+// the session for everything else.
+type dispatchedSession struct {
+	*discordgo.Session
+	outbox *outbound.Dispatcher
+}
+
+func (s dispatchedSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.outbox.ChannelMessageSend(channelID, content, options...)
+}
+
+func (s dispatchedSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.outbox.ChannelMessageSendComplex(channelID, data, options...)
+}
+
+// commandEnabled reports whether name may run in the request's guild and
+// tells the user when it may not. !config can never be disabled.
+func (b *Bot) commandEnabled(ctx context.Context, req *request, name string) bool {
+	if req.GuildID == "" || name == "config" {
+		return true
+	}
+	gs, err := b.settings.Get(ctx, req.GuildID)
+	if err == nil && !settings.CommandEnabled(gs, name) {
+		req.Error(req.T("error.disabled"))
+		return false
+	}
+	return true
+}
+
+// guildZone returns the guild's timezone, or UTC outside of guilds and when
+// none is set.
+func (b *Bot) guildZone(ctx context.Context, guildID string) *time.Location {
+	if guildID == "" {
+		return time.UTC
+	}
+	gs, err := b.settings.Get(ctx, guildID)
+	if err != nil {
+		return time.UTC
+	}
+	return settings.Location(gs)
+}
+
+// requestZone returns the timezone times are shown in for req: the guild's
+// in a guild, and the user's own in direct messages.
+func (b *Bot) requestZone(ctx context.Context, req *request) *time.Location {
+	if req.GuildID != "" {
+		return b.guildZone(ctx, req.GuildID)
+	}
+	us, err := b.personal.Get(ctx, req.UserID)
+	if err != nil {
+		return time.UTC
+	}
+	return settings.PersonalLocation(us)
+}
+
+// recent shows the newest changes one page at a time, with buttons to move
+// between pages and a menu to switch language.
+func (b *Bot) recent(ctx context.Context, req *request, lang string, limit int) {
+	if limit < 1 {
+		limit = 1
+	} else if limit > 100 {
+		limit = 100
+	}
+	if lang == "" {
+		lang, _ = b.resolveLang(ctx, req)
+	}
+	p := &recentPage{ownerID: req.UserID, lang: lang, limit: limit, loc: req.loc, zone: b.requestZone(ctx, req)}
+	content, embeds, pages, err := b.renderRecentPage(ctx, p)
+	if err != nil {
+		req.Error(req.T("recent.error", err))
+		return
+	}
+	if pages == 0 {
+		req.Reply(content)
+		return
+	}
+	id := b.pages.add(p)
+	req.ReplyEmbeds(content, embeds, recentComponents(id, p, pages)...)
+}
+
+// stats shows the count for a single day, or hands ranges over to
+// statsRangeReport.
+func (b *Bot) stats(ctx context.Context, req *request, dateStr, to, lang string, withChart bool) {
+	if lang == "" {
+		lang, _ = b.resolveLang(ctx, req)
+	}
+	if to != "" && to != dateStr {
+		b.statsRangeReport(ctx, req, dateStr, to, lang, withChart)
+		return
+	}
+	if withChart {
+		req.Error(req.T("stats.chart_needs_range", req.prefix, dateStr))
+		return
+	}
+	if zone := b.statsZone(ctx, req); zone != time.UTC {
+		b.localDayStats(ctx, req, dateStr, lang, zone)
+		return
+	}
+	count, err := b.store.Stat.Get(ctx, lang, dateStr)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			req.Reply(req.T("stats.none", lang, dateStr))
 			return
 		}
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("On %s, there were %d changes for language '%s'.", dateStr, count, lang))
-	}
-}
-
-func (b *Bot) sendRecentChanges(s Sender, m *discordgo.MessageCreate, lang string, events []*models.RecentChangeEvent) {
-	header := fmt.Sprintf("Recent changes for '%s':\n", lang)
-	var responseBuilder strings.Builder
-	responseBuilder.WriteString(header)
-	for i, event := range events {
-		t := time.Unix(event.Timestamp, 0).Format(time.RFC822)
-		encodedTitle := url.PathEscape(event.Title)
-		urlStr := fmt.Sprintf("https://%s.wikipedia.org/wiki/%s", lang, encodedTitle)
-		entry := fmt.Sprintf("%d. [%s] [%s](%s) by **%s**\nComment: %s\n\n",
-			i+1, t, event.Title, urlStr, event.User, event.Comment)
-		if responseBuilder.Len()+len(entry) > 2000 {
-			s.ChannelMessageSend(m.ChannelID, responseBuilder.String())
-			responseBuilder.Reset()
-			responseBuilder.WriteString(header)
-		}
-		responseBuilder.WriteString(entry)
+		req.Error(req.T("stats.error", err))
+		return
 	}
-	if responseBuilder.Len() > 0 {
-		s.ChannelMessageSend(m.ChannelID, responseBuilder.String())
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{statsEmbed(req.loc, lang, dateStr, count)})
+}
+
+// localDayStats shows the count for a single day in zone, summed from the
+// hourly counts.
+func (b *Bot) localDayStats(ctx context.Context, req *request, dateStr, lang string, zone *time.Location) {
+	day, _, argErr := statsRange(dateStr, "")
+	if argErr != nil {
+		req.Error(argErr.localize(req.loc))
+		return
+	}
+	days, err := b.dailyCounts(ctx, lang, day, day, zone)
+	if err != nil {
+		req.Error(req.T("stats.error", err))
+		return
+	}
+	if days[0].Count == 0 {
+		req.Reply(req.T("stats.none", lang, dateStr))
+		return
 	}
+	embed := statsEmbed(req.loc, lang, dateStr, days[0].Count)
+	embed.Footer = zoneFooter(req.loc, zone)
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{embed})
 }
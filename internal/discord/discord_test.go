@@ -1,6 +1,8 @@
 package discord
 
 import (
+	"context"
+	"net/http"
 	"strings"
 	"testing"
 	"time"
@@ -9,11 +11,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/outbound"
+	"github.com/vlkhvnn/TestON/internal/settings"
+	"github.com/vlkhvnn/TestON/internal/shard"
 	"github.com/vlkhvnn/TestON/internal/store"
+	"go.uber.org/zap"
 )
 
 type MockSession struct {
 	messages []string
+	embeds   [][]*discordgo.MessageEmbed
 }
 
 func (ms *MockSession) ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
@@ -21,6 +28,12 @@ func (ms *MockSession) ChannelMessageSend(channelID, content string, options ...
 	return &discordgo.Message{Content: content}, nil
 }
 
+func (ms *MockSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	ms.messages = append(ms.messages, data.Content)
+	ms.embeds = append(ms.embeds, data.Embeds)
+	return &discordgo.Message{Content: data.Content, Embeds: data.Embeds}, nil
+}
+
 func TestRecentCommandWithLimit(t *testing.T) {
 	mockEventStore := &store.MockEventStore{
 		RecentEvents: []*models.RecentChangeEvent{
@@ -51,12 +64,13 @@ func TestRecentCommandWithLimit(t *testing.T) {
 	}
 	mockStatStore := &store.MockStatStore{}
 	mockStorage := store.Storage{
-		Event: mockEventStore,
-		Lang:  mockLangStore,
-		Stat:  mockStatStore,
+		Event:    mockEventStore,
+		Lang:     mockLangStore,
+		Stat:     mockStatStore,
+		Settings: &store.MockSettingsStore{},
 	}
 
-	b, err := NewBot("fake-token", mockStorage)
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
 	require.NoError(t, err)
 
 	msgContent := "!recent 5"
@@ -85,6 +99,14 @@ func TestRecentCommandWithLimit(t *testing.T) {
 		}
 	}
 	assert.True(t, found, "Expected response message containing 'Recent changes for'")
+
+	require.Len(t, ms.embeds, 1)
+	require.Len(t, ms.embeds[0], 2)
+	embed := ms.embeds[0][0]
+	assert.Equal(t, "Test Page 1", embed.Title)
+	assert.Equal(t, "https://en.wikipedia.org/wiki/Test_Page_1", embed.URL)
+	assert.Equal(t, "User1", embed.Author.Name)
+	assert.Equal(t, "Comment 1", embed.Fields[0].Value)
 }
 
 func TestStatsCommand(t *testing.T) {
@@ -100,12 +122,13 @@ func TestStatsCommand(t *testing.T) {
 	}
 	mockEventStore := &store.MockEventStore{}
 	mockStorage := store.Storage{
-		Event: mockEventStore,
-		Lang:  mockLangStore,
-		Stat:  mockStatStore,
+		Event:    mockEventStore,
+		Lang:     mockLangStore,
+		Stat:     mockStatStore,
+		Settings: &store.MockSettingsStore{},
 	}
 
-	b, err := NewBot("fake-token", mockStorage)
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
 	require.NoError(t, err)
 
 	msgContent := "!stats 2025-02-04"
@@ -124,12 +147,319 @@ func TestStatsCommand(t *testing.T) {
 
 	b.HandleMessage(ms, m)
 
-	found := false
-	for _, response := range ms.messages {
-		if strings.Contains(response, "42 changes") {
-			found = true
-			break
-		}
+	require.Len(t, ms.embeds, 1)
+	require.Len(t, ms.embeds[0], 1)
+	assert.Contains(t, ms.embeds[0][0].Description, "42 changes")
+}
+
+func TestLangPreferenceLayers(t *testing.T) {
+	mockLangStore := &store.MockLangStore{}
+	mockSettingsStore := &store.MockSettingsStore{}
+	mockStorage := store.Storage{
+		Event:    &store.MockEventStore{},
+		Lang:     mockLangStore,
+		Stat:     &store.MockStatStore{},
+		Settings: mockSettingsStore,
+	}
+
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+	b.throttle = nil
+
+	send := func(content, userID string) string {
+		ms := &MockSession{}
+		b.HandleMessage(ms, &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				ChannelID: "channel1",
+				Author:    &discordgo.User{ID: userID},
+				GuildID:   "guild1",
+			},
+		})
+		require.NotEmpty(t, ms.messages)
+		return ms.messages[len(ms.messages)-1]
+	}
+
+	assert.Contains(t, send("!lang", "user1"), "'en' (global default)")
+
+	send("!setGuildLang de", "admin")
+	assert.Contains(t, send("!lang", "user1"), "'de' (server default)")
+
+	send("!setChannelLang fr", "admin")
+	assert.Contains(t, send("!lang", "user1"), "'fr' (channel default)")
+
+	// Replies follow the language once it has a catalog.
+	send("!setLang es", "user1")
+	assert.Contains(t, send("!lang", "user1"), "'es' (tu preferencia)")
+	assert.Contains(t, send("!lang", "user2"), "'fr' (channel default)")
+
+	send("!setLang reset", "user1")
+	assert.Contains(t, send("!lang", "user1"), "'fr' (channel default)")
+
+	assert.Equal(t, "de", mockSettingsStore.Settings["guild1"].Lang)
+	assert.Empty(t, mockLangStore.Langs["guild1"], "guild preference must not be stored as a user preference")
+}
+
+func TestLegacyGuildLangIsMoved(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx := context.Background()
+
+	// The old !setLang stored guild languages as user languages.
+	require.NoError(t, storage.Lang.SetUserLang(ctx, "guild1", "de"))
+	require.NoError(t, storage.Lang.SetUserLang(ctx, "guild2", "fr"))
+	_, err := b.settings.Set(ctx, "guild2", settings.KeyLang, "es")
+	require.NoError(t, err)
+
+	b.adoptLegacyGuildLang(ctx, "guild1")
+	b.adoptLegacyGuildLang(ctx, "guild2")
+	b.adoptLegacyGuildLang(ctx, "guild3")
+
+	gs, err := b.settings.Get(ctx, "guild1")
+	require.NoError(t, err)
+	assert.Equal(t, "de", gs.Lang)
+	gs, err = b.settings.Get(ctx, "guild2")
+	require.NoError(t, err)
+	assert.Equal(t, "es", gs.Lang, "a default set since wins")
+	for _, id := range []string{"guild1", "guild2"} {
+		_, err := storage.Lang.GetUserLang(ctx, id)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	}
+}
+
+func TestChannelLocalizer(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx := context.Background()
+
+	_, err := b.settings.Set(ctx, "guild1", settings.KeyLang, "es")
+	require.NoError(t, err)
+	require.NoError(t, storage.Lang.SetChannelLang(ctx, "guild1", "channel1", "ru"))
+
+	assert.Equal(t, "ru", b.ChannelLocalizer(ctx, "guild1", "channel1").Lang())
+	assert.Equal(t, "es", b.ChannelLocalizer(ctx, "guild1", "channel2").Lang())
+	assert.Equal(t, "en", b.ChannelLocalizer(ctx, "guild2", "channel3").Lang())
+}
+
+func TestConfigCommand(t *testing.T) {
+	mockSettingsStore := &store.MockSettingsStore{}
+	mockStorage := store.Storage{
+		Event:    &store.MockEventStore{},
+		Lang:     &store.MockLangStore{},
+		Stat:     &store.MockStatStore{},
+		Settings: mockSettingsStore,
+	}
+
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+	b.throttle = nil
+
+	send := func(content string) string {
+		ms := &MockSession{}
+		b.HandleMessage(ms, &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				ChannelID: "channel1",
+				Author:    &discordgo.User{ID: "user1"},
+				GuildID:   "guild1",
+			},
+		})
+		require.NotEmpty(t, ms.messages)
+		return ms.messages[len(ms.messages)-1]
 	}
-	assert.True(t, found, "Expected response message containing '42 changes'")
+
+	assert.Contains(t, send("!config set timezone Asia/Almaty"), "Asia/Almaty")
+	assert.Contains(t, send("!config set timezone Nowhere/Land"), "invalid value")
+	assert.Contains(t, send("!config set colour blue"), "unknown setting")
+	assert.Contains(t, send("!config show"), "**timezone**: Asia/Almaty")
+
+	assert.Contains(t, send("!config set disabled_commands stats"), "stats")
+	assert.Contains(t, send("!stats 2025-02-04"), "disabled")
+
+	assert.Contains(t, send("!config reset"), "reset to defaults")
+	assert.Empty(t, mockSettingsStore.Settings["guild1"])
+	assert.Contains(t, send("!config show"), "**timezone**: (default)")
+}
+
+func TestInvalidLanguageCodes(t *testing.T) {
+	mockLangStore := &store.MockLangStore{}
+	mockStorage := store.Storage{
+		Event:    &store.MockEventStore{},
+		Lang:     mockLangStore,
+		Stat:     &store.MockStatStore{},
+		Settings: &store.MockSettingsStore{},
+	}
+
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+
+	send := func(content string) []string {
+		ms := &MockSession{}
+		b.HandleMessage(ms, &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				ChannelID: "channel1",
+				Author:    &discordgo.User{ID: "user1"},
+				GuildID:   "guild1",
+			},
+		})
+		return ms.messages
+	}
+
+	reply := send("!setLang banana")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Unknown language code 'banana'")
+	assert.Empty(t, mockLangStore.Langs)
+
+	reply = send("!setLang russ")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Did you mean: ru")
+
+	reply = send("!recent xx")
+	require.Len(t, reply, 1)
+	assert.Contains(t, reply[0], "Unknown language code 'xx'")
+
+	reply = send("!setLang ES")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "es", mockLangStore.Langs["user1"])
+
+	reply = send("!languages wikivoyage")
+	require.NotEmpty(t, reply)
+	assert.Contains(t, reply[0], "`en` English")
+	assert.NotContains(t, reply[0], "`af` Afrikaans")
+}
+
+func TestLocalizedReplies(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	// The confirmation is already in the new language.
+	assert.Equal(t, []string{"Язык сервера изменён на 'ru'."}, sendCommand(b, "!setGuildLang ru"))
+	reply := sendCommand(b, "!stats 2025-99-01")
+	require.Len(t, reply, 1)
+	assert.Equal(t, "Неверный формат даты. Используйте гггг-мм-дд.\nИспользование: !stats <date> [to] [language] [chart]", reply[0])
+	assert.True(t, strings.HasPrefix(sendCommand(b, "!help")[0], "Команды:\n"))
+
+	// The user's own preference wins; languages without a catalog fall
+	// back to English.
+	sendCommand(b, "!setLang de")
+	assert.True(t, strings.HasPrefix(sendCommand(b, "!help")[0], "Commands:\n"))
+}
+
+// goneSession fails every send with the given error code, such as when the
+// channel has been deleted.
+type goneSession struct {
+	status int
+	code   int
+}
+
+func (s goneSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return nil, &discordgo.RESTError{
+		Response: &http.Response{StatusCode: s.status},
+		Message:  &discordgo.APIErrorMessage{Code: s.code},
+	}
+}
+
+func TestDeletedChannelLosesItsSubscriptions(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx := context.Background()
+	addChannelSubscriptions(t, b, storage, "gone", "kept")
+	b.SetDispatcher(outbound.New(goneSession{http.StatusNotFound, discordgo.ErrCodeUnknownChannel}, zap.NewNop().Sugar()))
+
+	_, err := b.outbox.ChannelMessageSend("gone", "hello")
+	require.True(t, outbound.ChannelDeleted(err))
+
+	_, ok := b.feeds.Get("gone")
+	assert.False(t, ok)
+	_, ok = b.feeds.Get("kept")
+	assert.True(t, ok)
+	feeds, _ := storage.Feed.ListAll(ctx)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, "kept", feeds[0].ChannelID)
+
+	watches, _ := storage.Watch.ListByChannel(ctx, "gone")
+	assert.Empty(t, watches)
+	assert.Equal(t, 1, b.watches.Len())
+
+	alerts, _ := storage.Alert.ListAll(ctx)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "kept", alerts[0].ChannelID)
+
+	_, err = storage.Digest.Get(ctx, "gone")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = storage.Digest.Get(ctx, "kept")
+	assert.NoError(t, err)
+}
+
+func TestInaccessibleChannelKeepsItsSubscriptions(t *testing.T) {
+	b, storage := newTestBot(t)
+	ctx := context.Background()
+	addChannelSubscriptions(t, b, storage, "hidden")
+	b.SetDispatcher(outbound.New(goneSession{http.StatusForbidden, discordgo.ErrCodeMissingAccess}, zap.NewNop().Sugar()))
+
+	_, err := b.outbox.ChannelMessageSend("hidden", "hello")
+	require.True(t, outbound.ChannelGone(err))
+
+	_, ok := b.feeds.Get("hidden")
+	assert.True(t, ok)
+	feeds, _ := storage.Feed.ListAll(ctx)
+	assert.Len(t, feeds, 1)
+	watches, _ := storage.Watch.ListByChannel(ctx, "hidden")
+	assert.Len(t, watches, 1)
+	assert.Equal(t, 1, b.watches.Len())
+	alerts, _ := storage.Alert.ListAll(ctx)
+	assert.Len(t, alerts, 1)
+	_, err = storage.Digest.Get(ctx, "hidden")
+	assert.NoError(t, err)
+}
+
+// addChannelSubscriptions gives each channel a feed, a watch, an alert and
+// a digest.
+func addChannelSubscriptions(t *testing.T, b *Bot, storage store.Storage, channelIDs ...string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, channelID := range channelIDs {
+		f := &models.Feed{GuildID: "guild1", ChannelID: channelID, Lang: "en"}
+		require.NoError(t, storage.Feed.Save(ctx, f))
+		require.NoError(t, b.feeds.Start(f))
+		w := &models.Watch{GuildID: "guild1", ChannelID: channelID, Kind: models.WatchKindPage, Lang: "en", Target: "Main Page"}
+		require.NoError(t, storage.Watch.Add(ctx, w))
+		b.watches.Add(w)
+		a := &models.Alert{GuildID: "guild1", ChannelID: channelID, Kind: models.AlertKindSpike, Lang: "en", Threshold: 3}
+		require.NoError(t, storage.Alert.Save(ctx, a))
+		b.alerts.Add(a)
+		d := &models.Digest{GuildID: "guild1", ChannelID: channelID, Period: models.DigestDaily, Langs: []string{"en"}}
+		require.NoError(t, storage.Digest.Save(ctx, d))
+	}
+}
+
+func TestLoadTakesOnlyServedGuilds(t *testing.T) {
+	// Snowflakes on shard 1 and shard 0 of 2.
+	const served, other = "4194304", "8388608"
+	storage := store.Storage{
+		Event:        &store.MockEventStore{},
+		Lang:         &store.MockLangStore{},
+		Stat:         &store.MockStatStore{},
+		Settings:     &store.MockSettingsStore{},
+		UserSettings: &store.MockUserSettingsStore{},
+		Watch: &store.MockWatchStore{Watches: []*models.Watch{
+			{GuildID: served, ChannelID: "c1", Kind: models.WatchKindPage, Lang: "en", Target: "Main Page"},
+			{GuildID: other, ChannelID: "c2", Kind: models.WatchKindPage, Lang: "en", Target: "Main Page"},
+		}},
+		Feed: &store.MockFeedStore{Feeds: map[string]*models.Feed{
+			"c1": {GuildID: served, ChannelID: "c1", Lang: "en"},
+			"c2": {GuildID: other, ChannelID: "c2", Lang: "en"},
+		}},
+		Alert:  &store.MockAlertStore{},
+		Digest: &store.MockDigestStore{},
+		Job:    &store.MockJobStore{},
+	}
+	b, err := NewBot("fake-token", storage, shard.Config{Count: 2, IDs: []int{1}})
+	require.NoError(t, err)
+	require.NoError(t, b.load(context.Background()))
+
+	matched := b.Watches().Match("en", &models.RecentChangeEvent{Title: "Main Page"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, "c1", matched[0].ChannelID)
+	_, ok := b.Feeds().Get("c1")
+	assert.True(t, ok)
+	_, ok = b.Feeds().Get("c2")
+	assert.False(t, ok, "another process posts the feeds of guilds it serves")
 }
@@ -0,0 +1,163 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+// Discord rejects messages that exceed these limits.
+const (
+	maxEmbedTotalChars = 6000
+	maxEmbedTitle      = 256
+	maxEmbedAuthor     = 256
+	maxEmbedFooter     = 2048
+	// maxEmbedComment is a little under MediaWiki's own limit of 500 on
+	// edit summaries, which keeps a page of change embeds with their links
+	// under maxEmbedTotalChars.
+	maxEmbedComment = 450
+	// maxEmbedLinks bounds the links field for the same reason. A change or
+	// log link is always short; a history link built from a long title is
+	// left out when it does not fit, as a cut URL would be broken.
+	maxEmbedLinks = 200
+)
+
+// footerTimeLayout shows when a change was made in the guild's timezone.
+const footerTimeLayout = "2006-01-02 15:04 MST"
+
+const (
+	colorGrowth  = 0x2ecc71
+	colorShrink  = 0xe74c3c
+	colorNeutral = 0x95a5a6
+	colorStats   = 0x3498db
+	colorEditWar = 0xe67e22
+)
+
+// changeEmbed renders one edit: the title links to the page, the author to
+// the editor's contributions, and the colour shows whether the page grew or
+// shrank. Discord shows the timestamp in each reader's own timezone; when
+// zone is not UTC, the footer also gives the time in zone.
+func changeEmbed(loc i18n.Localizer, zone *time.Location, lang string, e *models.RecentChangeEvent) *discordgo.MessageEmbed {
+	links := wikilink.For(lang, e)
+	embed := &discordgo.MessageEmbed{
+		Title: truncate(e.Title, maxEmbedTitle),
+		URL:   links.Page,
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: truncate(e.User, maxEmbedAuthor),
+			URL:  links.Contributions,
+		},
+		Timestamp: time.Unix(e.Timestamp, 0).UTC().Format(time.RFC3339),
+		Color:     deltaColor(e.ByteDelta()),
+	}
+	if e.Length.Old != 0 || e.Length.New != 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   loc.T("embed.size"),
+			Value:  formatDelta(loc, e.ByteDelta()),
+			Inline: true,
+		})
+	}
+	if comment := strings.TrimSpace(e.Comment); comment != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  loc.T("embed.comment"),
+			Value: truncate(comment, maxEmbedComment),
+		})
+	}
+	if value := linksValue(loc, links); value != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  loc.T("embed.links"),
+			Value: value,
+		})
+	}
+	var footer []string
+	if e.Wiki != "" {
+		footer = append(footer, e.Wiki)
+	}
+	if zone != time.UTC {
+		footer = append(footer, time.Unix(e.Timestamp, 0).In(zone).Format(footerTimeLayout))
+	}
+	if len(footer) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: truncate(strings.Join(footer, " · "), maxEmbedFooter)}
+	}
+	return embed
+}
+
+func statsEmbed(loc i18n.Localizer, lang, date string, count int) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       loc.T("stats.title", lang),
+		Description: loc.N("stats.description", count, date, lang),
+		Color:       colorStats,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: loc.T("stats.field.date"), Value: date, Inline: true},
+			{Name: loc.T("stats.field.changes"), Value: fmt.Sprint(count), Inline: true},
+		},
+	}
+}
+
+// linksValue links the change itself and the page history, if it fits in
+// maxEmbedLinks. Events stored before revision IDs were recorded have
+// neither a change nor a log link, and get no links field.
+func linksValue(loc i18n.Localizer, links wikilink.Links) string {
+	var parts []string
+	switch {
+	case links.Diff != "":
+		parts = append(parts, fmt.Sprintf("[%s](%s)", loc.T("embed.diff"), links.Diff))
+	case links.Log != "":
+		parts = append(parts, fmt.Sprintf("[%s](%s)", loc.T("embed.log"), links.Log))
+	default:
+		return ""
+	}
+	if links.History != "" {
+		history := fmt.Sprintf("[%s](%s)", loc.T("embed.history"), links.History)
+		if len([]rune(parts[0]))+len([]rune(history))+3 <= maxEmbedLinks {
+			parts = append(parts, history)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+func deltaColor(delta int64) int {
+	switch {
+	case delta > 0:
+		return colorGrowth
+	case delta < 0:
+		return colorShrink
+	}
+	return colorNeutral
+}
+
+func formatDelta(loc i18n.Localizer, delta int64) string {
+	if delta > 0 {
+		return loc.N("embed.bytes_added", int(delta))
+	}
+	return loc.N("embed.bytes", int(delta))
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// embedLength counts the characters Discord adds up against the per-message
+// total.
+func embedLength(e *discordgo.MessageEmbed) int {
+	n := len([]rune(e.Title)) + len([]rune(e.Description))
+	if e.Author != nil {
+		n += len([]rune(e.Author.Name))
+	}
+	if e.Footer != nil {
+		n += len([]rune(e.Footer.Text))
+	}
+	for _, f := range e.Fields {
+		n += len([]rune(f.Name)) + len([]rune(f.Value))
+	}
+	return n
+}
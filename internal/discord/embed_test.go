@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+func TestChangeEmbed(t *testing.T) {
+	e := &models.RecentChangeEvent{
+		Title:      "Main Page",
+		User:       "192.0.2.1",
+		Comment:    "  fix typo ",
+		Timestamp:  1738627200,
+		Wiki:       "dewiki",
+		ServerName: "de.wikipedia.org",
+		Length:     models.EventLength{Old: 1200, New: 1000},
+		Revision:   models.Revision{Old: 11, New: 12},
+	}
+	embed := changeEmbed(i18n.Localizer{}, time.UTC, "de", e)
+	assert.Equal(t, "https://de.wikipedia.org/wiki/Main_Page", embed.URL)
+	assert.Equal(t, "https://de.wikipedia.org/wiki/Special:Contributions/192.0.2.1", embed.Author.URL)
+	assert.Equal(t, "2025-02-04T00:00:00Z", embed.Timestamp)
+	assert.Equal(t, colorShrink, embed.Color)
+	require.Len(t, embed.Fields, 3)
+	assert.Equal(t, "-200 bytes", embed.Fields[0].Value)
+	assert.Equal(t, "fix typo", embed.Fields[1].Value)
+	assert.Equal(t, "[diff](https://de.wikipedia.org/w/index.php?diff=12&oldid=11) · "+
+		"[history](https://de.wikipedia.org/w/index.php?action=history&oldid=12)", embed.Fields[2].Value)
+	assert.Equal(t, "dewiki", embed.Footer.Text)
+
+	e.Length = models.EventLength{New: 50}
+	embed = changeEmbed(i18n.Localizer{}, time.UTC, "de", e)
+	assert.Equal(t, colorGrowth, embed.Color)
+	assert.Equal(t, "+50 bytes", embed.Fields[0].Value)
+
+	// Events stored before sizes were recorded have no size field.
+	embed = changeEmbed(i18n.Localizer{}, time.UTC, "en", &models.RecentChangeEvent{Title: "X", User: "Y"})
+	assert.Equal(t, colorNeutral, embed.Color)
+	assert.Empty(t, embed.Fields)
+	assert.Equal(t, "https://en.wikipedia.org/wiki/X", embed.URL)
+}
+
+func TestChangeEmbedTruncates(t *testing.T) {
+	embed := changeEmbed(i18n.Localizer{}, time.UTC, "en", &models.RecentChangeEvent{
+		Title:   strings.Repeat("é", 300),
+		User:    "U",
+		Comment: strings.Repeat("c", 2000),
+	})
+	assert.Len(t, []rune(embed.Title), maxEmbedTitle)
+	assert.True(t, strings.HasSuffix(embed.Title, "…"))
+	assert.Len(t, []rune(embed.Fields[0].Value), maxEmbedComment)
+}
+
+func TestChangeEmbedShowsGuildTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	e := &models.RecentChangeEvent{Title: "X", User: "Y", Wiki: "dewiki", Timestamp: 1738663200}
+
+	embed := changeEmbed(i18n.Localizer{}, berlin, "de", e)
+	assert.Equal(t, "dewiki · 2025-02-04 11:00 CET", embed.Footer.Text)
+	assert.Equal(t, "2025-02-04T10:00:00Z", embed.Timestamp)
+
+	embed = changeEmbed(i18n.Localizer{}, time.UTC, "de", e)
+	assert.Equal(t, "dewiki", embed.Footer.Text)
+}
+
+func TestFullPageFitsInOneMessage(t *testing.T) {
+	// Zones add their local time to the footer.
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	total := 0
+	for i := 0; i < pageSize; i++ {
+		total += embedLength(changeEmbed(i18n.Localizer{}, berlin, "en", &models.RecentChangeEvent{
+			Title:    strings.Repeat("t", 300),
+			User:     strings.Repeat("u", 300),
+			Comment:  strings.Repeat("c", 2000),
+			Wiki:     "enwiktionary",
+			Length:   models.EventLength{Old: 1, New: 100000},
+			Revision: models.Revision{Old: 1234567890, New: 1234567891},
+		}))
+	}
+	assert.LessOrEqual(t, total, maxEmbedTotalChars)
+
+	// Log events link their history by title, which a long non-ASCII title
+	// makes many times longer once percent-encoded.
+	total = 0
+	for i := 0; i < pageSize; i++ {
+		embed := changeEmbed(i18n.Localizer{}, berlin, "ru", &models.RecentChangeEvent{
+			Type:    "log",
+			Title:   strings.Repeat("ж", 255),
+			User:    strings.Repeat("u", 300),
+			Comment: strings.Repeat("c", 2000),
+			Wiki:    "ruwiki",
+			LogID:   1234567890,
+		})
+		for _, f := range embed.Fields {
+			assert.LessOrEqual(t, len([]rune(f.Value)), 1024)
+		}
+		total += embedLength(embed)
+	}
+	assert.LessOrEqual(t, total, maxEmbedTotalChars)
+}
+
+func TestLinksLeaveOutLongHistory(t *testing.T) {
+	e := &models.RecentChangeEvent{Type: "log", Title: "Short", LogID: 42}
+	embed := changeEmbed(i18n.Localizer{}, time.UTC, "ru", e)
+	require.Len(t, embed.Fields, 1)
+	assert.Contains(t, embed.Fields[0].Value, "[history](https://ru.wikipedia.org/w/index.php?action=history&title=Short)")
+
+	e.Title = strings.Repeat("ж", 100)
+	embed = changeEmbed(i18n.Localizer{}, time.UTC, "ru", e)
+	require.Len(t, embed.Fields, 1)
+	assert.Equal(t, "[log entry](https://ru.wikipedia.org/w/index.php?logid=42&title=Special%3ALog)", embed.Fields[0].Value)
+}
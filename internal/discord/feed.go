@@ -0,0 +1,95 @@
+package discord
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vlkhvnn/TestON/internal/feed"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+func (b *Bot) handleFeed(ctx context.Context, req *request, action, lang, filters string) {
+	switch action {
+	case "start":
+		b.startFeed(ctx, req, lang, filters)
+	case "stop":
+		b.stopFeed(ctx, req)
+	case "status":
+		f, ok := b.feeds.Get(req.ChannelID)
+		if !ok {
+			req.Reply(req.T("feed.none_status", req.prefix))
+			return
+		}
+		req.Reply(req.N("feed.status", b.feeds.Pending(req.ChannelID), f.Lang, describeFilter(req.loc, f.Filters)))
+	}
+}
+
+func (b *Bot) startFeed(ctx context.Context, req *request, lang, filters string) {
+	if lang == "" {
+		req.Error(req.T("feed.usage_start", req.prefix))
+		return
+	}
+	filter, err := feed.ParseFilter(filters)
+	if err != nil {
+		req.Error(req.T("feed.invalid_filter", err))
+		return
+	}
+
+	gs, err := b.settings.Get(ctx, req.GuildID)
+	if err != nil {
+		req.Error(req.T("config.error", err))
+		return
+	}
+	if len(gs.FeedChannels) > 0 && !contains(gs.FeedChannels, req.ChannelID) {
+		req.Error(req.T("feed.not_allowed"))
+		return
+	}
+
+	f := &models.Feed{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		CreatedBy: req.UserID,
+		Lang:      lang,
+		Filters:   filter.String(),
+	}
+	if err := b.store.Feed.Save(ctx, f); err != nil {
+		req.Error(req.T("feed.start_failed", err))
+		return
+	}
+	if err := b.feeds.Start(f); err != nil {
+		req.Error(req.T("feed.start_failed", err))
+		return
+	}
+	req.Reply(req.T("feed.started", lang, describeFilter(req.loc, f.Filters)))
+}
+
+func (b *Bot) stopFeed(ctx context.Context, req *request) {
+	if err := b.store.Feed.Delete(ctx, req.ChannelID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			req.Error(req.T("feed.none"))
+			return
+		}
+		req.Error(req.T("feed.stop_failed", err))
+		return
+	}
+	b.feeds.Stop(req.ChannelID)
+	req.Reply(req.T("feed.stopped"))
+}
+
+func describeFilter(loc i18n.Localizer, filters string) string {
+	if filters == "" {
+		return loc.T("feed.no_filters")
+	}
+	return loc.T("feed.filters", filters)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package discord
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxJobError bounds the error shown for a failed run; database errors can
+// be long enough to overflow the field.
+const maxJobError = 300
+
+// listJobs shows when every background job runs next and how its last run
+// went. The errors of failed runs are only shown to the bot's operators.
+func (b *Bot) listJobs(req *request) {
+	jobs := b.jobs.Status()
+	if len(jobs) == 0 {
+		req.Reply(req.T("jobs.none"))
+		return
+	}
+
+	fields := make([]*discordgo.MessageEmbedField, len(jobs))
+	for i, j := range jobs {
+		var lines []string
+		switch {
+		case j.Running:
+			lines = append(lines, req.T("jobs.running"))
+		case !j.Next.IsZero():
+			lines = append(lines, req.T("jobs.next", j.Next.Unix()))
+		}
+		took := j.Last.Duration.Round(time.Millisecond).String()
+		switch {
+		case j.Last.LastRun.IsZero():
+			lines = append(lines, req.T("jobs.never"))
+		case j.Last.Error != "" && b.operators[req.UserID]:
+			lines = append(lines, req.T("jobs.failed", j.Last.LastRun.Unix(), took, truncate(j.Last.Error, maxJobError)))
+		case j.Last.Error != "":
+			lines = append(lines, req.T("jobs.failed_hidden", j.Last.LastRun.Unix(), took))
+		default:
+			lines = append(lines, req.T("jobs.ok", j.Last.LastRun.Unix(), took))
+		}
+		if j.Skipped > 0 {
+			lines = append(lines, req.N("jobs.skipped", j.Skipped))
+		}
+		fields[i] = &discordgo.MessageEmbedField{
+			Name:  j.Name + " · " + j.Schedule,
+			Value: strings.Join(lines, "\n"),
+		}
+	}
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{{
+		Title:  req.T("jobs.title"),
+		Fields: fields,
+		Color:  colorNeutral,
+	}})
+}
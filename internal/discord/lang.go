@@ -0,0 +1,211 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/settings"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+// DefaultLang is used when neither the user, the channel nor the guild has a
+// language preference.
+const DefaultLang = "en"
+
+// langSource says where a resolved language came from. Its value is the
+// suffix of the "lang.source.*" message describing it.
+type langSource string
+
+const (
+	langSourceUser    langSource = "user"
+	langSourceChannel langSource = "channel"
+	langSourceGuild   langSource = "server"
+	langSourceGlobal  langSource = "global"
+)
+
+type langScope string
+
+const (
+	langScopeUser    langScope = "user"
+	langScopeChannel langScope = "channel"
+	langScopeGuild   langScope = "server"
+)
+
+// resolveLang returns the language that applies to the request.
+// The user's own preference wins over the channel default, which wins over
+// the guild default, which wins over DefaultLang. Channel and guild levels
+// are skipped in direct messages.
+func (b *Bot) resolveLang(ctx context.Context, req *request) (string, langSource) {
+	if lang, err := b.store.Lang.GetUserLang(ctx, req.UserID); err == nil && lang != "" {
+		return lang, langSourceUser
+	}
+	if req.GuildID == "" {
+		return DefaultLang, langSourceGlobal
+	}
+	if lang, err := b.store.Lang.GetChannelLang(ctx, req.ChannelID); err == nil && lang != "" {
+		return lang, langSourceChannel
+	}
+	if gs, err := b.settings.Get(ctx, req.GuildID); err == nil && gs.Lang != "" {
+		return gs.Lang, langSourceGuild
+	}
+	return DefaultLang, langSourceGlobal
+}
+
+// localizer returns the localizer for the language that applies to the
+// request. Languages without a catalog get English replies.
+func (b *Bot) localizer(ctx context.Context, req *request) i18n.Localizer {
+	lang, _ := b.resolveLang(ctx, req)
+	return i18n.Default().Localizer(lang)
+}
+
+// ChannelLocalizer returns the localizer for messages the bot posts to a
+// channel on its own, such as watch notifications and feeds: in the
+// channel's language, or else the guild's.
+func (b *Bot) ChannelLocalizer(ctx context.Context, guildID, channelID string) i18n.Localizer {
+	if lang, err := b.store.Lang.GetChannelLang(ctx, channelID); err == nil && lang != "" {
+		return i18n.Default().Localizer(lang)
+	}
+	if gs, err := b.settings.Get(ctx, guildID); err == nil && gs.Lang != "" {
+		return i18n.Default().Localizer(gs.Lang)
+	}
+	return i18n.Default().Localizer(DefaultLang)
+}
+
+// setLang stores code as the language for scope, or clears it when code is
+// "reset". The code must already be validated.
+func (b *Bot) setLang(ctx context.Context, req *request, scope langScope, code string) {
+	if scope != langScopeUser && req.GuildID == "" {
+		req.Error(req.T("lang.needs_server." + string(scope)))
+		return
+	}
+
+	if code == "reset" {
+		var err error
+		switch scope {
+		case langScopeUser:
+			err = b.store.Lang.DeleteUserLang(ctx, req.UserID)
+		case langScopeChannel:
+			err = b.store.Lang.DeleteChannelLang(ctx, req.ChannelID)
+		case langScopeGuild:
+			_, err = b.settings.ResetKey(ctx, req.GuildID, settings.KeyLang)
+		}
+		if err != nil {
+			req.Error(req.T("lang.reset_failed." + string(scope)))
+			return
+		}
+		req.loc = b.localizer(ctx, req)
+		req.Reply(req.T("lang.reset." + string(scope)))
+		return
+	}
+
+	lang := code
+	var err error
+	switch scope {
+	case langScopeUser:
+		err = b.store.Lang.SetUserLang(ctx, req.UserID, lang)
+	case langScopeChannel:
+		err = b.store.Lang.SetChannelLang(ctx, req.GuildID, req.ChannelID, lang)
+	case langScopeGuild:
+		_, err = b.settings.Set(ctx, req.GuildID, settings.KeyLang, lang)
+	}
+	if err != nil {
+		req.Error(req.T("lang.set_failed." + string(scope)))
+		return
+	}
+	// Answer in the new language if it now applies.
+	req.loc = b.localizer(ctx, req)
+	req.Reply(req.T("lang.set."+string(scope), lang))
+}
+
+func unknownLangError(code string) *argError {
+	if suggestions := sitematrix.Default().Suggest(code, 5); len(suggestions) > 0 {
+		return newArgError("lang.unknown_suggest", code, strings.Join(suggestions, ", "))
+	}
+	return newArgError("lang.unknown", code)
+}
+
+func sendLanguages(req *request, project string) {
+	matrix := sitematrix.Default()
+
+	header := req.T("languages.header") + "\n"
+	if project != "" {
+		header = req.T("languages.header_project", project) + "\n"
+	}
+
+	var entries []string
+	for _, l := range matrix.Languages {
+		if project != "" && !hasProject(l, project) {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("`%s` %s", l.Code, l.LocalName))
+	}
+	if len(entries) == 0 {
+		req.Error(req.T("languages.none", project))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, entry := range entries {
+		if sb.Len()+len(entry)+2 > 2000 {
+			req.Reply(sb.String())
+			sb.Reset()
+		}
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(entry)
+	}
+	if sb.Len() > 0 {
+		req.Reply(sb.String())
+	}
+}
+
+func hasProject(l sitematrix.Language, project string) bool {
+	for _, p := range l.Projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) guildCreateHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
+	go b.adoptLegacyGuildLang(context.Background(), g.ID)
+}
+
+// adoptLegacyGuildLang moves a language that the old !setLang saved under
+// the guild's ID, as if it were a user's, to the guild default, unless the
+// guild has set a default since. Migration 000021 moves the rows of guilds
+// the database knows; this catches the rest as the bot sees them.
+func (b *Bot) adoptLegacyGuildLang(ctx context.Context, guildID string) {
+	lang, err := b.store.Lang.GetUserLang(ctx, guildID)
+	if err != nil || lang == "" {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to look up legacy language of guild %s: %v", guildID, err)
+		}
+		return
+	}
+	_, err = b.settings.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		if gs.Lang == "" {
+			gs.Lang = lang
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to move legacy language of guild %s: %v", guildID, err)
+		return
+	}
+	if err := b.store.Lang.DeleteUserLang(ctx, guildID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to delete legacy language of guild %s: %v", guildID, err)
+		return
+	}
+	log.Printf("Moved legacy language %s of guild %s to its settings", lang, guildID)
+}
@@ -0,0 +1,265 @@
+package discord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+)
+
+const (
+	pageSize = 5
+	// pageTTL is how long the controls of a paginated message keep working
+	// after they were last used.
+	pageTTL = 10 * time.Minute
+	// maxSelectOptions is Discord's limit on select menu options.
+	maxSelectOptions = 25
+)
+
+// pageLanguages are offered in the language menu of !recent, largest wikis
+// first. The language being shown is always added.
+var pageLanguages = []string{
+	"en", "de", "fr", "es", "ja", "ru", "it", "zh", "pt", "pl", "nl", "ar",
+	"uk", "sv", "fa", "he", "ko", "id", "vi", "tr", "cs", "fi", "hu", "no",
+}
+
+// componentHandler handles a click on a message component. Custom IDs have
+// the form <handler>:<state id>:<action>.
+type componentHandler func(ctx context.Context, s InteractionSender, i *discordgo.InteractionCreate, id, action string)
+
+func (b *Bot) handleComponent(s InteractionSender, i *discordgo.InteractionCreate) {
+	parts := strings.SplitN(i.MessageComponentData().CustomID, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+	handle, ok := b.components[parts[0]]
+	if !ok {
+		return
+	}
+	handle(context.Background(), s, i, parts[1], parts[2])
+}
+
+// recentPage is the state behind one paginated !recent message.
+type recentPage struct {
+	ownerID string
+	lang    string
+	limit   int
+	page    int
+	expires time.Time
+	// loc is the owner's reply language when the command ran.
+	loc i18n.Localizer
+	// zone is the guild's timezone when the command ran.
+	zone *time.Location
+}
+
+// pageCache keeps pagination state in memory. Entries expire after pageTTL
+// without use, and controls on messages from before a restart expire with
+// them.
+type pageCache struct {
+	mu    sync.Mutex
+	pages map[string]*recentPage
+	now   func() time.Time
+}
+
+func newPageCache() *pageCache {
+	return &pageCache{pages: make(map[string]*recentPage), now: time.Now}
+}
+
+func (c *pageCache) add(p *recentPage) string {
+	var buf [8]byte
+	rand.Read(buf[:])
+	id := hex.EncodeToString(buf[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, existing := range c.pages {
+		if now.After(existing.expires) {
+			delete(c.pages, key)
+		}
+	}
+	cp := *p
+	cp.expires = now.Add(pageTTL)
+	c.pages[id] = &cp
+	return id
+}
+
+// get returns a copy of the state, or false if it is unknown or expired.
+func (c *pageCache) get(id string) (*recentPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pages[id]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(p.expires) {
+		delete(c.pages, id)
+		return nil, false
+	}
+	cp := *p
+	return &cp, true
+}
+
+func (c *pageCache) save(id string, p *recentPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp := *p
+	cp.expires = c.now().Add(pageTTL)
+	c.pages[id] = &cp
+}
+
+// renderRecentPage loads the page p points at, clamping it to the pages
+// that exist, and returns the message content, its embeds and the number
+// of pages.
+func (b *Bot) renderRecentPage(ctx context.Context, p *recentPage) (string, []*discordgo.MessageEmbed, int, error) {
+	count, err := b.store.Event.Count(ctx, p.lang)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	total := count
+	if p.limit < total {
+		total = p.limit
+	}
+	pages := (total + pageSize - 1) / pageSize
+	if pages == 0 {
+		p.page = 0
+		return p.loc.T("recent.none", p.lang), []*discordgo.MessageEmbed{}, 0, nil
+	}
+	if p.page >= pages {
+		p.page = pages - 1
+	} else if p.page < 0 {
+		p.page = 0
+	}
+
+	offset := p.page * pageSize
+	n := pageSize
+	if total-offset < n {
+		n = total - offset
+	}
+	events, err := b.store.Event.GetPage(ctx, p.lang, offset, n)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	embeds := make([]*discordgo.MessageEmbed, len(events))
+	for i, event := range events {
+		embeds[i] = changeEmbed(p.loc, p.zone, p.lang, event)
+	}
+	content := p.loc.T("recent.page", p.lang, p.page+1, pages)
+	return content, embeds, pages, nil
+}
+
+func recentComponents(id string, p *recentPage, pages int) []discordgo.MessageComponent {
+	customID := func(action string) string {
+		return "recent:" + id + ":" + action
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    p.loc.T("recent.previous"),
+				Style:    discordgo.SecondaryButton,
+				CustomID: customID("prev"),
+				Disabled: p.page == 0,
+			},
+			discordgo.Button{
+				Label:    p.loc.T("recent.next"),
+				Style:    discordgo.SecondaryButton,
+				CustomID: customID("next"),
+				Disabled: p.page >= pages-1,
+			},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    customID("lang"),
+				Placeholder: p.loc.T("recent.language"),
+				Options:     languageOptions(p.lang),
+			},
+		}},
+	}
+}
+
+func languageOptions(current string) []discordgo.SelectMenuOption {
+	codes := pageLanguages
+	if !contains(codes, current) {
+		codes = append([]string{current}, codes...)
+	}
+	if len(codes) > maxSelectOptions {
+		codes = codes[:maxSelectOptions]
+	}
+	options := make([]discordgo.SelectMenuOption, len(codes))
+	for i, code := range codes {
+		label := code
+		if l, ok := sitematrix.Default().Lookup(code); ok && l.Name != "" {
+			label = fmt.Sprintf("%s (%s)", l.Name, code)
+		}
+		options[i] = discordgo.SelectMenuOption{Label: label, Value: code, Default: code == current}
+	}
+	return options
+}
+
+func (b *Bot) handleRecentComponent(ctx context.Context, s InteractionSender, i *discordgo.InteractionCreate, id, action string) {
+	p, ok := b.pages.get(id)
+	if !ok {
+		respondEphemeral(s, i, interactionLocalizer(i.Interaction).T("recent.expired"))
+		return
+	}
+	if interactionUserID(i.Interaction) != p.ownerID {
+		respondEphemeral(s, i, interactionLocalizer(i.Interaction).T("recent.not_owner"))
+		return
+	}
+
+	switch action {
+	case "prev":
+		p.page--
+	case "next":
+		p.page++
+	case "lang":
+		values := i.MessageComponentData().Values
+		if len(values) == 0 || !sitematrix.Default().Valid(values[0]) {
+			respondEphemeral(s, i, p.loc.T("recent.unknown_lang"))
+			return
+		}
+		p.lang = values[0]
+		p.page = 0
+	default:
+		return
+	}
+
+	content, embeds, pages, err := b.renderRecentPage(ctx, p)
+	if err != nil {
+		respondEphemeral(s, i, p.loc.T("recent.error", err))
+		return
+	}
+	b.pages.save(id, p)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Embeds:     embeds,
+			Components: recentComponents(id, p, pages),
+		},
+	})
+}
+
+// interactionLocalizer picks the reply language from the locale of the
+// clicking user's Discord client, for clicks that carry no other state.
+func interactionLocalizer(i *discordgo.Interaction) i18n.Localizer {
+	lang, _, _ := strings.Cut(string(i.Locale), "-")
+	return i18n.Default().Localizer(lang)
+}
+
+func respondEphemeral(s InteractionSender, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
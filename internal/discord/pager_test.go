@@ -0,0 +1,194 @@
+package discord
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+func newPagedBot(t *testing.T, n int) *Bot {
+	t.Helper()
+	b, storage := newTestBot(t)
+	events := storage.Event.(*store.MockEventStore)
+	for i := 1; i <= n; i++ {
+		events.RecentEvents = append(events.RecentEvents, &models.RecentChangeEvent{
+			Title:      fmt.Sprintf("Page %d", i),
+			User:       "U",
+			ServerName: "de.wikipedia.org",
+		})
+	}
+	return b
+}
+
+func recentMessage(t *testing.T, b *Bot, content string) *discordgo.MessageSend {
+	t.Helper()
+	var sent *discordgo.MessageSend
+	ms := &recordingSession{send: func(m *discordgo.MessageSend) { sent = m }}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   content,
+			ChannelID: "channel1",
+			Author:    &discordgo.User{ID: "user1"},
+			GuildID:   "guild1",
+		},
+	})
+	require.NotNil(t, sent)
+	return sent
+}
+
+// recordingSession keeps the full complex message so tests can read its
+// components.
+type recordingSession struct {
+	MockSession
+	send func(m *discordgo.MessageSend)
+}
+
+func (rs *recordingSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	rs.send(data)
+	return rs.MockSession.ChannelMessageSendComplex(channelID, data, options...)
+}
+
+func componentID(t *testing.T, components []discordgo.MessageComponent, row, col int) string {
+	t.Helper()
+	r := components[row].(discordgo.ActionsRow)
+	switch c := r.Components[col].(type) {
+	case discordgo.Button:
+		return c.CustomID
+	case discordgo.SelectMenu:
+		return c.CustomID
+	}
+	t.Fatalf("unexpected component %T", r.Components[col])
+	return ""
+}
+
+func click(b *Bot, customID, userID string, values ...string) *MockInteractionSession {
+	ms := &MockInteractionSession{}
+	b.HandleInteraction(ms, &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionMessageComponent,
+			GuildID:   "guild1",
+			ChannelID: "channel1",
+			Member:    &discordgo.Member{User: &discordgo.User{ID: userID}},
+			Data: discordgo.MessageComponentInteractionData{
+				CustomID: customID,
+				Values:   values,
+			},
+		},
+	})
+	return ms
+}
+
+func TestRecentPagination(t *testing.T) {
+	b := newPagedBot(t, 12)
+
+	msg := recentMessage(t, b, "!recent de 100")
+	assert.Equal(t, "Recent changes for 'de' (page 1 of 3):", msg.Content)
+	require.Len(t, msg.Embeds, pageSize)
+	assert.Equal(t, "Page 1", msg.Embeds[0].Title)
+	require.Len(t, msg.Components, 2)
+	assert.True(t, msg.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.Button).Disabled, "no previous page")
+
+	next := componentID(t, msg.Components, 0, 1)
+	ms := click(b, next, "user1")
+	require.Len(t, ms.responses, 1)
+	resp := ms.responses[0]
+	assert.Equal(t, discordgo.InteractionResponseUpdateMessage, resp.Type)
+	assert.Equal(t, "Recent changes for 'de' (page 2 of 3):", resp.Data.Content)
+	assert.Equal(t, "Page 6", resp.Data.Embeds[0].Title)
+
+	ms = click(b, next, "user1")
+	resp = ms.responses[0]
+	assert.Equal(t, "Recent changes for 'de' (page 3 of 3):", resp.Data.Content)
+	assert.Len(t, resp.Data.Embeds, 2)
+	assert.True(t, resp.Data.Components[0].(discordgo.ActionsRow).Components[1].(discordgo.Button).Disabled, "no next page")
+
+	// Clicking next on the last page stays there.
+	ms = click(b, next, "user1")
+	assert.Equal(t, "Recent changes for 'de' (page 3 of 3):", ms.responses[0].Data.Content)
+
+	ms = click(b, componentID(t, msg.Components, 0, 0), "user1")
+	assert.Equal(t, "Recent changes for 'de' (page 2 of 3):", ms.responses[0].Data.Content)
+}
+
+func TestRecentPaginationRespectsLimit(t *testing.T) {
+	b := newPagedBot(t, 12)
+	msg := recentMessage(t, b, "!recent de 7")
+	assert.Equal(t, "Recent changes for 'de' (page 1 of 2):", msg.Content)
+
+	ms := click(b, componentID(t, msg.Components, 0, 1), "user1")
+	assert.Len(t, ms.responses[0].Data.Embeds, 2)
+}
+
+func TestRecentLanguageMenu(t *testing.T) {
+	b := newPagedBot(t, 3)
+	msg := recentMessage(t, b, "!recent de")
+
+	menu := msg.Components[1].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	require.NotEmpty(t, menu.Options)
+	assert.LessOrEqual(t, len(menu.Options), maxSelectOptions)
+	var selected []string
+	for _, o := range menu.Options {
+		if o.Default {
+			selected = append(selected, o.Value)
+		}
+	}
+	assert.Equal(t, []string{"de"}, selected)
+
+	ms := click(b, menu.CustomID, "user1", "fr")
+	require.Len(t, ms.responses, 1)
+	assert.Equal(t, "Recent changes for 'fr' (page 1 of 1):", ms.responses[0].Data.Content)
+
+	ms = click(b, menu.CustomID, "user1", "xx")
+	assert.Equal(t, "Unknown language.", ms.responses[0].Data.Content)
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, ms.responses[0].Data.Flags)
+}
+
+func TestRecentLanguageMenuAddsCurrentLanguage(t *testing.T) {
+	options := languageOptions("eo")
+	assert.Equal(t, "eo", options[0].Value)
+	assert.True(t, options[0].Default)
+	assert.Len(t, options, maxSelectOptions)
+}
+
+func TestRecentControlsOwnerAndExpiry(t *testing.T) {
+	b := newPagedBot(t, 12)
+	now := time.Date(2025, 2, 4, 12, 0, 0, 0, time.UTC)
+	b.pages.now = func() time.Time { return now }
+
+	msg := recentMessage(t, b, "!recent de")
+	next := componentID(t, msg.Components, 0, 1)
+
+	ms := click(b, next, "someone-else")
+	assert.Equal(t, "Only the person who ran the command can use these controls.", ms.responses[0].Data.Content)
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, ms.responses[0].Data.Flags)
+
+	// Using the controls keeps them alive.
+	now = now.Add(pageTTL - time.Second)
+	ms = click(b, next, "user1")
+	assert.Equal(t, discordgo.InteractionResponseUpdateMessage, ms.responses[0].Type)
+
+	now = now.Add(pageTTL + time.Second)
+	ms = click(b, next, "user1")
+	assert.Equal(t, "These controls have expired. Run the command again.", ms.responses[0].Data.Content)
+}
+
+func TestUnknownComponentIsIgnored(t *testing.T) {
+	b := newPagedBot(t, 1)
+	assert.Empty(t, click(b, "nope:1:next", "user1").responses)
+	assert.Empty(t, click(b, "garbage", "user1").responses)
+}
+
+func TestSlashRecentHasControls(t *testing.T) {
+	b := newPagedBot(t, 12)
+	ms := &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("recent", stringOption("language", "de")))
+	require.Len(t, ms.responses, 1)
+	assert.Equal(t, "Recent changes for 'de' (page 1 of 2):", ms.responses[0].Data.Content)
+	require.Len(t, ms.responses[0].Data.Components, 2)
+}
@@ -0,0 +1,166 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/settings"
+)
+
+// permissionNames are the message keys of the permissions commands use,
+// named as in Discord's own settings.
+var permissionNames = map[int64]string{
+	discordgo.PermissionAdministrator:  "perm.administrator",
+	discordgo.PermissionManageServer:   "perm.manage_server",
+	discordgo.PermissionManageChannels: "perm.manage_channels",
+}
+
+func permissionName(loc i18n.Localizer, p int64) string {
+	if key, ok := permissionNames[p]; ok {
+		return loc.T(key)
+	}
+	return loc.T("perm.other", p)
+}
+
+// permitted checks the command's own permission and the roles the guild
+// granted it. A command with neither is open to everyone; granting roles
+// an open command restricts it to those roles. Administrators may always
+// run everything.
+func (b *Bot) permitted(ctx context.Context, req *request, cmd *command) bool {
+	var gs models.GuildSettings
+	if req.GuildID != "" {
+		gs, _ = b.settings.Get(ctx, req.GuildID)
+	}
+	granted := len(gs.RoleGrants[cmd.Name]) > 0
+	if cmd.Permission == 0 && !granted {
+		return true
+	}
+	if settings.RoleGranted(gs, cmd.Name, req.roles) || req.permissions == nil {
+		return true
+	}
+
+	perms, err := req.permissions()
+	if err != nil {
+		log.Printf("Denied %s to user %s in guild %s: could not read permissions: %v", cmd.Name, req.UserID, req.GuildID, err)
+		req.Error(req.T("perms.check_failed"))
+		return false
+	}
+	if perms&discordgo.PermissionAdministrator != 0 || (cmd.Permission != 0 && perms&cmd.Permission != 0) {
+		return true
+	}
+
+	var msg string
+	switch {
+	case cmd.Permission != 0 && granted:
+		msg = req.T("perms.denied_permission_or_role", req.prefix, cmd.Name, permissionName(req.loc, cmd.Permission))
+	case cmd.Permission != 0:
+		msg = req.T("perms.denied_permission", req.prefix, cmd.Name, permissionName(req.loc, cmd.Permission))
+	default:
+		msg = req.T("perms.denied_role", req.prefix, cmd.Name)
+	}
+	log.Printf("Denied %s to user %s in guild %s channel %s: permission %d, %d granted roles",
+		cmd.Name, req.UserID, req.GuildID, req.ChannelID, cmd.Permission, len(gs.RoleGrants[cmd.Name]))
+	req.Error(msg)
+	return false
+}
+
+func (b *Bot) handlePerms(ctx context.Context, req *request, action, name, role string) {
+	if action == "list" {
+		b.listGrants(ctx, req)
+		return
+	}
+	if name == "" || role == "" {
+		req.Error(req.T("perms.usage", req.prefix, action))
+		return
+	}
+	cmd, ok := b.commands.lookup(strings.TrimPrefix(name, req.prefix))
+	if !ok {
+		req.Error(req.T("error.unknown_command", name, req.prefix))
+		return
+	}
+
+	switch action {
+	case "grant":
+		if cmd.Name == "perms" {
+			req.Error(req.T("perms.perms_fixed", req.prefix, permissionName(req.loc, discordgo.PermissionManageServer)))
+			return
+		}
+		gs, err := b.settings.GrantRole(ctx, req.GuildID, cmd.Name, role)
+		if err != nil {
+			req.Error(permsError(req, err))
+			return
+		}
+		msg := req.T("perms.granted", req.prefix, cmd.Name)
+		if cmd.Permission == 0 && len(gs.RoleGrants[cmd.Name]) == 1 {
+			msg += " " + req.T("perms.granted_restricts")
+		}
+		req.Reply(msg)
+
+	case "revoke":
+		gs, err := b.settings.RevokeRole(ctx, req.GuildID, cmd.Name, role)
+		if err != nil {
+			req.Error(permsError(req, err))
+			return
+		}
+		msg := req.T("perms.revoked", req.prefix, cmd.Name)
+		if cmd.Permission == 0 && len(gs.RoleGrants[cmd.Name]) == 0 {
+			msg += " " + req.T("perms.revoked_open")
+		}
+		req.Reply(msg)
+	}
+}
+
+// listGrants answers with an embed so role mentions render without pinging
+// the roles.
+func (b *Bot) listGrants(ctx context.Context, req *request) {
+	gs, err := b.settings.Get(ctx, req.GuildID)
+	if err != nil {
+		req.Error(req.T("perms.error", err))
+		return
+	}
+	if len(gs.RoleGrants) == 0 {
+		req.Reply(req.T("perms.none"))
+		return
+	}
+	names := make([]string, 0, len(gs.RoleGrants))
+	for n := range gs.RoleGrants {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, n := range names {
+		mentions := make([]string, len(gs.RoleGrants[n]))
+		for i, id := range gs.RoleGrants[n] {
+			mentions[i] = "<@&" + id + ">"
+		}
+		who := strings.Join(mentions, ", ")
+		if cmd, ok := b.commands.lookup(n); ok && cmd.Permission != 0 {
+			who = req.T("perms.permission_or_roles", permissionName(req.loc, cmd.Permission), who)
+		}
+		sb.WriteString(fmt.Sprintf("`%s%s` — %s\n", req.prefix, n, who))
+	}
+	req.ReplyEmbeds("", []*discordgo.MessageEmbed{{
+		Title:       req.T("perms.title"),
+		Description: sb.String(),
+		Color:       colorStats,
+	}})
+}
+
+// permsError shows the settings error itself when it is about the input,
+// as those name the offending role or command.
+func permsError(req *request, err error) string {
+	switch {
+	case errors.Is(err, settings.ErrInvalidValue), errors.Is(err, settings.ErrUnknownGrant), errors.Is(err, settings.ErrTooManyGrant):
+		return fmt.Sprintf("%v.", err)
+	default:
+		return req.T("perms.update_failed", err)
+	}
+}
@@ -0,0 +1,124 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// permSession reports fixed channel permissions, like a session with a
+// populated state would.
+type permSession struct {
+	MockSession
+	perms int64
+	err   error
+}
+
+func (ps *permSession) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	return ps.perms, ps.err
+}
+
+func sendAs(b *Bot, content string, perms int64, roles ...string) []string {
+	ps := &permSession{perms: perms}
+	b.HandleMessage(ps, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   content,
+			ChannelID: "channel1",
+			Author:    &discordgo.User{ID: "user1"},
+			GuildID:   "guild1",
+			Member:    &discordgo.Member{Roles: roles},
+		},
+	})
+	return ps.messages
+}
+
+func TestPermissionDenied(t *testing.T) {
+	b, _ := newTestBot(t)
+
+	reply := sendAs(b, "!watchlist", 0)
+	assert.Equal(t, []string{"Nothing is watched in this channel. Add a page with !watch or an editor with !watchuser."}, reply)
+
+	reply = sendAs(b, "!setChannelLang de", 0)
+	assert.Equal(t, []string{"You do not have permission to use !setChannelLang: it needs the Manage Channels permission."}, reply)
+
+	reply = sendAs(b, "!setChannelLang de", discordgo.PermissionManageChannels)
+	assert.Contains(t, reply[0], "channel language set to 'de'")
+
+	reply = sendAs(b, "!setGuildLang de", discordgo.PermissionAdministrator)
+	assert.Contains(t, reply[0], "server language set to 'de'")
+}
+
+func TestPermissionLookupFailureDenies(t *testing.T) {
+	b, _ := newTestBot(t)
+	ps := &permSession{err: errors.New("unknown channel")}
+	b.HandleMessage(ps, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   "!setChannelLang de",
+			ChannelID: "channel1",
+			Author:    &discordgo.User{ID: "user1"},
+			GuildID:   "guild1",
+		},
+	})
+	assert.Equal(t, []string{"Could not check your permissions. Please try again."}, ps.messages)
+}
+
+func TestRoleGrants(t *testing.T) {
+	b, _ := newTestBot(t)
+	manager := int64(discordgo.PermissionManageServer)
+
+	assert.Equal(t, []string{"No roles have been granted commands on this server."}, sendAs(b, "!perms list", manager))
+	assert.Contains(t, sendAs(b, "!perms grant watch <@&42>", 0)[0], "needs the Manage Server permission")
+
+	assert.Equal(t, []string{"Members with that role can now use !watch."}, sendAs(b, "!perms grant !watch <@&42>", manager))
+	reply := sendAs(b, "!watch de Berlin", 0)
+	assert.Equal(t, "You do not have permission to use !watch: it needs the Manage Channels permission or a role granted access to it.", reply[0])
+	reply = sendAs(b, "!watch de Berlin", 0, "7", "42")
+	assert.Equal(t, "Watching 'Berlin' (de) in this channel.", reply[0])
+
+	// Aliases resolve to the command they name.
+	assert.Contains(t, sendAs(b, "!perms grant setServerLang 42", manager)[0], "!setGuildLang")
+
+	// Granting an open command restricts it.
+	reply = sendAs(b, "!perms grant recent 42", manager)
+	assert.Contains(t, reply[0], "Everyone else can no longer use it")
+	assert.Equal(t, []string{"You do not have permission to use !recent: it needs a role granted access to it."}, sendAs(b, "!recent", 0))
+	assert.NotContains(t, sendAs(b, "!recent", discordgo.PermissionAdministrator)[0], "permission")
+
+	ps := &permSession{perms: manager}
+	b.HandleMessage(ps, &discordgo.MessageCreate{Message: &discordgo.Message{
+		Content: "!perms list", ChannelID: "channel1", GuildID: "guild1",
+		Author: &discordgo.User{ID: "user1"},
+	}})
+	require.Len(t, ps.embeds, 1)
+	assert.Equal(t, "`!recent` — <@&42>\n"+
+		"`!setGuildLang` — Manage Server or <@&42>\n"+
+		"`!watch` — Manage Channels or <@&42>\n", ps.embeds[0][0].Description)
+
+	assert.Contains(t, sendAs(b, "!perms revoke recent 42", manager)[0], "Everyone can use it again.")
+	assert.NotContains(t, sendAs(b, "!recent", 0)[0], "permission")
+
+	assert.Equal(t, []string{"unknown grant: <@&42> has no grant for recent."}, sendAs(b, "!perms revoke recent 42", manager))
+	assert.Equal(t, []string{"invalid value: \"mods\" is not a role."}, sendAs(b, "!perms grant recent mods", manager))
+	assert.Equal(t, []string{"!perms always needs the Manage Server permission."}, sendAs(b, "!perms grant perms 42", manager))
+	assert.Contains(t, sendAs(b, "!perms grant nope 42", manager)[0], "Unknown command 'nope'")
+}
+
+func TestSlashRoleGrant(t *testing.T) {
+	b, _ := newTestBot(t)
+	_, err := b.settings.GrantRole(context.Background(), "guild1", "setChannelLang", "42")
+	require.NoError(t, err)
+
+	i := newSlashInteraction("setlang", stringOption("language", "de"), stringOption("scope", "channel"))
+	ms := &MockInteractionSession{}
+	b.HandleInteraction(ms, i)
+	assert.Contains(t, ms.responses[0].Data.Content, "You do not have permission")
+
+	i.Member.Roles = []string{"42"}
+	ms = &MockInteractionSession{}
+	b.HandleInteraction(ms, i)
+	assert.Contains(t, ms.responses[0].Data.Content, "channel language set to 'de'")
+}
@@ -0,0 +1,136 @@
+package discord
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/ratelimit"
+)
+
+// throttledCommands counts refused commands by command name. expvar serves
+// it at /debug/vars when the metrics listener is enabled.
+var throttledCommands = expvar.NewMap("throttled_commands")
+
+// rateLimits are the buckets a command draws from. Each command has its own
+// buckets, so a busy !recent does not block !help. Zero rates are
+// unlimited.
+type rateLimits struct {
+	User    ratelimit.Rate
+	Channel ratelimit.Rate
+	Guild   ratelimit.Rate
+}
+
+var (
+	// defaultRateLimits applies to commands without their own limits.
+	defaultRateLimits = &rateLimits{
+		User:    ratelimit.Rate{Burst: 5, Every: 3 * time.Second},
+		Channel: ratelimit.Rate{Burst: 10, Every: 2 * time.Second},
+		Guild:   ratelimit.Rate{Burst: 30, Every: time.Second},
+	}
+	// queryRateLimits is for commands that run heavier queries or send
+	// several embeds.
+	queryRateLimits = &rateLimits{
+		User:    ratelimit.Rate{Burst: 3, Every: 10 * time.Second},
+		Channel: ratelimit.Rate{Burst: 5, Every: 5 * time.Second},
+		Guild:   ratelimit.Rate{Burst: 15, Every: 2 * time.Second},
+	}
+)
+
+// throttle applies the rate limits and remembers which cooldowns have
+// already been announced, so a user who keeps sending a command does not
+// make the bot keep answering.
+type throttle struct {
+	limiter *ratelimit.Limiter
+
+	mu       sync.Mutex
+	notified map[string]time.Time
+	now      func() time.Time
+}
+
+func newThrottle() *throttle {
+	return &throttle{
+		limiter:  ratelimit.New(),
+		notified: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (t *throttle) setClock(now func() time.Time) {
+	t.now = now
+	t.limiter.SetClock(now)
+}
+
+// notify reports whether the cooldown for key still needs announcing and
+// marks it announced until it ends.
+func (t *throttle) notify(key string, retryAfter time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if until, ok := t.notified[key]; ok && now.Before(until) {
+		return false
+	}
+	for k, until := range t.notified {
+		if !now.Before(until) {
+			delete(t.notified, k)
+		}
+	}
+	t.notified[key] = now.Add(retryAfter)
+	return true
+}
+
+// rateLimited takes a token for the command from the user's, channel's
+// and guild's buckets, and tells the user when to retry if one is empty.
+// A bot without a throttle is not limited.
+func (b *Bot) rateLimited(req *request, cmd *command) bool {
+	if b.throttle == nil {
+		return false
+	}
+	limits := cmd.RateLimit
+	if limits == nil {
+		limits = defaultRateLimits
+	}
+	buckets := []ratelimit.Bucket{
+		{Key: "user:" + req.UserID + ":" + cmd.Name, Rate: limits.User},
+		{Key: "channel:" + req.ChannelID + ":" + cmd.Name, Rate: limits.Channel},
+	}
+	if req.GuildID != "" {
+		buckets = append(buckets, ratelimit.Bucket{Key: "guild:" + req.GuildID + ":" + cmd.Name, Rate: limits.Guild})
+	}
+
+	ok, denied, retryAfter := b.throttle.limiter.Allow(buckets...)
+	if ok {
+		return false
+	}
+	throttledCommands.Add(cmd.Name, 1)
+
+	wait := formatCooldown(req.loc, retryAfter)
+	var msg string
+	switch denied {
+	case 0:
+		msg = req.T("ratelimit.user", req.prefix, cmd.Name, wait)
+	case 1:
+		msg = req.T("ratelimit.channel", req.prefix, cmd.Name, wait)
+	default:
+		msg = req.T("ratelimit.guild", req.prefix, cmd.Name, wait)
+	}
+	// Interactions must always be answered; their errors are only shown to
+	// the user anyway.
+	_, interaction := req.replier.(*interactionReplier)
+	if interaction || b.throttle.notify(buckets[denied].Key+":"+req.UserID, retryAfter) {
+		log.Printf("Throttled %s for user %s in channel %s guild %s, retry in %s", cmd.Name, req.UserID, req.ChannelID, req.GuildID, retryAfter)
+		req.Error(msg)
+	}
+	return true
+}
+
+// formatCooldown rounds up to whole seconds.
+func formatCooldown(loc i18n.Localizer, d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return loc.N("duration.seconds", secs)
+}
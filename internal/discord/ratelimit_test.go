@@ -0,0 +1,102 @@
+package discord
+
+import (
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+)
+
+func newThrottledBot(t *testing.T) (*Bot, *time.Time) {
+	t.Helper()
+	b, _ := newTestBot(t)
+	now := time.Date(2025, 2, 4, 12, 0, 0, 0, time.UTC)
+	b.throttle = newThrottle()
+	b.throttle.setClock(func() time.Time { return now })
+	return b, &now
+}
+
+func sendFrom(b *Bot, content, userID, channelID string) []string {
+	ms := &MockSession{}
+	b.HandleMessage(ms, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			Content:   content,
+			ChannelID: channelID,
+			Author:    &discordgo.User{ID: userID},
+			GuildID:   "guild1",
+		},
+	})
+	return ms.messages
+}
+
+func throttledCount(name string) int64 {
+	if v, ok := throttledCommands.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestUserRateLimit(t *testing.T) {
+	b, now := newThrottledBot(t)
+	before := throttledCount("stats")
+
+	for i := 0; i < queryRateLimits.User.Burst; i++ {
+		reply := sendFrom(b, "!stats 2025-02-04 de", "user1", "channel1")
+		require.Len(t, reply, 1)
+		assert.NotContains(t, reply[0], "Slow down")
+	}
+	assert.Equal(t, []string{"Slow down! You can use !stats again in 10 seconds."}, sendFrom(b, "!stats 2025-02-04 de", "user1", "channel1"))
+	// The cooldown is only announced once.
+	assert.Empty(t, sendFrom(b, "!stats 2025-02-04 de", "user1", "channel1"))
+	assert.Equal(t, before+2, throttledCount("stats"))
+
+	// Other commands and other users are not affected.
+	assert.NotContains(t, sendFrom(b, "!lang", "user1", "channel1")[0], "Slow down")
+	assert.NotContains(t, sendFrom(b, "!stats 2025-02-04 de", "user2", "channel2")[0], "Slow down")
+
+	*now = now.Add(queryRateLimits.User.Every)
+	assert.NotContains(t, sendFrom(b, "!stats 2025-02-04 de", "user1", "channel1")[0], "Slow down")
+}
+
+func TestChannelAndGuildRateLimits(t *testing.T) {
+	b, now := newThrottledBot(t)
+
+	for i := 0; i < queryRateLimits.Channel.Burst; i++ {
+		sendFrom(b, "!stats 2025-02-04 de", fmt.Sprintf("user%d", i), "channel1")
+	}
+	reply := sendFrom(b, "!stats 2025-02-04 de", "late", "channel1")
+	assert.Equal(t, []string{"!stats is being used a lot in this channel. Try again in 5 seconds."}, reply)
+
+	*now = now.Add(time.Minute)
+	for i := 0; i < queryRateLimits.Guild.Burst; i++ {
+		sendFrom(b, "!stats 2025-02-04 de", fmt.Sprintf("user%d", i), fmt.Sprintf("channel%d", i))
+	}
+	reply = sendFrom(b, "!stats 2025-02-04 de", "late", "elsewhere")
+	assert.Equal(t, []string{"!stats is being used a lot on this server. Try again in 2 seconds."}, reply)
+}
+
+func TestSlashRateLimitAlwaysAnswers(t *testing.T) {
+	b, _ := newThrottledBot(t)
+	var last *MockInteractionSession
+	for i := 0; i < queryRateLimits.User.Burst+2; i++ {
+		last = &MockInteractionSession{}
+		b.HandleInteraction(last, newSlashInteraction("stats", stringOption("date", "2025-02-04"), stringOption("language", "de")))
+	}
+	require.Len(t, last.responses, 1)
+	assert.Contains(t, last.responses[0].Data.Content, "Slow down!")
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, last.responses[0].Data.Flags)
+}
+
+func TestFormatCooldown(t *testing.T) {
+	assert.Equal(t, "1 second", formatCooldown(i18n.Localizer{}, 200*time.Millisecond))
+	assert.Equal(t, "3 seconds", formatCooldown(i18n.Localizer{}, 2100*time.Millisecond))
+	ru := i18n.Default().Localizer("ru")
+	assert.Equal(t, "1 секунду", formatCooldown(ru, time.Second))
+	assert.Equal(t, "3 секунды", formatCooldown(ru, 3*time.Second))
+	assert.Equal(t, "10 секунд", formatCooldown(ru, 10*time.Second))
+}
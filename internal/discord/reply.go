@@ -0,0 +1,179 @@
+package discord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+)
+
+// InteractionSender is the part of *discordgo.Session used to answer
+// interactions. It is an interface so tests can run without a gateway.
+type InteractionSender interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// replier sends command output back to wherever the command came from.
+// Error is used for usage and validation problems; interactions show those
+// only to the invoking user.
+type replier interface {
+	Reply(content string)
+	ReplyEmbeds(content string, embeds []*discordgo.MessageEmbed, components ...discordgo.MessageComponent)
+	ReplyFile(content string, embeds []*discordgo.MessageEmbed, file *discordgo.File)
+	Error(content string)
+}
+
+// request describes who invoked a command and where, independent of whether
+// it arrived as a text message or as a slash command.
+type request struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	replier
+
+	// prefix is the text command prefix in effect, used in usage messages.
+	prefix string
+
+	// permissions returns the invoking member's permissions in the channel.
+	// It is nil when they cannot be determined.
+	permissions func() (int64, error)
+	// roles are the invoking member's role IDs; empty outside guilds.
+	roles []string
+	// loc formats replies in the requester's language.
+	loc i18n.Localizer
+}
+
+// T formats the message for key in the requester's language.
+func (r *request) T(key string, args ...any) string {
+	return r.loc.T(key, args...)
+}
+
+// N formats a message that depends on the count n.
+func (r *request) N(key string, n int, args ...any) string {
+	return r.loc.N(key, n, args...)
+}
+
+func newMessageRequest(s Sender, m *discordgo.MessageCreate) *request {
+	req := &request{
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+		replier:   &channelReplier{s: s, channelID: m.ChannelID},
+		prefix:    defaultPrefix,
+	}
+	if m.Member != nil {
+		req.roles = m.Member.Roles
+	}
+	if ps, ok := s.(permissionSource); ok && m.GuildID != "" {
+		req.permissions = func() (int64, error) {
+			return ps.UserChannelPermissions(m.Author.ID, m.ChannelID)
+		}
+	}
+	return req
+}
+
+func newInteractionRequest(s InteractionSender, i *discordgo.Interaction) *request {
+	req := &request{
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+		replier:   &interactionReplier{s: s, i: i},
+		prefix:    defaultPrefix,
+	}
+	req.UserID = interactionUserID(i)
+	if i.Member != nil {
+		req.roles = i.Member.Roles
+		perms := i.Member.Permissions
+		req.permissions = func() (int64, error) {
+			return perms, nil
+		}
+	}
+	return req
+}
+
+// interactionUserID returns who triggered the interaction, in a guild or
+// in a DM.
+func interactionUserID(i *discordgo.Interaction) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+type channelReplier struct {
+	s         Sender
+	channelID string
+}
+
+func (r *channelReplier) Reply(content string) {
+	r.s.ChannelMessageSend(r.channelID, content)
+}
+
+func (r *channelReplier) ReplyEmbeds(content string, embeds []*discordgo.MessageEmbed, components ...discordgo.MessageComponent) {
+	r.s.ChannelMessageSendComplex(r.channelID, &discordgo.MessageSend{
+		Content:    content,
+		Embeds:     embeds,
+		Components: components,
+	})
+}
+
+func (r *channelReplier) ReplyFile(content string, embeds []*discordgo.MessageEmbed, file *discordgo.File) {
+	r.s.ChannelMessageSendComplex(r.channelID, &discordgo.MessageSend{
+		Content: content,
+		Embeds:  embeds,
+		Files:   []*discordgo.File{file},
+	})
+}
+
+func (r *channelReplier) Error(content string) {
+	r.s.ChannelMessageSend(r.channelID, content)
+}
+
+// interactionReplier answers the interaction with the first message and
+// sends anything after that as follow-ups.
+type interactionReplier struct {
+	s         InteractionSender
+	i         *discordgo.Interaction
+	responded bool
+}
+
+func (r *interactionReplier) Reply(content string) {
+	r.send(&discordgo.MessageSend{Content: content}, 0)
+}
+
+func (r *interactionReplier) ReplyEmbeds(content string, embeds []*discordgo.MessageEmbed, components ...discordgo.MessageComponent) {
+	r.send(&discordgo.MessageSend{Content: content, Embeds: embeds, Components: components}, 0)
+}
+
+func (r *interactionReplier) ReplyFile(content string, embeds []*discordgo.MessageEmbed, file *discordgo.File) {
+	r.send(&discordgo.MessageSend{Content: content, Embeds: embeds, Files: []*discordgo.File{file}}, 0)
+}
+
+func (r *interactionReplier) Error(content string) {
+	r.send(&discordgo.MessageSend{Content: content}, discordgo.MessageFlagsEphemeral)
+}
+
+func (r *interactionReplier) send(m *discordgo.MessageSend, flags discordgo.MessageFlags) {
+	if !r.responded {
+		r.responded = true
+		r.s.InteractionRespond(r.i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    m.Content,
+				Embeds:     m.Embeds,
+				Components: m.Components,
+				Files:      m.Files,
+				Flags:      flags,
+			},
+		})
+		return
+	}
+	r.s.FollowupMessageCreate(r.i, false, &discordgo.WebhookParams{
+		Content:    m.Content,
+		Embeds:     m.Embeds,
+		Components: m.Components,
+		Files:      m.Files,
+		Flags:      flags,
+	})
+}
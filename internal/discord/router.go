@@ -0,0 +1,126 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+const defaultPrefix = "!"
+
+// permissionSource is implemented by *discordgo.Session. Senders without it
+// (such as test mocks) skip permission checks.
+type permissionSource interface {
+	UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error)
+}
+
+func (b *Bot) HandleMessage(s Sender, m *discordgo.MessageCreate) {
+	// The bot's own ID is known once the session is open.
+	if b.userID != "" && m.Author.ID == b.userID {
+		return
+	}
+
+	ctx := context.Background()
+	var gs models.GuildSettings
+	if m.GuildID != "" {
+		gs, _ = b.settings.Get(ctx, m.GuildID)
+	}
+
+	tokens, ok := b.commandLine(gs, m.Content)
+	if !ok || len(tokens) == 0 {
+		return
+	}
+
+	cmd, ok := b.commands.lookup(tokens[0])
+	if !ok {
+		return
+	}
+
+	req := newMessageRequest(s, m)
+	req.prefix = guildPrefix(gs)
+	req.loc = b.localizer(ctx, req)
+	if !b.allowed(ctx, req, cmd) {
+		return
+	}
+
+	a, err := cmd.parseArgs(tokens[1:])
+	if err != nil {
+		b.usageError(req, cmd, err)
+		return
+	}
+	cmd.Handler(ctx, req, a)
+}
+
+// commandLine strips the guild's prefix, or a mention of the bot, from
+// content and expands the guild's custom aliases. ok is false when the
+// message is not addressed to the bot.
+func (b *Bot) commandLine(gs models.GuildSettings, content string) ([]string, bool) {
+	rest, ok := b.stripMention(content)
+	if !ok {
+		prefix := guildPrefix(gs)
+		if !strings.HasPrefix(content, prefix) {
+			return nil, false
+		}
+		rest = strings.TrimPrefix(content, prefix)
+	}
+
+	tokens := tokenize(rest)
+	if len(tokens) == 0 {
+		return nil, true
+	}
+	if _, builtin := b.commands.lookup(tokens[0]); builtin {
+		return tokens, true
+	}
+	if expansion, ok := gs.Aliases[strings.ToLower(tokens[0])]; ok {
+		return append(tokenize(expansion), tokens[1:]...), true
+	}
+	return tokens, true
+}
+
+func (b *Bot) stripMention(content string) (string, bool) {
+	if b.userID == "" {
+		return "", false
+	}
+	for _, mention := range []string{"<@" + b.userID + ">", "<@!" + b.userID + ">"} {
+		if strings.HasPrefix(content, mention) {
+			return strings.TrimPrefix(content, mention), true
+		}
+	}
+	return "", false
+}
+
+func guildPrefix(gs models.GuildSettings) string {
+	if gs.Prefix != "" {
+		return gs.Prefix
+	}
+	return defaultPrefix
+}
+
+// allowed runs the checks shared by every entry point before a command's
+// handler is called, and answers the user when one of them fails.
+func (b *Bot) allowed(ctx context.Context, req *request, cmd *command) bool {
+	if !cmd.availableIn(req) {
+		if cmd.DMOnly {
+			req.Error(req.T("error.dm_only"))
+		} else {
+			req.Error(req.T("error.guild_only"))
+		}
+		return false
+	}
+	if !b.commandEnabled(ctx, req, cmd.Name) {
+		return false
+	}
+	return b.permitted(ctx, req, cmd) && !b.rateLimited(req, cmd)
+}
+
+func (b *Bot) usageError(req *request, cmd *command, err error) {
+	var argErr *argError
+	msg := req.T("error.invalid_args")
+	if errors.As(err, &argErr) {
+		msg = argErr.localize(req.loc)
+	}
+	req.Error(msg + "\n" + req.T("error.usage", cmd.usage(req.prefix)))
+}
@@ -0,0 +1,374 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+)
+
+const maxAutocompleteChoices = 25
+
+// commandSyncer is the part of *discordgo.Session used to register
+// application commands.
+type commandSyncer interface {
+	ApplicationCommands(appID, guildID string, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error)
+	ApplicationCommandEdit(appID, guildID, cmdID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error)
+	ApplicationCommandDelete(appID, guildID, cmdID string, options ...discordgo.RequestOption) error
+}
+
+func slashCommands() []*discordgo.ApplicationCommand {
+	minLimit := 1.0
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        "setlang",
+			Description: "Set the Wikipedia language for you, this channel or this server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "language",
+					Description:  "Language code, or \"reset\" to clear it",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "scope",
+					Description: "Who the language applies to (default: you)",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "me", Value: string(langScopeUser)},
+						{Name: "this channel", Value: string(langScopeChannel)},
+						{Name: "this server", Value: string(langScopeGuild)},
+					},
+				},
+			},
+		},
+		{
+			Name:        "recent",
+			Description: "Show recent Wikipedia changes",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "language",
+					Description:  "Language code (default: your language)",
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "limit",
+					Description: "Number of changes to show",
+					MinValue:    &minLimit,
+					MaxValue:    100,
+				},
+			},
+		},
+		{
+			Name:        "stats",
+			Description: "Show the number of changes for a language on a date or range of dates",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "date",
+					Description:  "Date as yyyy-mm-dd",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "to",
+					Description:  "Last date of a range, as yyyy-mm-dd",
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "language",
+					Description:  "Language code (default: your language)",
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "chart",
+					Description: "Attach a line chart of the range",
+				},
+			},
+		},
+		{
+			Name:        "top",
+			Description: "Show the most changed articles on a date or range of dates",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "language",
+					Description:  "Language code (default: your language)",
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "date",
+					Description:  "Date as yyyy-mm-dd (default: today)",
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "to",
+					Description:  "Last date of a range, as yyyy-mm-dd",
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "chart",
+					Description: "Attach a bar chart",
+				},
+			},
+		},
+	}
+}
+
+// syncCommands makes the registered global commands match want. Commands
+// that are unchanged are left alone so their IDs and permissions survive
+// restarts.
+func syncCommands(s commandSyncer, appID string, want []*discordgo.ApplicationCommand) error {
+	existing, err := s.ApplicationCommands(appID, "")
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*discordgo.ApplicationCommand, len(existing))
+	for _, cmd := range existing {
+		byName[cmd.Name] = cmd
+	}
+
+	for _, cmd := range want {
+		current, ok := byName[cmd.Name]
+		delete(byName, cmd.Name)
+		switch {
+		case !ok:
+			if _, err := s.ApplicationCommandCreate(appID, "", cmd); err != nil {
+				return fmt.Errorf("create /%s: %w", cmd.Name, err)
+			}
+			log.Printf("Registered slash command /%s", cmd.Name)
+		case !commandEqual(current, cmd):
+			if _, err := s.ApplicationCommandEdit(appID, "", current.ID, cmd); err != nil {
+				return fmt.Errorf("update /%s: %w", cmd.Name, err)
+			}
+			log.Printf("Updated slash command /%s", cmd.Name)
+		}
+	}
+
+	for _, stale := range byName {
+		if err := s.ApplicationCommandDelete(appID, "", stale.ID); err != nil {
+			return fmt.Errorf("delete /%s: %w", stale.Name, err)
+		}
+		log.Printf("Removed slash command /%s", stale.Name)
+	}
+	return nil
+}
+
+func commandEqual(a, b *discordgo.ApplicationCommand) bool {
+	return a.Name == b.Name &&
+		a.Description == b.Description &&
+		optionsEqual(a.Options, b.Options)
+}
+
+func optionsEqual(a, b []*discordgo.ApplicationCommandOption) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.Type != y.Type ||
+			x.Name != y.Name ||
+			x.Description != y.Description ||
+			x.Required != y.Required ||
+			x.Autocomplete != y.Autocomplete ||
+			x.MaxValue != y.MaxValue ||
+			!floatPtrEqual(x.MinValue, y.MinValue) ||
+			len(x.Choices) != len(y.Choices) ||
+			!optionsEqual(x.Options, y.Options) {
+			return false
+		}
+		for j := range x.Choices {
+			if x.Choices[j].Name != y.Choices[j].Name ||
+				fmt.Sprint(x.Choices[j].Value) != fmt.Sprint(y.Choices[j].Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func floatPtrEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (b *Bot) interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	b.HandleInteraction(s, i)
+}
+
+func (b *Bot) HandleInteraction(s InteractionSender, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.handleSlashCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		b.handleAutocomplete(s, i, time.Now())
+	case discordgo.InteractionMessageComponent:
+		b.handleComponent(s, i)
+	}
+}
+
+// handleSlashCommand maps a slash command onto the registered text command
+// with the same arguments, so both entry points share validation and
+// handlers.
+func (b *Bot) handleSlashCommand(s InteractionSender, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	req := newInteractionRequest(s, i.Interaction)
+
+	values := make(map[string]string, len(data.Options))
+	for _, opt := range data.Options {
+		if opt.Type == discordgo.ApplicationCommandOptionBoolean {
+			// Flags such as chart are passed like the text command's
+			// keyword, and only when set.
+			if opt.BoolValue() {
+				values[opt.Name] = opt.Name
+			}
+			continue
+		}
+		values[opt.Name] = fmt.Sprint(opt.Value)
+	}
+
+	name := data.Name
+	if name == "setlang" {
+		switch langScope(values["scope"]) {
+		case langScopeChannel:
+			name = "setChannelLang"
+		case langScopeGuild:
+			name = "setGuildLang"
+		default:
+			name = "setLang"
+		}
+		delete(values, "scope")
+	}
+
+	ctx := context.Background()
+	req.loc = b.localizer(ctx, req)
+	cmd, ok := b.commands.lookup(name)
+	if !ok {
+		req.Error(req.T("error.unknown_slash", data.Name))
+		return
+	}
+
+	if req.GuildID != "" {
+		if gs, err := b.settings.Get(ctx, req.GuildID); err == nil {
+			req.prefix = guildPrefix(gs)
+		}
+	}
+	if !b.allowed(ctx, req, cmd) {
+		return
+	}
+
+	a, err := cmd.parseNamedArgs(values)
+	if err != nil {
+		b.usageError(req, cmd, err)
+		return
+	}
+	cmd.Handler(ctx, req, a)
+}
+
+func (b *Bot) handleAutocomplete(s InteractionSender, i *discordgo.InteractionCreate, now time.Time) {
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Focused {
+			focused = opt
+			break
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	switch focused.Name {
+	case "language":
+		choices = languageChoices(focused.StringValue())
+	case "date", "to":
+		ctx := context.Background()
+		req := newInteractionRequest(s, i.Interaction)
+		choices = dateChoices(b.localizer(ctx, req), b.statsZone(ctx, req), focused.StringValue(), now)
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+// languageChoices offers codes starting with input first, then the closest
+// matches by code or English name.
+func languageChoices(input string) []*discordgo.ApplicationCommandOptionChoice {
+	matrix := sitematrix.Default()
+	input = sitematrix.Normalize(input)
+
+	seen := make(map[string]bool)
+	var codes []string
+	for _, code := range matrix.Codes() {
+		if len(codes) == maxAutocompleteChoices {
+			break
+		}
+		if strings.HasPrefix(code, input) {
+			codes = append(codes, code)
+			seen[code] = true
+		}
+	}
+	for _, code := range matrix.Suggest(input, maxAutocompleteChoices) {
+		if len(codes) == maxAutocompleteChoices {
+			break
+		}
+		if !seen[code] {
+			codes = append(codes, code)
+		}
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(codes))
+	for _, code := range codes {
+		l, _ := matrix.Lookup(code)
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s (%s)", code, l.LocalName),
+			Value: code,
+		})
+	}
+	return choices
+}
+
+// dateChoices offers the last days in zone, the zone whose days the
+// requester's stats dates refer to, labelling today and yesterday in loc's
+// language.
+func dateChoices(loc i18n.Localizer, zone *time.Location, input string, now time.Time) []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	day := now.In(zone)
+	for n := 0; n < 30 && len(choices) < maxAutocompleteChoices; n++ {
+		date := day.AddDate(0, 0, -n).Format("2006-01-02")
+		if !strings.HasPrefix(date, input) {
+			continue
+		}
+		name := date
+		switch n {
+		case 0:
+			name = loc.T("stats.choice_today", date)
+		case 1:
+			name = loc.T("stats.choice_yesterday", date)
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: date})
+	}
+	return choices
+}
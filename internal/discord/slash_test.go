@@ -0,0 +1,195 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/shard"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+type MockInteractionSession struct {
+	responses []*discordgo.InteractionResponse
+	followups []*discordgo.WebhookParams
+}
+
+func (ms *MockInteractionSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	ms.responses = append(ms.responses, resp)
+	return nil
+}
+
+func (ms *MockInteractionSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	ms.followups = append(ms.followups, data)
+	return &discordgo.Message{Content: data.Content}, nil
+}
+
+func newSlashInteraction(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			GuildID:   "guild1",
+			ChannelID: "channel1",
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "user1"}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name:    name,
+				Options: options,
+			},
+		},
+	}
+}
+
+func stringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: value,
+	}
+}
+
+func TestSlashSetLangAndRecent(t *testing.T) {
+	mockLangStore := &store.MockLangStore{}
+	mockStorage := store.Storage{
+		Event: &store.MockEventStore{
+			RecentEvents: []*models.RecentChangeEvent{
+				{ID: "1", Title: "Test Page", User: "User1", Timestamp: time.Now().Unix()},
+			},
+		},
+		Lang:     mockLangStore,
+		Stat:     &store.MockStatStore{},
+		Settings: &store.MockSettingsStore{},
+	}
+
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+
+	ms := &MockInteractionSession{}
+	setLang := newSlashInteraction("setlang",
+		stringOption("language", "de"),
+		stringOption("scope", "channel"),
+	)
+	b.HandleInteraction(ms, setLang)
+	require.Len(t, ms.responses, 1)
+	assert.Contains(t, ms.responses[0].Data.Content, "You do not have permission")
+	assert.Empty(t, mockLangStore.ChannelLangs)
+
+	ms = &MockInteractionSession{}
+	setLang.Member.Permissions = discordgo.PermissionManageChannels
+	b.HandleInteraction(ms, setLang)
+	require.Len(t, ms.responses, 1)
+	assert.Contains(t, ms.responses[0].Data.Content, "channel language set to 'de'")
+	assert.Equal(t, "de", mockLangStore.ChannelLangs["channel1"])
+
+	ms = &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("recent"))
+	require.Len(t, ms.responses, 1)
+	assert.Contains(t, ms.responses[0].Data.Content, "Recent changes for 'de'")
+	assert.Zero(t, ms.responses[0].Data.Flags)
+}
+
+func TestSlashErrorsAreEphemeral(t *testing.T) {
+	mockStorage := store.Storage{
+		Event:    &store.MockEventStore{},
+		Lang:     &store.MockLangStore{},
+		Stat:     &store.MockStatStore{},
+		Settings: &store.MockSettingsStore{},
+	}
+
+	b, err := NewBot("fake-token", mockStorage, shard.Config{Count: 1})
+	require.NoError(t, err)
+
+	ms := &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("setlang", stringOption("language", "banana")))
+	require.Len(t, ms.responses, 1)
+	assert.Contains(t, ms.responses[0].Data.Content, "Unknown language code")
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, ms.responses[0].Data.Flags)
+
+	ms = &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("stats", stringOption("date", "yesterday")))
+	require.Len(t, ms.responses, 1)
+	assert.Contains(t, ms.responses[0].Data.Content, "Invalid date format")
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, ms.responses[0].Data.Flags)
+}
+
+func TestAutocomplete(t *testing.T) {
+	langOpt := stringOption("language", "ru")
+	langOpt.Focused = true
+	i := newSlashInteraction("recent", langOpt)
+	i.Type = discordgo.InteractionApplicationCommandAutocomplete
+
+	b, _ := newTestBot(t)
+	ms := &MockInteractionSession{}
+	b.handleAutocomplete(ms, i, time.Now())
+	require.Len(t, ms.responses, 1)
+	require.NotEmpty(t, ms.responses[0].Data.Choices)
+	assert.Equal(t, "ru", ms.responses[0].Data.Choices[0].Value)
+	assert.LessOrEqual(t, len(ms.responses[0].Data.Choices), maxAutocompleteChoices)
+
+	now := time.Date(2025, 2, 4, 12, 0, 0, 0, time.UTC)
+	choices := dateChoices(i18n.Localizer{}, time.UTC, "2025-02-0", now)
+	require.Len(t, choices, 4)
+	assert.Equal(t, "2025-02-04", choices[0].Value)
+	assert.Equal(t, "2025-02-04 (today)", choices[0].Name)
+	assert.Equal(t, "2025-02-01", choices[3].Value)
+
+	// Guilds with local stats days get their own today, in their language.
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	choices = dateChoices(i18n.Default().Localizer("ru"), tokyo, "", time.Date(2025, 2, 4, 20, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2025-02-05 (сегодня)", choices[0].Name)
+	assert.Equal(t, "2025-02-04 (вчера)", choices[1].Name)
+}
+
+type mockSyncer struct {
+	existing []*discordgo.ApplicationCommand
+	created  []string
+	edited   []string
+	deleted  []string
+}
+
+func (m *mockSyncer) ApplicationCommands(appID, guildID string, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	return m.existing, nil
+}
+
+func (m *mockSyncer) ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	m.created = append(m.created, cmd.Name)
+	return cmd, nil
+}
+
+func (m *mockSyncer) ApplicationCommandEdit(appID, guildID, cmdID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	m.edited = append(m.edited, cmd.Name)
+	return cmd, nil
+}
+
+func (m *mockSyncer) ApplicationCommandDelete(appID, guildID, cmdID string, options ...discordgo.RequestOption) error {
+	m.deleted = append(m.deleted, cmdID)
+	return nil
+}
+
+func TestSyncCommands(t *testing.T) {
+	want := slashCommands()
+
+	unchanged := *want[0]
+	unchanged.ID = "1"
+	changed := *want[1]
+	changed.ID = "2"
+	changed.Description = "old description"
+	stale := &discordgo.ApplicationCommand{ID: "3", Name: "oldcommand"}
+
+	syncer := &mockSyncer{existing: []*discordgo.ApplicationCommand{&unchanged, &changed, stale}}
+	require.NoError(t, syncCommands(syncer, "app", want))
+
+	assert.Equal(t, []string{want[2].Name, want[3].Name}, syncer.created)
+	assert.Equal(t, []string{want[1].Name}, syncer.edited)
+	assert.Equal(t, []string{"3"}, syncer.deleted)
+
+	syncer = &mockSyncer{existing: want}
+	require.NoError(t, syncCommands(syncer, "app", slashCommands()))
+	assert.Empty(t, syncer.created)
+	assert.Empty(t, syncer.edited)
+	assert.Empty(t, syncer.deleted)
+}
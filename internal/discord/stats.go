@@ -0,0 +1,245 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/chart"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/settings"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// maxStatsDays bounds the range of !stats and !top.
+	maxStatsDays = 92
+	// maxListedDays is the longest range whose days are listed one by one.
+	maxListedDays = 31
+	topLimit      = 10
+)
+
+// statsRange validates a from/to pair. An empty to means the single day
+// from.
+func statsRange(from, to string) (time.Time, time.Time, *argError) {
+	start, err := time.Parse(dateLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, newArgError("args.bad_date")
+	}
+	end := start
+	if to != "" {
+		if end, err = time.Parse(dateLayout, to); err != nil {
+			return time.Time{}, time.Time{}, newArgError("args.bad_date")
+		}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, newArgError("stats.end_before_start", to, from)
+	}
+	if days := int(end.Sub(start).Hours()/24) + 1; days > maxStatsDays {
+		return time.Time{}, time.Time{}, newArgError("stats.range_too_long", maxStatsDays, days)
+	}
+	return start, end, nil
+}
+
+// statsRangeReport shows daily counts between two dates, optionally with a
+// line chart attached.
+func (b *Bot) statsRangeReport(ctx context.Context, req *request, from, to, lang string, withChart bool) {
+	start, end, argErr := statsRange(from, to)
+	if argErr != nil {
+		req.Error(argErr.localize(req.loc))
+		return
+	}
+	zone := b.statsZone(ctx, req)
+	days, err := b.dailyCounts(ctx, lang, start, end, zone)
+	if err != nil {
+		req.Error(req.T("stats.error", err))
+		return
+	}
+
+	total, busiest := 0, days[0]
+	for _, d := range days {
+		total += d.Count
+		if d.Count > busiest.Count {
+			busiest = d
+		}
+	}
+	if total == 0 {
+		req.Reply(req.T("stats.range_none", lang, from, end.Format(dateLayout)))
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       req.T("stats.title", lang),
+		Description: req.N("stats.range_description", total, from, end.Format(dateLayout), lang),
+		Color:       colorStats,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: req.T("stats.field.total"), Value: fmt.Sprint(total), Inline: true},
+			{Name: req.T("stats.field.average"), Value: chart.FormatValue(float64(total) / float64(len(days))), Inline: true},
+			{Name: req.T("stats.field.busiest"), Value: fmt.Sprintf("%s (%d)", busiest.Date, busiest.Count), Inline: true},
+		},
+		Footer: zoneFooter(req.loc, zone),
+	}
+	if len(days) <= maxListedDays && !withChart {
+		var sb strings.Builder
+		for _, d := range days {
+			sb.WriteString(fmt.Sprintf("`%s` %d\n", d.Date, d.Count))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: req.T("stats.field.per_day"), Value: sb.String()})
+	}
+	if !withChart {
+		req.ReplyEmbeds("", []*discordgo.MessageEmbed{embed})
+		return
+	}
+
+	points := make([]chart.Point, len(days))
+	for i, d := range days {
+		// Drop the year; the range is in the embed.
+		points[i] = chart.Point{Label: d.Date[5:], Value: float64(d.Count)}
+	}
+	// The embed has the title; the chart font cannot draw most scripts.
+	img := chart.Line(chart.Chart{Points: points})
+	b.replyChart(req, embed, "stats.png", img)
+}
+
+// statsZone returns the zone whose days !stats dates refer to: UTC, unless
+// the guild, or in direct messages the user, asked for local days.
+func (b *Bot) statsZone(ctx context.Context, req *request) *time.Location {
+	if req.GuildID == "" {
+		us, err := b.personal.Get(ctx, req.UserID)
+		if err != nil {
+			return time.UTC
+		}
+		return settings.PersonalStatsLocation(us)
+	}
+	gs, err := b.settings.Get(ctx, req.GuildID)
+	if err != nil {
+		return time.UTC
+	}
+	return settings.StatsLocation(gs)
+}
+
+// dailyCounts returns one count per day between start and end, as days in
+// zone. UTC days are stored as such; other zones sum the hourly counts from
+// local midnight to local midnight, so days around a DST change have 23 or
+// 25 hours. In zones offset by a fraction of an hour, each hour counts
+// towards the day it starts in.
+func (b *Bot) dailyCounts(ctx context.Context, lang string, start, end time.Time, zone *time.Location) ([]models.DailyCount, error) {
+	if zone == time.UTC {
+		counts, err := b.store.Stat.GetRange(ctx, lang, start.Format(dateLayout), end.Format(dateLayout))
+		if err != nil {
+			return nil, err
+		}
+		return fillDays(start, end, counts), nil
+	}
+
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, zone)
+	to := time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, zone)
+	hours, err := b.store.Stat.GetHours(ctx, lang, from.Truncate(time.Hour), to)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]models.DailyCount, len(hours))
+	for i, h := range hours {
+		counts[i] = models.DailyCount{Date: h.Hour.In(zone).Format(dateLayout), Count: h.Count}
+	}
+	return fillDays(start, end, counts), nil
+}
+
+// zoneFooter names the zone of the days in a stats embed, which is only
+// needed when it is not UTC.
+func zoneFooter(loc i18n.Localizer, zone *time.Location) *discordgo.MessageEmbedFooter {
+	if zone == time.UTC {
+		return nil
+	}
+	return &discordgo.MessageEmbedFooter{Text: loc.T("stats.zone", zone.String())}
+}
+
+// fillDays returns one entry per day between start and end, with zero for
+// days the store has no row for. Several counts for one day are added up.
+func fillDays(start, end time.Time, counts []models.DailyCount) []models.DailyCount {
+	byDate := make(map[string]int, len(counts))
+	for _, c := range counts {
+		byDate[c.Date] += c.Count
+	}
+	var days []models.DailyCount
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format(dateLayout)
+		days = append(days, models.DailyCount{Date: date, Count: byDate[date]})
+	}
+	return days
+}
+
+// top lists the most changed articles over a day or a range, optionally
+// with a bar chart attached.
+func (b *Bot) top(ctx context.Context, req *request, lang, from, to string, withChart bool) {
+	if lang == "" {
+		lang, _ = b.resolveLang(ctx, req)
+	}
+	if from == "" {
+		from = time.Now().UTC().Format(dateLayout)
+	}
+	_, end, argErr := statsRange(from, to)
+	if argErr != nil {
+		req.Error(argErr.localize(req.loc))
+		return
+	}
+	period := from
+	if to != "" && to != from {
+		period = req.T("top.period", from, end.Format(dateLayout))
+	}
+
+	articles, err := b.store.Stat.TopArticles(ctx, lang, from, end.Format(dateLayout), topLimit)
+	if err != nil {
+		req.Error(req.T("top.error", err))
+		return
+	}
+	if len(articles) == 0 {
+		req.Reply(req.T("top.none", lang, period))
+		return
+	}
+
+	host := wikilink.Host(lang, "")
+	var sb strings.Builder
+	for i, a := range articles {
+		sb.WriteString(req.N("top.line", a.Count, i+1, a.Title, wikilink.Page(host, a.Title)) + "\n")
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       req.T("top.title", lang, period),
+		Description: sb.String(),
+		Color:       colorStats,
+	}
+	if !withChart {
+		req.ReplyEmbeds("", []*discordgo.MessageEmbed{embed})
+		return
+	}
+
+	// Bars are numbered like the list in the embed, as the chart font
+	// cannot draw titles in most scripts.
+	points := make([]chart.Point, len(articles))
+	for i, a := range articles {
+		points[i] = chart.Point{Label: fmt.Sprintf("%d.", i+1), Value: float64(a.Count)}
+	}
+	img := chart.Bar(chart.Chart{Points: points})
+	b.replyChart(req, embed, "top.png", img)
+}
+
+// replyChart attaches the chart as a PNG and shows it inside embed.
+func (b *Bot) replyChart(req *request, embed *discordgo.MessageEmbed, name string, img *image.RGBA) {
+	data, err := chart.PNG(img)
+	if err != nil {
+		req.Error(req.T("chart.error", err))
+		return
+	}
+	embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + name}
+	req.ReplyFile("", []*discordgo.MessageEmbed{embed}, &discordgo.File{
+		Name:        name,
+		ContentType: "image/png",
+		Reader:      bytes.NewReader(data),
+	})
+}
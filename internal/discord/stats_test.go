@@ -0,0 +1,157 @@
+package discord
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+func newStatsBot(t *testing.T) *Bot {
+	t.Helper()
+	b, storage := newTestBot(t)
+	stats := storage.Stat.(*store.MockStatStore)
+	stats.Stats["de_2025-02-01"] = 3
+	stats.Stats["de_2025-02-03"] = 12
+	stats.Articles = map[string]int{
+		"de_2025-02-03_Berlin":        5,
+		"de_2025-02-04_Berlin":        2,
+		"de_2025-02-04_München":       4,
+		"de_2025-02-04_Hauptseite":    1,
+		"en_2025-02-04_Not in German": 9,
+	}
+	return b
+}
+
+func isPNG(t *testing.T, f *discordgo.File) {
+	t.Helper()
+	data, err := io.ReadAll(f.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNG", string(data[:4]))
+	assert.Equal(t, "image/png", f.ContentType)
+}
+
+func TestStatsRange(t *testing.T) {
+	b := newStatsBot(t)
+	msg := recentMessage(t, b, "!stats 2025-02-01 2025-02-04 de")
+	require.Len(t, msg.Embeds, 1)
+	embed := msg.Embeds[0]
+	assert.Equal(t, "Between 2025-02-01 and 2025-02-04, there were 22 changes for language 'de'.", embed.Description)
+	assert.Equal(t, "22", embed.Fields[0].Value)
+	assert.Equal(t, "5.5", embed.Fields[1].Value)
+	assert.Equal(t, "2025-02-03 (12)", embed.Fields[2].Value)
+	// Days without a row are listed as zero.
+	assert.Contains(t, embed.Fields[3].Value, "`2025-02-02` 0")
+	assert.Empty(t, msg.Files)
+}
+
+func TestStatsRangeChart(t *testing.T) {
+	b := newStatsBot(t)
+	msg := recentMessage(t, b, "!stats 2025-02-01 2025-02-04 de chart")
+	require.Len(t, msg.Files, 1)
+	assert.Equal(t, "stats.png", msg.Files[0].Name)
+	isPNG(t, msg.Files[0])
+	require.NotNil(t, msg.Embeds[0].Image)
+	assert.Equal(t, "attachment://stats.png", msg.Embeds[0].Image.URL)
+}
+
+func TestStatsRangeErrors(t *testing.T) {
+	b := newStatsBot(t)
+	assert.Contains(t, sendCommand(b, "!stats 2025-02-04 de chart")[0], "Charts need a range")
+	assert.Contains(t, sendCommand(b, "!stats 2025-02-04 2025-02-01")[0], "before the start date")
+	assert.Contains(t, sendCommand(b, "!stats 2025-01-01 2025-06-01")[0], "at most 92 days")
+	assert.Contains(t, sendCommand(b, "!stats 2024-01-01 2024-01-31 de")[0], "No stats found")
+
+	// The same date twice is a single day.
+	msg := recentMessage(t, b, "!stats 2025-02-03 2025-02-03 de")
+	assert.Contains(t, msg.Embeds[0].Description, "12 changes")
+}
+
+func TestStatsLocalDays(t *testing.T) {
+	b, storage := newTestBot(t)
+	stats := storage.Stat.(*store.MockStatStore)
+	// One change every hour; Berlin moves its clocks forward on 2025-03-30.
+	start := time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC)
+	for h := start; h.Before(start.AddDate(0, 0, 4)); h = h.Add(time.Hour) {
+		require.NoError(t, stats.IncrementHour(context.Background(), "de", h))
+	}
+
+	// UTC days come from the daily counts, which this test has none of.
+	assert.Contains(t, sendCommand(b, "!stats 2025-03-30 de")[0], "No stats found")
+
+	sendCommand(b, "!config set timezone Europe/Berlin")
+	assert.Contains(t, sendCommand(b, "!stats 2025-03-30 de")[0], "No stats found",
+		"a timezone alone does not change stats days")
+	assert.Contains(t, sendCommand(b, "!config set stats_days local")[0], "local")
+
+	msg := recentMessage(t, b, "!stats 2025-03-30 de")
+	require.Len(t, msg.Embeds, 1)
+	assert.Contains(t, msg.Embeds[0].Description, "23 changes")
+	assert.Equal(t, "Days in Europe/Berlin time.", msg.Embeds[0].Footer.Text)
+
+	msg = recentMessage(t, b, "!stats 2025-03-29 2025-03-31 de")
+	require.Len(t, msg.Embeds, 1)
+	embed := msg.Embeds[0]
+	assert.Equal(t, "71", embed.Fields[0].Value)
+	assert.Equal(t, "`2025-03-29` 24\n`2025-03-30` 23\n`2025-03-31` 24\n", embed.Fields[3].Value)
+	assert.Equal(t, "Days in Europe/Berlin time.", embed.Footer.Text)
+
+	assert.Contains(t, sendCommand(b, "!stats 2025-04-10 de")[0], "No stats found")
+}
+
+func TestTop(t *testing.T) {
+	b := newStatsBot(t)
+	msg := recentMessage(t, b, "!top de 2025-02-03 2025-02-04")
+	require.Len(t, msg.Embeds, 1)
+	embed := msg.Embeds[0]
+	assert.Equal(t, "Most changed articles on 'de', 2025-02-03 to 2025-02-04", embed.Title)
+	assert.Equal(t, "1. [Berlin](https://de.wikipedia.org/wiki/Berlin) — 7 changes\n"+
+		"2. [München](https://de.wikipedia.org/wiki/M%C3%BCnchen) — 4 changes\n"+
+		"3. [Hauptseite](https://de.wikipedia.org/wiki/Hauptseite) — 1 change\n", embed.Description)
+	assert.Empty(t, msg.Files)
+
+	msg = recentMessage(t, b, "!top de 2025-02-04 chart")
+	require.Len(t, msg.Files, 1)
+	assert.Equal(t, "top.png", msg.Files[0].Name)
+	isPNG(t, msg.Files[0])
+	assert.Equal(t, "attachment://top.png", msg.Embeds[0].Image.URL)
+
+	assert.Contains(t, sendCommand(b, "!top de 2024-01-01")[0], "No article stats found for de on 2024-01-01")
+}
+
+func TestSlashStatsChart(t *testing.T) {
+	b := newStatsBot(t)
+	ms := &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("stats",
+		stringOption("date", "2025-02-01"),
+		stringOption("to", "2025-02-04"),
+		stringOption("language", "de"),
+		&discordgo.ApplicationCommandInteractionDataOption{
+			Name:  "chart",
+			Type:  discordgo.ApplicationCommandOptionBoolean,
+			Value: true,
+		},
+	))
+	require.Len(t, ms.responses, 1)
+	require.Len(t, ms.responses[0].Data.Files, 1)
+	assert.Equal(t, "stats.png", ms.responses[0].Data.Files[0].Name)
+
+	ms = &MockInteractionSession{}
+	b.HandleInteraction(ms, newSlashInteraction("stats",
+		stringOption("date", "2025-02-01"),
+		stringOption("to", "2025-02-04"),
+		stringOption("language", "de"),
+		&discordgo.ApplicationCommandInteractionDataOption{
+			Name:  "chart",
+			Type:  discordgo.ApplicationCommandOptionBoolean,
+			Value: false,
+		},
+	))
+	require.Len(t, ms.responses, 1)
+	assert.Empty(t, ms.responses[0].Data.Files)
+}
@@ -0,0 +1,116 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"github.com/vlkhvnn/TestON/internal/watch"
+)
+
+const (
+	// maxWatchesPerChannel keeps a single channel from flooding itself.
+	maxWatchesPerChannel = 200
+	// maxPersonalWatches is the limit for a user's direct messages, where
+	// one person sets up every watch.
+	maxPersonalWatches = 50
+)
+
+func (b *Bot) addWatch(ctx context.Context, req *request, kind, lang, target string) {
+	target = watch.NormalizeTitle(target)
+	if lang == "" || lang == "all" {
+		lang = models.WatchAllLangs
+	}
+
+	existing, err := b.store.Watch.ListByChannel(ctx, req.ChannelID)
+	if err != nil {
+		req.Error(req.T("watch.error", err))
+		return
+	}
+	limit := maxWatchesPerChannel
+	if req.GuildID == "" {
+		limit = maxPersonalWatches
+	}
+	if len(existing) >= limit {
+		req.Error(req.N("watch.too_many", len(existing)))
+		return
+	}
+
+	w := &models.Watch{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		CreatedBy: req.UserID,
+		Kind:      kind,
+		Lang:      lang,
+		Target:    target,
+	}
+	if err := b.store.Watch.Add(ctx, w); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			req.Error(req.T("watch.exists", describeWatch(req.loc, w)))
+			return
+		}
+		req.Error(req.T("watch.add_failed", err))
+		return
+	}
+	b.watches.Add(w)
+	req.Reply(req.T("watch.added", describeWatch(req.loc, w)))
+}
+
+func (b *Bot) removeWatch(ctx context.Context, req *request, kind, lang, target string) {
+	target = watch.NormalizeTitle(target)
+	if lang == "" || lang == "all" {
+		lang = models.WatchAllLangs
+	}
+	w := &models.Watch{Kind: kind, Lang: lang, Target: target}
+
+	if err := b.store.Watch.Delete(ctx, req.ChannelID, kind, lang, target); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			req.Error(req.T("watch.missing", describeWatch(req.loc, w)))
+			return
+		}
+		req.Error(req.T("watch.remove_failed", err))
+		return
+	}
+	b.watches.Remove(req.ChannelID, kind, lang, target)
+	req.Reply(req.T("watch.removed", describeWatch(req.loc, w)))
+}
+
+func (b *Bot) watchlist(ctx context.Context, req *request) {
+	watches, err := b.store.Watch.ListByChannel(ctx, req.ChannelID)
+	if err != nil {
+		req.Error(req.T("watch.error", err))
+		return
+	}
+	if len(watches) == 0 {
+		req.Reply(req.T("watch.none", req.prefix))
+		return
+	}
+
+	header := req.T("watch.header") + "\n"
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, w := range watches {
+		entry := "• " + describeWatch(req.loc, w) + "\n"
+		if sb.Len()+len(entry) > 2000 {
+			req.Reply(sb.String())
+			sb.Reset()
+			sb.WriteString(header)
+		}
+		sb.WriteString(entry)
+	}
+	req.Reply(sb.String())
+}
+
+func describeWatch(loc i18n.Localizer, w *models.Watch) string {
+	wikis := w.Lang
+	if w.Lang == models.WatchAllLangs {
+		wikis = loc.T("watch.all_wikis")
+	}
+	if w.Kind == models.WatchKindUser {
+		return loc.T("watch.user", w.Target, wikis)
+	}
+	return loc.T("watch.page", w.Target, wikis)
+}
@@ -0,0 +1,402 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/score"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultInterval is the minimum time between two posts to one channel.
+	// Edits arriving in between are batched into the next post.
+	DefaultInterval = 5 * time.Second
+	// maxPending bounds the backlog of a channel; newer edits are counted
+	// and reported as skipped instead.
+	maxPending = 200
+	// backfillPage is how many stored events are read at a time when
+	// catching up after a restart.
+	backfillPage  = 500
+	maxMessageLen = 2000
+	maxCommentLen = 120
+	tickInterval  = time.Second
+)
+
+// Sender queues messages for delivery, such as *outbound.Dispatcher. Feeds
+// do not wait for their posts, so one slow channel does not hold up the
+// others; the sender retries and reports failed posts itself.
+type Sender interface {
+	Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error
+}
+
+// EventSource returns stored events for backfilling after a restart.
+type EventSource interface {
+	GetSince(ctx context.Context, lang string, timestamp int64, limit int) ([]*models.RecentChangeEvent, error)
+}
+
+// CursorStore persists the last event posted to each feed channel.
+type CursorStore interface {
+	UpdateCursor(ctx context.Context, channelID string, timestamp int64, eventID string) error
+}
+
+type channelFeed struct {
+	feed    *models.Feed
+	filter  Filter
+	pending []*models.RecentChangeEvent
+	skipped int
+	// next is the earliest time the channel may be posted to again.
+	next time.Time
+	// lastID is the last event enqueued, to drop duplicates between the
+	// backfill and the live stream.
+	lastID string
+	// gap is set when the backfill found the cursor event pruned, so edits
+	// after it may be missing.
+	gap bool
+}
+
+// ZoneFunc returns the timezone a guild's feed lines show times in.
+type ZoneFunc func(ctx context.Context, guildID string) *time.Location
+
+// LocaleFunc returns the localizer for messages posted to a channel of a
+// guild.
+type LocaleFunc func(ctx context.Context, guildID, channelID string) i18n.Localizer
+
+// Manager runs the live feeds. Handle only filters and buffers events, so
+// ingestion is never blocked by Discord; Run posts the buffers, at most
+// once per interval and channel.
+type Manager struct {
+	events   EventSource
+	cursors  CursorStore
+	interval time.Duration
+	now      func() time.Time
+	zone     ZoneFunc
+	locale   LocaleFunc
+
+	mu    sync.Mutex
+	feeds map[string]*channelFeed
+}
+
+func NewManager(events EventSource, cursors CursorStore) *Manager {
+	return &Manager{
+		events:   events,
+		cursors:  cursors,
+		interval: DefaultInterval,
+		now:      time.Now,
+		zone:     func(context.Context, string) *time.Location { return time.UTC },
+		locale:   func(context.Context, string, string) i18n.Localizer { return i18n.Localizer{} },
+		feeds:    make(map[string]*channelFeed),
+	}
+}
+
+// SetZones makes feed lines show times in the zone fn returns for the
+// feed's guild instead of UTC. It must be called before Run.
+func (m *Manager) SetZones(fn ZoneFunc) {
+	m.zone = fn
+}
+
+// SetLocales makes feed posts use the language fn returns for their channel
+// instead of English. It must be called before Run.
+func (m *Manager) SetLocales(fn LocaleFunc) {
+	m.locale = fn
+}
+
+// Load registers stored feeds and queues the events each one missed since
+// its cursor. It must run before the stream starts delivering events.
+func (m *Manager) Load(ctx context.Context, feeds []*models.Feed) error {
+	for _, f := range feeds {
+		if err := m.Start(f); err != nil {
+			return fmt.Errorf("feed for channel %s: %w", f.ChannelID, err)
+		}
+		if f.CursorTimestamp == 0 {
+			continue
+		}
+		if err := m.backfill(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfill queues every stored event after f's cursor, a page at a time;
+// edits beyond the backlog limit are counted as skipped. Events are kept
+// for store.EventRetention, so if the cursor event is gone the feed was
+// down for longer and the next post says edits may be missing.
+func (m *Manager) backfill(ctx context.Context, f *models.Feed) error {
+	timestamp, eventID := f.CursorTimestamp, f.CursorEventID
+	for first := true; ; first = false {
+		events, err := m.events.GetSince(ctx, f.Lang, timestamp, backfillPage)
+		if err != nil {
+			return err
+		}
+		missed, found := afterCursor(events, timestamp, eventID)
+		if first && !found {
+			m.mu.Lock()
+			if cf, ok := m.feeds[f.ChannelID]; ok {
+				cf.gap = true
+			}
+			m.mu.Unlock()
+		}
+		for _, e := range missed {
+			m.Handle(f.Lang, e)
+		}
+		if len(events) < backfillPage || len(missed) == 0 {
+			return nil
+		}
+		last := missed[len(missed)-1]
+		timestamp, eventID = last.Timestamp, last.ID.String()
+	}
+}
+
+// afterCursor drops the events up to and including the cursor and reports
+// whether the cursor event was found. events are ordered oldest first; if
+// the cursor event itself is no longer stored, everything from its second
+// is treated as already posted.
+func afterCursor(events []*models.RecentChangeEvent, timestamp int64, eventID string) ([]*models.RecentChangeEvent, bool) {
+	start := len(events)
+	for i, e := range events {
+		if e.Timestamp > timestamp {
+			start = i
+			break
+		}
+	}
+	for i, e := range events[:start] {
+		if e.ID.String() == eventID {
+			return events[i+1:], true
+		}
+	}
+	return events[start:], false
+}
+
+// Start registers f, replacing any feed already running in its channel.
+func (m *Manager) Start(f *models.Feed) error {
+	filter, err := ParseFilter(f.Filters)
+	if err != nil {
+		return err
+	}
+	cp := *f
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feeds[f.ChannelID] = &channelFeed{feed: &cp, filter: filter}
+	return nil
+}
+
+// Stop removes the channel's feed and drops its buffer.
+func (m *Manager) Stop(channelID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.feeds[channelID]
+	delete(m.feeds, channelID)
+	return ok
+}
+
+// Get returns a copy of the channel's feed.
+func (m *Manager) Get(channelID string) (*models.Feed, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cf, ok := m.feeds[channelID]
+	if !ok {
+		return nil, false
+	}
+	cp := *cf.feed
+	return &cp, true
+}
+
+// Pending returns the number of edits waiting to be posted to the channel.
+func (m *Manager) Pending(channelID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cf, ok := m.feeds[channelID]; ok {
+		return len(cf.pending)
+	}
+	return 0
+}
+
+// Handle is called for every ingested event.
+func (m *Manager) Handle(lang string, event *models.RecentChangeEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, cf := range m.feeds {
+		if cf.feed.Lang != lang || !cf.filter.Match(event) {
+			continue
+		}
+		id := event.ID.String()
+		if id != "" && id == cf.lastID {
+			continue
+		}
+		cf.lastID = id
+		if len(cf.pending) >= maxPending {
+			cf.skipped++
+			continue
+		}
+		cf.pending = append(cf.pending, event)
+	}
+}
+
+// Run posts buffered edits until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context, sender Sender, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.flush(ctx, sender, logger)
+		}
+	}
+}
+
+type batch struct {
+	cf      *channelFeed
+	content string
+	count   int
+	last    *models.RecentChangeEvent
+}
+
+// flush queues one message for every channel that has edits waiting and is
+// not throttled. The edits count as posted once queued; a post the sender
+// cannot queue is tried again after the interval.
+func (m *Manager) flush(ctx context.Context, sender Sender, logger *zap.SugaredLogger) {
+	now := m.now()
+	zones, locales := m.lookup(ctx, now)
+	var batches []batch
+	m.mu.Lock()
+	for _, cf := range m.feeds {
+		if len(cf.pending) == 0 || now.Before(cf.next) {
+			continue
+		}
+		cf.next = now.Add(m.interval)
+		zone, ok := zones[cf.feed.GuildID]
+		if !ok {
+			// Started after the zones were looked up; the next post
+			// will use its own.
+			zone = time.UTC
+		}
+		// A missing localizer is English.
+		loc := locales[cf.feed.ChannelID]
+		content, count := formatBatch(loc, cf.feed.Lang, zone, cf.pending, cf.skipped, cf.gap)
+		b := batch{cf: cf, content: content, count: count}
+		if count > 0 {
+			b.last = cf.pending[count-1]
+		}
+		batches = append(batches, b)
+	}
+	m.mu.Unlock()
+
+	for _, b := range batches {
+		channelID := b.cf.feed.ChannelID
+		if err := sender.Enqueue(channelID, &discordgo.MessageSend{Content: b.content}); err != nil {
+			logger.Errorw("Failed to queue feed update", "channel", channelID, "error", err)
+			continue
+		}
+
+		m.mu.Lock()
+		if m.feeds[channelID] != b.cf {
+			// Stopped or restarted meanwhile.
+			m.mu.Unlock()
+			continue
+		}
+		b.cf.pending = b.cf.pending[b.count:]
+		b.cf.skipped = 0
+		b.cf.gap = false
+		if b.last != nil {
+			b.cf.feed.CursorTimestamp = b.last.Timestamp
+			b.cf.feed.CursorEventID = b.last.ID.String()
+		}
+		m.mu.Unlock()
+
+		if b.last != nil {
+			if err := m.cursors.UpdateCursor(ctx, channelID, b.last.Timestamp, b.last.ID.String()); err != nil {
+				logger.Errorw("Failed to save feed cursor", "channel", channelID, "error", err)
+			}
+		}
+	}
+}
+
+// lookup finds the timezone of every guild and the language of every
+// channel with a feed due at now. It runs without holding the lock, as the
+// lookups may hit the database.
+func (m *Manager) lookup(ctx context.Context, now time.Time) (map[string]*time.Location, map[string]i18n.Localizer) {
+	var due []models.Feed
+	m.mu.Lock()
+	for _, cf := range m.feeds {
+		if len(cf.pending) > 0 && !now.Before(cf.next) {
+			due = append(due, *cf.feed)
+		}
+	}
+	m.mu.Unlock()
+
+	zones := make(map[string]*time.Location, len(due))
+	locales := make(map[string]i18n.Localizer, len(due))
+	for _, f := range due {
+		if _, ok := zones[f.GuildID]; !ok {
+			zones[f.GuildID] = m.zone(ctx, f.GuildID)
+		}
+		locales[f.ChannelID] = m.locale(ctx, f.GuildID, f.ChannelID)
+	}
+	return zones, locales
+}
+
+// formatBatch renders as many pending edits as fit in one message and
+// returns how many it used. A single edit is posted on its own line;
+// several get a header. gap adds a note that older edits may be missing.
+func formatBatch(loc i18n.Localizer, lang string, zone *time.Location, pending []*models.RecentChangeEvent, skipped int, gap bool) (string, int) {
+	var footer string
+	if skipped > 0 {
+		footer = loc.N("feed.skipped", skipped)
+	}
+	if gap {
+		footer = strings.TrimPrefix(footer+"\n"+loc.T("feed.gap"), "\n")
+	}
+	if len(pending) == 1 {
+		return strings.TrimSuffix(formatLine(loc, lang, zone, pending[0])+"\n"+footer, "\n"), 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString(loc.T("feed.batch_header", lang) + "\n")
+	count := 0
+	for _, e := range pending {
+		line := formatLine(loc, lang, zone, e) + "\n"
+		if sb.Len()+len(line)+len(footer) > maxMessageLen && count > 0 {
+			break
+		}
+		sb.WriteString(line)
+		count++
+	}
+	sb.WriteString(footer)
+	return strings.TrimSuffix(sb.String(), "\n"), count
+}
+
+// formatLine renders one edit with its time in zone, naming the zone unless
+// it is UTC.
+func formatLine(loc i18n.Localizer, lang string, zone *time.Location, e *models.RecentChangeEvent) string {
+	links := wikilink.For(lang, e)
+	layout := "15:04:05"
+	if zone != time.UTC {
+		layout += " MST"
+	}
+	t := time.Unix(e.Timestamp, 0).In(zone).Format(layout)
+
+	line := loc.T("feed.line", t, e.Title, links.Page, e.User)
+	if inline := links.Inline(loc); inline != "" {
+		line += " · " + inline
+	}
+	if e.Score > score.Suspicious {
+		line += fmt.Sprintf(" · ⚠️ %.2f", e.Score)
+	}
+	if comment := strings.TrimSpace(e.Comment); comment != "" {
+		if r := []rune(comment); len(r) > maxCommentLen {
+			comment = string(r[:maxCommentLen]) + "…"
+		}
+		line += " — " + comment
+	}
+	return line
+}
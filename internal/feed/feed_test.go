@@ -0,0 +1,373 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/outbound"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"go.uber.org/zap"
+)
+
+func event(id int, ts int64, title string) *models.RecentChangeEvent {
+	return &models.RecentChangeEvent{
+		ID:        json.Number(fmt.Sprint(id)),
+		Type:      "edit",
+		Title:     title,
+		User:      "Alice",
+		Timestamp: ts,
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("TYPE=edit minor=no user!=SomeBot title~election")
+	require.NoError(t, err)
+	assert.Equal(t, "type=edit minor=false user!=SomeBot title~election", f.String())
+
+	f, err = ParseFilter("user=Jimbo Wales bot=false")
+	require.NoError(t, err)
+	require.Len(t, f, 2)
+	assert.Equal(t, "Jimbo Wales", f[0].Value)
+
+	f, err = ParseFilter("")
+	require.NoError(t, err)
+	assert.Empty(t, f)
+
+	for input, msg := range map[string]string{
+		"edit":          `"edit" is not key=value`,
+		"size=10":       "unknown key",
+		"minor>true":    "minor only supports = and !=",
+		"bot=maybe":     "bot must be true or false",
+		"title>Foo":     "title does not support >",
+		"user=":         "user needs a value",
+		"minor=true Ok": `"Ok" is not key=value`,
+	} {
+		_, err := ParseFilter(input)
+		require.Error(t, err, input)
+		assert.Contains(t, err.Error(), msg, input)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	e := &models.RecentChangeEvent{Type: "edit", Title: "2024 Election", User: "192.0.2.1", Comment: "fix typo"}
+
+	cases := map[string]bool{
+		"":                      true,
+		"type=edit":             true,
+		"type=new":              false,
+		"type!=new":             true,
+		"title~ELECTION":        true,
+		"title!~election":       false,
+		"anon=true":             true,
+		"anon=true minor=true":  false,
+		"comment=fix typo":      true,
+		"user=192.0.2.1 bot=no": true,
+	}
+	for input, want := range cases {
+		f, err := ParseFilter(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, f.Match(e), input)
+	}
+}
+
+func TestNumberField(t *testing.T) {
+	f, err := ParseFilter("delta>-100 delta<=500")
+	require.NoError(t, err)
+	assert.True(t, f.Match(&models.RecentChangeEvent{Length: models.EventLength{Old: 1000, New: 1500}}))
+	assert.False(t, f.Match(&models.RecentChangeEvent{Length: models.EventLength{Old: 1000, New: 1501}}))
+	assert.False(t, f.Match(&models.RecentChangeEvent{Length: models.EventLength{Old: 1000, New: 900}}))
+
+	f, err = ParseFilter("score>0.7")
+	require.NoError(t, err)
+	assert.True(t, f.Match(&models.RecentChangeEvent{Score: 0.94}))
+	assert.False(t, f.Match(&models.RecentChangeEvent{Score: 0.7}))
+
+	_, err = ParseFilter("delta~3")
+	assert.EqualError(t, err, "delta does not support ~")
+	_, err = ParseFilter("delta>abc")
+	assert.EqualError(t, err, "delta must be a number")
+}
+
+type mockSender struct {
+	sent map[string][]string
+	err  error
+}
+
+func (s *mockSender) Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.sent == nil {
+		s.sent = make(map[string][]string)
+	}
+	s.sent[channelID] = append(s.sent[channelID], m.Content)
+	return nil
+}
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestManager(events []*models.RecentChangeEvent) (*Manager, *store.MockFeedStore, *clock) {
+	feeds := &store.MockFeedStore{}
+	m := NewManager(&store.MockEventStore{RecentEvents: events}, feeds)
+	c := &clock{t: time.Unix(1700000000, 0)}
+	m.now = c.now
+	return m, feeds, c
+}
+
+func startFeed(t *testing.T, m *Manager, feeds *store.MockFeedStore, f *models.Feed) {
+	t.Helper()
+	require.NoError(t, feeds.Save(context.Background(), f))
+	require.NoError(t, m.Start(f))
+}
+
+func TestManagerThrottlesAndBatches(t *testing.T) {
+	m, feeds, c := newTestManager(nil)
+	startFeed(t, m, feeds, &models.Feed{ChannelID: "c1", Lang: "en", Filters: "type=edit"})
+	sender := &mockSender{}
+	logger := zap.NewNop().Sugar()
+	ctx := context.Background()
+
+	m.Handle("en", event(1, 100, "First"))
+	m.Handle("de", event(2, 100, "Other language"))
+	m.Handle("en", &models.RecentChangeEvent{ID: "3", Type: "log", Title: "Filtered"})
+	m.flush(ctx, sender, logger)
+	require.Len(t, sender.sent["c1"], 1)
+	assert.Contains(t, sender.sent["c1"][0], "[First](<https://en.wikipedia.org/wiki/First>) by **Alice**")
+	assert.NotContains(t, sender.sent["c1"][0], "Live edits")
+
+	// Within the interval nothing is posted; the edits are batched.
+	m.Handle("en", event(4, 101, "Second"))
+	m.Handle("en", event(5, 102, "Third"))
+	m.flush(ctx, sender, logger)
+	assert.Len(t, sender.sent["c1"], 1)
+	assert.Equal(t, 2, m.Pending("c1"))
+
+	c.t = c.t.Add(DefaultInterval)
+	m.flush(ctx, sender, logger)
+	require.Len(t, sender.sent["c1"], 2)
+	assert.Contains(t, sender.sent["c1"][1], "📰 Live edits on en:")
+	assert.Contains(t, sender.sent["c1"][1], "Second")
+	assert.Contains(t, sender.sent["c1"][1], "Third")
+	assert.Zero(t, m.Pending("c1"))
+
+	assert.Equal(t, int64(102), feeds.Feeds["c1"].CursorTimestamp)
+	assert.Equal(t, "5", feeds.Feeds["c1"].CursorEventID)
+}
+
+func TestManagerSplitsLongBatches(t *testing.T) {
+	m, feeds, _ := newTestManager(nil)
+	startFeed(t, m, feeds, &models.Feed{ChannelID: "c1", Lang: "en"})
+	for i := 0; i < maxPending+5; i++ {
+		e := event(i+1, int64(100+i), strings.Repeat("Long title ", 5))
+		e.Comment = strings.Repeat("x", maxCommentLen+10)
+		m.Handle("en", e)
+	}
+	assert.Equal(t, maxPending, m.Pending("c1"))
+
+	sender := &mockSender{}
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	require.Len(t, sender.sent["c1"], 1)
+	msg := sender.sent["c1"][0]
+	assert.LessOrEqual(t, len(msg), maxMessageLen)
+	assert.Contains(t, msg, "…5 more edits skipped")
+	assert.Contains(t, msg, strings.Repeat("x", maxCommentLen)+"…")
+	assert.Greater(t, m.Pending("c1"), 0, "edits that did not fit wait for the next post")
+}
+
+func TestManagerKeepsEditsItCannotQueue(t *testing.T) {
+	m, feeds, c := newTestManager(nil)
+	startFeed(t, m, feeds, &models.Feed{ChannelID: "c1", Lang: "en"})
+	sender := &mockSender{err: outbound.ErrQueueFull}
+	logger := zap.NewNop().Sugar()
+
+	m.Handle("en", event(1, 100, "First"))
+	m.flush(context.Background(), sender, logger)
+	assert.Equal(t, 1, m.Pending("c1"))
+	assert.Zero(t, feeds.Feeds["c1"].CursorTimestamp)
+
+	// The queue has room again after the interval.
+	sender.err = nil
+	c.t = c.t.Add(DefaultInterval)
+	m.flush(context.Background(), sender, logger)
+	require.Len(t, sender.sent["c1"], 1)
+	assert.Zero(t, m.Pending("c1"))
+	assert.Equal(t, int64(100), feeds.Feeds["c1"].CursorTimestamp)
+}
+
+func TestManagerStop(t *testing.T) {
+	m, feeds, _ := newTestManager(nil)
+	startFeed(t, m, feeds, &models.Feed{ChannelID: "c1", Lang: "en"})
+	m.Handle("en", event(1, 100, "First"))
+
+	assert.True(t, m.Stop("c1"))
+	assert.False(t, m.Stop("c1"))
+	_, ok := m.Get("c1")
+	assert.False(t, ok)
+
+	sender := &mockSender{}
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	assert.Empty(t, sender.sent)
+}
+
+func TestManagerLoadResumesAfterCursor(t *testing.T) {
+	stored := []*models.RecentChangeEvent{
+		event(1, 100, "Posted"),
+		event(2, 200, "Posted too"),
+		event(3, 200, "Missed same second"),
+		event(4, 300, "Missed"),
+	}
+	m, _, _ := newTestManager(stored)
+	err := m.Load(context.Background(), []*models.Feed{
+		{ChannelID: "c1", Lang: "en", CursorTimestamp: 200, CursorEventID: "2"},
+		{ChannelID: "c2", Lang: "en"},
+	})
+	require.NoError(t, err)
+
+	sender := &mockSender{}
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	require.Len(t, sender.sent["c1"], 1)
+	msg := sender.sent["c1"][0]
+	assert.NotContains(t, msg, "[Posted")
+	assert.Contains(t, msg, "Missed same second")
+	assert.Contains(t, msg, "[Missed]")
+	assert.Empty(t, sender.sent["c2"], "feeds without a cursor start live")
+
+	// The live stream repeating the last backfilled event is ignored.
+	m.Handle("en", event(4, 300, "Missed"))
+	assert.Zero(t, m.Pending("c1"))
+}
+
+func TestAfterCursor(t *testing.T) {
+	events := []*models.RecentChangeEvent{event(1, 100, "a"), event(2, 100, "b"), event(3, 101, "c")}
+	missed, found := afterCursor(events, 100, "1")
+	assert.Len(t, missed, 2)
+	assert.True(t, found)
+	missed, _ = afterCursor(events, 100, "2")
+	assert.Len(t, missed, 1)
+	missed, found = afterCursor(events, 100, "gone")
+	assert.Len(t, missed, 1, "unknown cursor skips its whole second")
+	assert.False(t, found)
+	missed, _ = afterCursor(events, 101, "3")
+	assert.Empty(t, missed)
+}
+
+func TestLoadBackfillsEveryPage(t *testing.T) {
+	var stored []*models.RecentChangeEvent
+	for i := 1; i <= backfillPage+50; i++ {
+		stored = append(stored, event(i, int64(100+i), fmt.Sprint("Page ", i)))
+	}
+	m, _, _ := newTestManager(stored)
+	require.NoError(t, m.Load(context.Background(), []*models.Feed{
+		{ChannelID: "c1", Lang: "en", CursorTimestamp: 101, CursorEventID: "1"},
+	}))
+
+	assert.Equal(t, maxPending, m.Pending("c1"))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assert.Equal(t, backfillPage+49-maxPending, m.feeds["c1"].skipped, "edits past the first page are counted")
+	assert.False(t, m.feeds["c1"].gap)
+}
+
+func TestLoadReportsPrunedCursor(t *testing.T) {
+	m, _, _ := newTestManager([]*models.RecentChangeEvent{event(5, 500, "Kept")})
+	require.NoError(t, m.Load(context.Background(), []*models.Feed{
+		{ChannelID: "c1", Lang: "en", CursorTimestamp: 100, CursorEventID: "1"},
+	}))
+
+	sender := &mockSender{}
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	require.Len(t, sender.sent["c1"], 1)
+	assert.Contains(t, sender.sent["c1"][0], "[Kept]")
+	assert.Contains(t, sender.sent["c1"][0], "some earlier edits may be missing")
+
+	m.Handle("en", event(6, 600, "Live"))
+	m.now = func() time.Time { return time.Now().Add(time.Hour) }
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	require.Len(t, sender.sent["c1"], 2)
+	assert.NotContains(t, sender.sent["c1"][1], "missing", "the gap is reported once")
+}
+
+func TestLoadRejectsInvalidFilters(t *testing.T) {
+	m, _, _ := newTestManager(nil)
+	err := m.Load(context.Background(), []*models.Feed{{ChannelID: "c1", Lang: "en", Filters: "bogus"}})
+	assert.ErrorContains(t, err, "feed for channel c1")
+}
+
+func TestFormatLineLinksTheChange(t *testing.T) {
+	e := event(1, 0, "Cat")
+	e.ServerName = "en.wiktionary.org"
+	e.Revision = models.Revision{Old: 3, New: 4}
+	assert.Equal(t, "`00:00:00` [Cat](<https://en.wiktionary.org/wiki/Cat>) by **Alice** · "+
+		"[diff](<https://en.wiktionary.org/w/index.php?diff=4&oldid=3>) · "+
+		"[history](<https://en.wiktionary.org/w/index.php?action=history&oldid=4>)", formatLine(i18n.Localizer{}, "en", time.UTC, e))
+}
+
+func TestFormatLineFlagsSuspiciousEdits(t *testing.T) {
+	e := event(1, 0, "Cat")
+	e.Score = 0.7
+	assert.NotContains(t, formatLine(i18n.Localizer{}, "en", time.UTC, e), "⚠️")
+	e.Score = 0.94
+	assert.Contains(t, formatLine(i18n.Localizer{}, "en", time.UTC, e), "by **Alice** · ⚠️ 0.94")
+}
+
+func TestFeedShowsTimesInGuildZone(t *testing.T) {
+	m, feeds, _ := newTestManager(nil)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	m.SetZones(func(ctx context.Context, guildID string) *time.Location {
+		if guildID == "g1" {
+			return tokyo
+		}
+		return time.UTC
+	})
+	startFeed(t, m, feeds, &models.Feed{GuildID: "g1", ChannelID: "c1", Lang: "en"})
+	startFeed(t, m, feeds, &models.Feed{GuildID: "g2", ChannelID: "c2", Lang: "en"})
+	sender := &mockSender{}
+
+	m.Handle("en", event(1, 0, "Cat"))
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+	assert.True(t, strings.HasPrefix(sender.sent["c1"][0], "`09:00:00 JST` [Cat]"), sender.sent["c1"][0])
+	assert.True(t, strings.HasPrefix(sender.sent["c2"][0], "`00:00:00` [Cat]"), sender.sent["c2"][0])
+}
+
+func TestFeedPostsInTheChannelLanguage(t *testing.T) {
+	m, feeds, _ := newTestManager(nil)
+	m.SetLocales(func(ctx context.Context, guildID, channelID string) i18n.Localizer {
+		if channelID == "c1" {
+			return i18n.Default().Localizer("ru")
+		}
+		return i18n.Localizer{}
+	})
+	startFeed(t, m, feeds, &models.Feed{GuildID: "g1", ChannelID: "c1", Lang: "ru"})
+	sender := &mockSender{}
+
+	cat := event(1, 0, "Кошка")
+	cat.Revision = models.Revision{Old: 3, New: 4}
+	m.Handle("ru", cat)
+	m.Handle("ru", event(2, 0, "Собака"))
+	m.flush(context.Background(), sender, zap.NewNop().Sugar())
+
+	require.Len(t, sender.sent["c1"], 1)
+	msg := sender.sent["c1"][0]
+	assert.True(t, strings.HasPrefix(msg, "📰 Правки в ru в реальном времени:\n"), msg)
+	assert.Contains(t, msg, "автор **Alice** · [разница]")
+}
+
+func TestFormatBatchCountsSkippedEdits(t *testing.T) {
+	pending := []*models.RecentChangeEvent{event(1, 0, "Cat")}
+	content, _ := formatBatch(i18n.Default().Localizer("ru"), "ru", time.UTC, pending, 3, false)
+	assert.True(t, strings.HasSuffix(content, "\n…ещё 3 правки пропущены, потому что лента перегружена."), content)
+	content, _ = formatBatch(i18n.Localizer{}, "en", time.UTC, pending, 1, false)
+	assert.True(t, strings.HasSuffix(content, "\n…1 more edit skipped because the feed is busy."), content)
+}
@@ -0,0 +1,214 @@
+package feed
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindNumber
+)
+
+// field describes one filterable property of an event. Number fields are
+// compared numerically and accept the ordering operators.
+type field struct {
+	kind   fieldKind
+	text   func(e *models.RecentChangeEvent) string
+	number func(e *models.RecentChangeEvent) float64
+}
+
+var fields = map[string]field{
+	"type":    {kind: kindString, text: func(e *models.RecentChangeEvent) string { return e.Type }},
+	"user":    {kind: kindString, text: func(e *models.RecentChangeEvent) string { return e.User }},
+	"title":   {kind: kindString, text: func(e *models.RecentChangeEvent) string { return e.Title }},
+	"comment": {kind: kindString, text: func(e *models.RecentChangeEvent) string { return e.Comment }},
+	"wiki":    {kind: kindString, text: func(e *models.RecentChangeEvent) string { return e.Wiki }},
+	"bot":     {kind: kindBool, text: func(e *models.RecentChangeEvent) string { return strconv.FormatBool(e.Bot) }},
+	"minor":   {kind: kindBool, text: func(e *models.RecentChangeEvent) string { return strconv.FormatBool(e.Minor) }},
+	"delta":   {kind: kindNumber, number: func(e *models.RecentChangeEvent) float64 { return float64(e.ByteDelta()) }},
+	"score":   {kind: kindNumber, number: func(e *models.RecentChangeEvent) float64 { return e.Score }},
+	"anon": {kind: kindBool, text: func(e *models.RecentChangeEvent) string {
+		return strconv.FormatBool(net.ParseIP(e.User) != nil)
+	}},
+}
+
+// operators is ordered so two-character operators are tried first.
+var operators = []string{"!=", ">=", "<=", "!~", "=", ">", "<", "~"}
+
+// FieldNames returns the keys accepted in filters, sorted.
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Condition is a single key/operator/value test such as minor=false.
+type Condition struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+func (c Condition) String() string {
+	return c.Key + c.Op + c.Value
+}
+
+func (c Condition) match(e *models.RecentChangeEvent) bool {
+	f := fields[c.Key]
+	if f.kind == kindNumber {
+		got := f.number(e)
+		want, _ := strconv.ParseFloat(c.Value, 64)
+		switch c.Op {
+		case "=":
+			return got == want
+		case "!=":
+			return got != want
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		case "<":
+			return got < want
+		case "<=":
+			return got <= want
+		}
+		return false
+	}
+
+	got := strings.ToLower(f.text(e))
+	want := strings.ToLower(c.Value)
+	switch c.Op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	case "~":
+		return strings.Contains(got, want)
+	case "!~":
+		return !strings.Contains(got, want)
+	}
+	return false
+}
+
+// Filter is a list of conditions that must all hold. The empty filter
+// matches everything.
+type Filter []Condition
+
+// ParseFilter parses a filter such as "type=edit minor=false user~bot".
+// Tokens without an operator continue the previous value, so
+// "user=Jimbo Wales" needs no quoting.
+func ParseFilter(s string) (Filter, error) {
+	var filter Filter
+	for _, token := range strings.Fields(s) {
+		c, ok := splitCondition(token)
+		if !ok {
+			if len(filter) == 0 || fields[filter[len(filter)-1].Key].kind != kindString {
+				return nil, fmt.Errorf("%q is not key=value", token)
+			}
+			filter[len(filter)-1].Value += " " + token
+			continue
+		}
+		filter = append(filter, c)
+	}
+	for i, c := range filter {
+		normalized, err := validate(c)
+		if err != nil {
+			return nil, err
+		}
+		filter[i] = normalized
+	}
+	return filter, nil
+}
+
+func splitCondition(token string) (Condition, bool) {
+	i := strings.IndexAny(token, "!=<>~")
+	if i <= 0 {
+		return Condition{}, false
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(token[i:], op) {
+			return Condition{
+				Key:   strings.ToLower(token[:i]),
+				Op:    op,
+				Value: token[i+len(op):],
+			}, true
+		}
+	}
+	return Condition{}, false
+}
+
+func validate(c Condition) (Condition, error) {
+	f, ok := fields[c.Key]
+	if !ok {
+		return c, fmt.Errorf("unknown key %q, use one of %s", c.Key, strings.Join(FieldNames(), ", "))
+	}
+	if c.Value == "" {
+		return c, fmt.Errorf("%s needs a value", c.Key)
+	}
+	switch f.kind {
+	case kindBool:
+		if c.Op != "=" && c.Op != "!=" {
+			return c, fmt.Errorf("%s only supports = and !=", c.Key)
+		}
+		v, ok := parseBool(c.Value)
+		if !ok {
+			return c, fmt.Errorf("%s must be true or false", c.Key)
+		}
+		c.Value = strconv.FormatBool(v)
+	case kindNumber:
+		if c.Op == "~" || c.Op == "!~" {
+			return c, fmt.Errorf("%s does not support %s", c.Key, c.Op)
+		}
+		if _, err := strconv.ParseFloat(c.Value, 64); err != nil {
+			return c, fmt.Errorf("%s must be a number", c.Key)
+		}
+	default:
+		switch c.Op {
+		case "=", "!=", "~", "!~":
+		default:
+			return c, fmt.Errorf("%s does not support %s", c.Key, c.Op)
+		}
+	}
+	return c, nil
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "on":
+		return true, true
+	case "no", "n", "off":
+		return false, true
+	}
+	v, err := strconv.ParseBool(s)
+	return v, err == nil
+}
+
+func (f Filter) Match(e *models.RecentChangeEvent) bool {
+	for _, c := range f {
+		if !c.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter in the form ParseFilter accepts.
+func (f Filter) String() string {
+	parts := make([]string, len(f))
+	for i, c := range f {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,186 @@
+// Package i18n holds the message catalogs for the bot's replies. Catalogs
+// are embedded JSON files, one per language, mapping a key to a fmt format
+// string or, for messages that depend on a count, to one format per plural
+// category. Keys missing from a catalog fall back to English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Fallback is the language every other catalog falls back to.
+const Fallback = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Message is a single entry of a catalog. Text is set for plain messages,
+// Forms for messages with plurals.
+type Message struct {
+	Text  string
+	Forms map[string]string
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.Forms); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms: %w", err)
+	}
+	return nil
+}
+
+// Catalog maps message keys to messages for one language.
+type Catalog map[string]Message
+
+// Bundle is a set of catalogs.
+type Bundle struct {
+	catalogs map[string]Catalog
+}
+
+var defaultBundle = mustLoad(localeFiles, "locales")
+
+// Default returns the embedded catalogs.
+func Default() *Bundle {
+	return defaultBundle
+}
+
+// Load reads every <lang>.json file in dir. The fallback catalog must be
+// among them.
+func Load(fsys fs.FS, dir string) (*Bundle, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{catalogs: make(map[string]Catalog)}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var c Catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		b.catalogs[strings.TrimSuffix(e.Name(), ".json")] = c
+	}
+	if _, ok := b.catalogs[Fallback]; !ok {
+		return nil, fmt.Errorf("no %s catalog in %s", Fallback, dir)
+	}
+	return b, nil
+}
+
+func mustLoad(fsys fs.FS, dir string) *Bundle {
+	b, err := Load(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("i18n: invalid embedded catalogs: %v", err))
+	}
+	return b
+}
+
+// Languages returns the codes of all catalogs, sorted.
+func (b *Bundle) Languages() []string {
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Catalog returns the catalog for lang.
+func (b *Bundle) Catalog(lang string) (Catalog, bool) {
+	c, ok := b.catalogs[lang]
+	return c, ok
+}
+
+// Localizer returns a localizer for lang. Languages without a catalog get
+// English.
+func (b *Bundle) Localizer(lang string) Localizer {
+	l := Localizer{lang: Fallback, fallback: b.catalogs[Fallback]}
+	if c, ok := b.catalogs[lang]; ok {
+		l.lang = lang
+		l.catalog = c
+	}
+	return l
+}
+
+// Localizer formats messages in one language. The zero value uses the
+// embedded English catalog.
+type Localizer struct {
+	lang     string
+	catalog  Catalog
+	fallback Catalog
+}
+
+// Lang returns the language messages are formatted in.
+func (l Localizer) Lang() string {
+	if l.lang == "" {
+		return Fallback
+	}
+	return l.lang
+}
+
+func (l Localizer) lookup(key string) (Message, string, bool) {
+	if l.fallback == nil {
+		l = defaultBundle.Localizer(Fallback)
+	}
+	if m, ok := l.catalog[key]; ok {
+		return m, l.Lang(), true
+	}
+	m, ok := l.fallback[key]
+	return m, Fallback, ok
+}
+
+// T formats the message for key with args. Unknown keys are returned as
+// they are, so a missing message is visible rather than empty.
+func (l Localizer) T(key string, args ...any) string {
+	m, _, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	format := m.Text
+	if m.Forms != nil {
+		// Plural messages should go through N; pick a form that exists.
+		format = m.Forms[Other]
+		if format == "" {
+			format = m.Forms[Many]
+		}
+	}
+	return sprintf(format, args)
+}
+
+// N formats the plural message for key, choosing the form for n. n is
+// passed as the first argument, followed by args.
+func (l Localizer) N(key string, n int, args ...any) string {
+	m, lang, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	all := append([]any{n}, args...)
+	if m.Forms == nil {
+		return sprintf(m.Text, all)
+	}
+	format, ok := m.Forms[PluralCategory(lang, n)]
+	if !ok {
+		format = m.Forms[Other]
+	}
+	return sprintf(format, all)
+}
+
+func sprintf(format string, args []any) string {
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,130 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verbRe matches fmt verbs, with or without an explicit argument index.
+var verbRe = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0]*[a-zA-Z%]`)
+
+// argCount returns how many arguments format consumes.
+func argCount(format string) int {
+	n, next := 0, 1
+	for _, m := range verbRe.FindAllStringSubmatch(format, -1) {
+		if m[0] == "%%" {
+			continue
+		}
+		i := next
+		if m[1] != "" {
+			i, _ = strconv.Atoi(m[1])
+		}
+		if i > n {
+			n = i
+		}
+		next = i + 1
+	}
+	return n
+}
+
+func formats(m Message) []string {
+	if m.Forms == nil {
+		return []string{m.Text}
+	}
+	var fs []string
+	for _, f := range m.Forms {
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// TestCatalogsComplete fails when a catalog lacks a key that exists in
+// English, lacks a plural form its language needs, or takes different
+// arguments than English.
+func TestCatalogsComplete(t *testing.T) {
+	b := Default()
+	en, ok := b.Catalog(Fallback)
+	require.True(t, ok)
+	keys := make([]string, 0, len(en))
+	for k := range en {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, lang := range b.Languages() {
+		c, _ := b.Catalog(lang)
+		t.Run(lang, func(t *testing.T) {
+			for _, key := range keys {
+				m, ok := c[key]
+				if !assert.True(t, ok, "missing key %q", key) {
+					continue
+				}
+				want := en[key]
+				if want.Forms != nil {
+					for _, cat := range PluralCategories(lang) {
+						assert.NotEmpty(t, m.Forms[cat], "%q has no %q form", key, cat)
+					}
+				} else {
+					assert.Nil(t, m.Forms, "%q is not a plural message in English", key)
+				}
+				for _, f := range formats(m) {
+					assert.Equal(t, argCount(formats(want)[0]), argCount(f), "%q: %q takes different arguments than English", key, f)
+				}
+			}
+			for key := range c {
+				_, ok := en[key]
+				assert.True(t, ok, "%q is not in the English catalog", key)
+			}
+		})
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	b, err := Load(fstest.MapFS{
+		"l/en.json": {Data: []byte(`{"hi": "Hello %s", "only_en": "English", "n": {"one": "%d file", "other": "%d files"}}`)},
+		"l/ru.json": {Data: []byte(`{"hi": "Привет %s", "n": {"one": "%d файл", "few": "%d файла", "many": "%d файлов"}}`)},
+	}, "l")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "ru"}, b.Languages())
+
+	ru := b.Localizer("ru")
+	assert.Equal(t, "ru", ru.Lang())
+	assert.Equal(t, "Привет Ann", ru.T("hi", "Ann"))
+	assert.Equal(t, "English", ru.T("only_en"), "missing keys fall back to English")
+	assert.Equal(t, "unknown.key", ru.T("unknown.key"))
+	assert.Equal(t, "21 файл", ru.N("n", 21))
+	assert.Equal(t, "3 файла", ru.N("n", 3))
+	assert.Equal(t, "11 файлов", ru.N("n", 11))
+
+	de := b.Localizer("de")
+	assert.Equal(t, "en", de.Lang(), "languages without a catalog get English")
+	assert.Equal(t, "1 file", de.N("n", 1))
+	assert.Equal(t, "0 files", de.N("n", 0))
+
+	var zero Localizer
+	assert.Equal(t, "Usage: !x", zero.T("error.usage", "!x"))
+}
+
+func TestLoadRequiresFallback(t *testing.T) {
+	_, err := Load(fstest.MapFS{"l/ru.json": {Data: []byte(`{}`)}}, "l")
+	assert.Error(t, err)
+
+	_, err = Load(fstest.MapFS{"l/en.json": {Data: []byte(`{"a": 1}`)}}, "l")
+	assert.Error(t, err)
+}
+
+func TestPluralCategory(t *testing.T) {
+	for n, want := range map[int]string{1: One, 3: Few, 5: Many, 11: Many, 14: Many, 21: One, 22: Few, 0: Many, -2: Few} {
+		assert.Equal(t, want, PluralCategory("ru", n), "ru %d", n)
+	}
+	assert.Equal(t, Many, PluralCategory("pl", 21))
+	assert.Equal(t, One, PluralCategory("fr", 0))
+	assert.Equal(t, Other, PluralCategory("en", 0))
+	assert.Equal(t, Other, PluralCategory("ja", 1))
+}
@@ -0,0 +1,100 @@
+package i18n
+
+// Plural categories, as named by CLDR.
+const (
+	One   = "one"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// pluralRule picks the category for a non-negative count.
+type pluralRule struct {
+	categories []string
+	pick       func(n int) string
+}
+
+var (
+	ruleOneOther = pluralRule{
+		categories: []string{One, Other},
+		pick: func(n int) string {
+			if n == 1 {
+				return One
+			}
+			return Other
+		},
+	}
+	// ruleZeroOne treats zero as singular, as French and Portuguese do.
+	ruleZeroOne = pluralRule{
+		categories: []string{One, Other},
+		pick: func(n int) string {
+			if n == 0 || n == 1 {
+				return One
+			}
+			return Other
+		},
+	}
+	ruleEastSlavic = pluralRule{
+		categories: []string{One, Few, Many},
+		pick: func(n int) string {
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return One
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return Few
+			}
+			return Many
+		},
+	}
+	rulePolish = pluralRule{
+		categories: []string{One, Few, Many},
+		pick: func(n int) string {
+			switch {
+			case n == 1:
+				return One
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return Few
+			}
+			return Many
+		},
+	}
+	ruleNone = pluralRule{
+		categories: []string{Other},
+		pick:       func(int) string { return Other },
+	}
+)
+
+// pluralRules lists languages whose rule differs from English.
+var pluralRules = map[string]pluralRule{
+	"fr": ruleZeroOne,
+	"pt": ruleZeroOne,
+	"ru": ruleEastSlavic,
+	"uk": ruleEastSlavic,
+	"be": ruleEastSlavic,
+	"pl": rulePolish,
+	"ja": ruleNone,
+	"zh": ruleNone,
+	"ko": ruleNone,
+	"vi": ruleNone,
+	"id": ruleNone,
+}
+
+func ruleFor(lang string) pluralRule {
+	if r, ok := pluralRules[lang]; ok {
+		return r
+	}
+	return ruleOneOther
+}
+
+// PluralCategory returns the plural category of n in lang.
+func PluralCategory(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	return ruleFor(lang).pick(n)
+}
+
+// PluralCategories returns the categories a plural message in lang needs.
+func PluralCategories(lang string) []string {
+	return ruleFor(lang).categories
+}
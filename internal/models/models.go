@@ -1,6 +1,9 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type RecentChangeEvent struct {
 	ID         json.Number `json:"id"`
@@ -13,4 +16,177 @@ type RecentChangeEvent struct {
 	Timestamp  int64       `json:"timestamp"`
 	Wiki       string      `json:"wiki"`
 	ServerName string      `json:"server_name"`
+	Namespace  int         `json:"namespace"`
+	Length     EventLength `json:"length"`
+	Revision   Revision    `json:"revision"`
+	// LogID and LogType are set for log events.
+	LogID   int64  `json:"log_id"`
+	LogType string `json:"log_type"`
+	// Score is the suspicion score the ingestion pipeline gave the event,
+	// from 0 to 1.
+	Score float64 `json:"-"`
+}
+
+// Revision holds the revision IDs before and after an edit. Old is zero
+// for page creations; both are zero for log events.
+type Revision struct {
+	Old int64 `json:"old"`
+	New int64 `json:"new"`
+}
+
+// EventLength holds the page size in bytes before and after a change. Old
+// is zero for page creations.
+type EventLength struct {
+	Old int64 `json:"old"`
+	New int64 `json:"new"`
+}
+
+// ByteDelta returns how many bytes the change added, negative for removals.
+func (e *RecentChangeEvent) ByteDelta() int64 {
+	return e.Length.New - e.Length.Old
+}
+
+type GuildSettings struct {
+	GuildID  string
+	Lang     string
+	Prefix   string
+	Timezone string
+	// LocalStatsDays makes !stats read dates as days in Timezone rather
+	// than UTC days.
+	LocalStatsDays   bool
+	FeedChannels     []string
+	DisabledCommands []string
+	// Aliases maps a custom command name to the command line it expands to,
+	// for example "rc" -> "recent en".
+	Aliases map[string]string
+	// RoleGrants maps a command name to the role IDs that may run it in
+	// addition to members with the command's own permission.
+	RoleGrants map[string][]string
+}
+
+// UserSettings are a user's personal settings. They apply in direct
+// messages, where no guild settings exist.
+type UserSettings struct {
+	UserID   string
+	Timezone string
+	// LocalStatsDays makes !stats in direct messages read dates as days in
+	// Timezone rather than UTC days.
+	LocalStatsDays bool
+}
+
+const (
+	WatchKindPage = "page"
+	WatchKindUser = "user"
+
+	// WatchAllLangs is stored as the language of watches that match on
+	// every wiki.
+	WatchAllLangs = "*"
+)
+
+type Watch struct {
+	ID        int64
+	GuildID   string
+	ChannelID string
+	CreatedBy string
+	Kind      string
+	Lang      string
+	Target    string
+	CreatedAt time.Time
+}
+
+type Feed struct {
+	ID        int64
+	GuildID   string
+	ChannelID string
+	CreatedBy string
+	Lang      string
+	Filters   string
+	// CursorTimestamp and CursorEventID identify the last event posted to
+	// the channel, so a restart resumes right after it.
+	CursorTimestamp int64
+	CursorEventID   string
+	CreatedAt       time.Time
+}
+
+const (
+	AlertKindSpike   = "spike"
+	AlertKindEditWar = "editwar"
+)
+
+// Alert subscribes a channel to alerts about activity on a wiki or a page.
+type Alert struct {
+	ID        int64
+	GuildID   string
+	ChannelID string
+	CreatedBy string
+	Kind      string
+	Lang      string
+	// Target is a page title, or empty for the whole wiki.
+	Target string
+	// Threshold is how many times its usual rate activity must reach for a
+	// spike, and how many reverts make an edit war.
+	Threshold float64
+	CreatedAt time.Time
+}
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Digest subscribes a channel to a summary of the previous day or week on
+// some wikis, posted at a local time of its guild.
+type Digest struct {
+	ID        int64
+	GuildID   string
+	ChannelID string
+	CreatedBy string
+	Period    string
+	Langs     []string
+	// Hour and Minute are the guild's local time of day the digest is
+	// posted at. Weekly digests are posted on Mondays.
+	Hour   int
+	Minute int
+	// NextRun is when the digest is due next.
+	NextRun   time.Time
+	CreatedAt time.Time
+}
+
+// JobRun is the last run of a scheduled job.
+type JobRun struct {
+	Name     string
+	LastRun  time.Time
+	Duration time.Duration
+	// Error is why the run failed, or empty if it succeeded.
+	Error string
+}
+
+// DailyCount is the number of changes on one UTC day, as yyyy-mm-dd.
+type DailyCount struct {
+	Date  string
+	Count int
+}
+
+// HourlyCount is the number of changes in the hour starting at Hour.
+type HourlyCount struct {
+	Hour  time.Time
+	Count int
+}
+
+// ArticleCount is the number of changes to one article over a period.
+type ArticleCount struct {
+	Title string
+	Count int
+}
+
+// EditorCount is the number of changes by one editor over a period.
+type EditorCount struct {
+	User  string
+	Count int
+}
+
+// NewPage is a page created during a period and who created it.
+type NewPage struct {
+	Title string
+	User  string
 }
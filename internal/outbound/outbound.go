@@ -0,0 +1,361 @@
+// Package outbound queues messages to Discord channels. Every channel has
+// its own queue, drained by one goroutine at a time, so messages to a
+// channel arrive in order and a slow or rate-limited channel does not hold
+// up the others. Transient failures are retried with backoff; permanent
+// ones, such as missing permissions or a deleted channel, are reported at
+// once.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultQueueSize bounds the messages waiting for one channel.
+	DefaultQueueSize = 100
+	// DefaultAttempts is how often a message is tried before giving up.
+	DefaultAttempts = 5
+
+	retryBase = 500 * time.Millisecond
+	retryMax  = 30 * time.Second
+)
+
+var (
+	ErrClosed    = errors.New("dispatcher closed")
+	ErrQueueFull = errors.New("channel queue full")
+)
+
+// messages counts outbound messages by outcome: sent, retried, failed and
+// dropped. expvar serves it at /debug/vars when the metrics listener is
+// enabled.
+var messages = expvar.NewMap("outbound_messages")
+
+// Session is the part of the Discord session the dispatcher sends with.
+type Session interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// FailureFunc is told about every message that could not be delivered.
+type FailureFunc func(channelID string, err error)
+
+type result struct {
+	msg *discordgo.Message
+	err error
+}
+
+type job struct {
+	msg     *discordgo.MessageSend
+	options []discordgo.RequestOption
+	// done receives the outcome; it is nil for messages nobody waits for.
+	done chan result
+}
+
+func (j job) finish(msg *discordgo.Message, err error) {
+	if j.done != nil {
+		j.done <- result{msg: msg, err: err}
+	}
+}
+
+type queue struct {
+	jobs []job
+}
+
+// Dispatcher sends messages through per-channel queues. It is safe for
+// concurrent use.
+type Dispatcher struct {
+	session   Session
+	logger    *zap.SugaredLogger
+	queueSize int
+	attempts  int
+	backoff   func(attempt int) time.Duration
+	onFailure FailureFunc
+
+	// ctx is cancelled when Close stops waiting for the queues to drain,
+	// which aborts requests and retries in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// queues holds the channels that have a worker running.
+	queues  map[string]*queue
+	closed  bool
+	workers sync.WaitGroup
+}
+
+func New(session Session, logger *zap.SugaredLogger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		session:   session,
+		logger:    logger,
+		queueSize: DefaultQueueSize,
+		attempts:  DefaultAttempts,
+		backoff:   backoff,
+		ctx:       ctx,
+		cancel:    cancel,
+		queues:    make(map[string]*queue),
+	}
+}
+
+// OnFailure sets fn to be called for every message that could not be
+// delivered. It must be called before the first message is sent.
+func (d *Dispatcher) OnFailure(fn FailureFunc) {
+	d.onFailure = fn
+}
+
+// Enqueue queues m for channelID and returns without waiting for it to be
+// sent. Failures are logged and passed to the OnFailure function.
+func (d *Dispatcher) Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error {
+	return d.push(channelID, job{msg: m, options: options})
+}
+
+// Send queues m for channelID and waits until it was sent or given up on.
+// If ctx ends first, Send returns its error but the message stays queued.
+func (d *Dispatcher) Send(ctx context.Context, channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	done := make(chan result, 1)
+	if err := d.push(channelID, job{msg: m, options: options, done: done}); err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-done:
+		return r.msg, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ChannelMessageSend sends content like the session method of the same
+// name, so the dispatcher can stand in for the session.
+func (d *Dispatcher) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return d.Send(context.Background(), channelID, &discordgo.MessageSend{Content: content}, options...)
+}
+
+// ChannelMessageSendComplex sends data like the session method of the same
+// name.
+func (d *Dispatcher) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return d.Send(context.Background(), channelID, data, options...)
+}
+
+func (d *Dispatcher) push(channelID string, j job) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	q, ok := d.queues[channelID]
+	if !ok {
+		q = &queue{}
+		d.queues[channelID] = q
+		d.workers.Add(1)
+		go d.work(channelID, q)
+	}
+	if len(q.jobs) >= d.queueSize {
+		messages.Add("dropped", 1)
+		d.logger.Warnw("Outbound queue full, dropping message", "channel", channelID)
+		return ErrQueueFull
+	}
+	q.jobs = append(q.jobs, j)
+	return nil
+}
+
+// work sends the channel's messages one after another and exits once the
+// queue is empty.
+func (d *Dispatcher) work(channelID string, q *queue) {
+	defer d.workers.Done()
+	for {
+		d.mu.Lock()
+		if len(q.jobs) == 0 {
+			delete(d.queues, channelID)
+			d.mu.Unlock()
+			return
+		}
+		j := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		d.mu.Unlock()
+
+		msg, err := d.deliver(channelID, j)
+		j.finish(msg, err)
+		if err != nil && ChannelGone(err) {
+			// Everything else queued for the channel would fail the same
+			// way. The failure was reported once for the channel already.
+			d.mu.Lock()
+			rest := q.jobs
+			q.jobs = nil
+			d.mu.Unlock()
+			if len(rest) > 0 {
+				messages.Add("failed", int64(len(rest)))
+				d.logger.Warnw("Dropping messages queued for a channel that is gone",
+					"channel", channelID, "messages", len(rest))
+			}
+			for _, j := range rest {
+				j.finish(nil, err)
+			}
+		}
+	}
+}
+
+// deliver sends one message, retrying transient failures.
+func (d *Dispatcher) deliver(channelID string, j job) (*discordgo.Message, error) {
+	// Rate limits are waited out here rather than inside the session, so
+	// the worker can be stopped while it waits.
+	options := append([]discordgo.RequestOption{
+		discordgo.WithContext(d.ctx),
+		discordgo.WithRetryOnRatelimit(false),
+	}, j.options...)
+
+	for attempt := 1; ; attempt++ {
+		msg, err := d.session.ChannelMessageSendComplex(channelID, j.msg, options...)
+		if err == nil {
+			messages.Add("sent", 1)
+			return msg, nil
+		}
+		if d.ctx.Err() != nil {
+			err = fmt.Errorf("%w: %v", ErrClosed, err)
+			d.fail(channelID, err)
+			return nil, err
+		}
+		if Permanent(err) || attempt >= d.attempts || !rewind(j.msg) {
+			d.fail(channelID, err)
+			return nil, err
+		}
+
+		wait, ok := RetryAfter(err)
+		if !ok {
+			wait = d.backoff(attempt)
+		}
+		messages.Add("retried", 1)
+		d.logger.Warnw("Retrying message", "channel", channelID, "attempt", attempt, "wait", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w: %v", ErrClosed, err)
+			d.fail(channelID, err)
+			return nil, err
+		}
+	}
+}
+
+func (d *Dispatcher) fail(channelID string, err error) {
+	messages.Add("failed", 1)
+	d.logger.Errorw("Failed to send message", "channel", channelID, "permanent", Permanent(err), "error", err)
+	if d.onFailure != nil {
+		d.onFailure(channelID, err)
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be sent.
+// When ctx ends first, the rest are dropped and Close reports how many.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	var dropped []job
+	for _, q := range d.queues {
+		dropped = append(dropped, q.jobs...)
+		q.jobs = nil
+	}
+	d.mu.Unlock()
+	d.cancel()
+	for _, j := range dropped {
+		j.finish(nil, ErrClosed)
+	}
+	messages.Add("dropped", int64(len(dropped)))
+	<-drained
+	return fmt.Errorf("%w: dropped %d queued messages: %v", ErrClosed, len(dropped), ctx.Err())
+}
+
+// backoff doubles the wait with every attempt, up to retryMax.
+func backoff(attempt int) time.Duration {
+	wait := retryBase << (attempt - 1)
+	if wait <= 0 || wait > retryMax {
+		return retryMax
+	}
+	return wait
+}
+
+// rewind resets attached files so the message can be sent again, and
+// reports whether that was possible.
+func rewind(m *discordgo.MessageSend) bool {
+	for _, f := range m.Files {
+		s, ok := f.Reader.(io.Seeker)
+		if !ok {
+			return false
+		}
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Permanent reports whether sending again cannot help: Discord rejected the
+// request itself, for example because the bot lacks permissions or the
+// channel is gone.
+func Permanent(err error) bool {
+	var rest *discordgo.RESTError
+	if !errors.As(err, &rest) || rest.Response == nil {
+		return false
+	}
+	code := rest.Response.StatusCode
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests
+}
+
+// ChannelGone reports whether err means the bot can no longer post to the
+// channel at all, because it was deleted, the bot lost access to it, or the
+// user of a direct message channel no longer accepts messages from the bot.
+func ChannelGone(err error) bool {
+	var rest *discordgo.RESTError
+	if !errors.As(err, &rest) || rest.Message == nil {
+		return false
+	}
+	switch rest.Message.Code {
+	case discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeCannotSendMessagesToThisUser:
+		return true
+	}
+	return false
+}
+
+// ChannelDeleted reports whether err means the channel no longer exists.
+// Unlike the other ways a channel can be gone, which a change of
+// permissions may undo, this one is final.
+func ChannelDeleted(err error) bool {
+	var rest *discordgo.RESTError
+	return errors.As(err, &rest) && rest.Message != nil && rest.Message.Code == discordgo.ErrCodeUnknownChannel
+}
+
+// RetryAfter returns how long Discord asked to wait before retrying a
+// rate-limited request.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rl *discordgo.RateLimitError
+	if errors.As(err, &rl) && rl.RateLimit != nil && rl.TooManyRequests != nil {
+		return rl.RetryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeSession records what was sent and fails according to errs, which
+// maps a channel to the errors its next sends return.
+type fakeSession struct {
+	mu   sync.Mutex
+	sent map[string][]string
+	errs map[string][]error
+	// block, when set, holds sends to the channel until it is closed.
+	block map[string]chan struct{}
+	// bodies are the file contents seen by each send.
+	bodies []string
+}
+
+func (s *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	wait := s.block[channelID]
+	s.mu.Unlock()
+	if wait != nil {
+		<-wait
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range data.Files {
+		body, _ := io.ReadAll(f.Reader)
+		s.bodies = append(s.bodies, string(body))
+	}
+	if errs := s.errs[channelID]; len(errs) > 0 {
+		s.errs[channelID] = errs[1:]
+		return nil, errs[0]
+	}
+	if s.sent == nil {
+		s.sent = make(map[string][]string)
+	}
+	s.sent[channelID] = append(s.sent[channelID], data.Content)
+	return &discordgo.Message{ChannelID: channelID, Content: data.Content}, nil
+}
+
+func (s *fakeSession) messages(channelID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent[channelID]...)
+}
+
+func restError(status, code int) error {
+	return &discordgo.RESTError{
+		Response: &http.Response{StatusCode: status},
+		Message:  &discordgo.APIErrorMessage{Code: code},
+	}
+}
+
+func newTestDispatcher(s Session) *Dispatcher {
+	d := New(s, zap.NewNop().Sugar())
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	return d
+}
+
+func TestSendKeepsChannelOrder(t *testing.T) {
+	s := &fakeSession{}
+	d := newTestDispatcher(s)
+
+	for _, content := range []string{"one", "two", "three"} {
+		require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: content}))
+	}
+	msg, err := d.ChannelMessageSend("c1", "four")
+	require.NoError(t, err)
+	assert.Equal(t, "four", msg.Content)
+	assert.Equal(t, []string{"one", "two", "three", "four"}, s.messages("c1"))
+}
+
+func TestSlowChannelDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	s := &fakeSession{block: map[string]chan struct{}{"slow": release}}
+	d := newTestDispatcher(s)
+
+	require.NoError(t, d.Enqueue("slow", &discordgo.MessageSend{Content: "stuck"}))
+	_, err := d.ChannelMessageSend("fast", "through")
+	require.NoError(t, err)
+	assert.Empty(t, s.messages("slow"))
+
+	close(release)
+	require.NoError(t, d.Close(context.Background()))
+	assert.Equal(t, []string{"stuck"}, s.messages("slow"))
+}
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	rateLimited := &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+		TooManyRequests: &discordgo.TooManyRequests{RetryAfter: time.Millisecond},
+	}}
+	s := &fakeSession{errs: map[string][]error{
+		"c1": {restError(http.StatusBadGateway, 0), rateLimited, errors.New("connection reset")},
+	}}
+	d := newTestDispatcher(s)
+
+	_, err := d.ChannelMessageSend("c1", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello"}, s.messages("c1"))
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	fail := restError(http.StatusInternalServerError, 0)
+	s := &fakeSession{errs: map[string][]error{"c1": {fail, fail, fail, fail, fail, fail}}}
+	d := newTestDispatcher(s)
+	var failures []string
+	d.OnFailure(func(channelID string, err error) { failures = append(failures, channelID) })
+
+	_, err := d.ChannelMessageSend("c1", "hello")
+	assert.ErrorIs(t, err, fail)
+	assert.Equal(t, []string{"c1"}, failures)
+	// The sixth error was never used.
+	assert.Len(t, s.errs["c1"], 1)
+}
+
+func TestPermanentFailuresAreNotRetried(t *testing.T) {
+	forbidden := restError(http.StatusForbidden, discordgo.ErrCodeMissingPermissions)
+	s := &fakeSession{errs: map[string][]error{"c1": {forbidden}}}
+	d := newTestDispatcher(s)
+	var reported error
+	d.OnFailure(func(channelID string, err error) { reported = err })
+
+	_, err := d.ChannelMessageSend("c1", "hello")
+	assert.ErrorIs(t, err, forbidden)
+	assert.True(t, Permanent(err))
+	assert.False(t, ChannelGone(err))
+	assert.Equal(t, forbidden, reported)
+	assert.True(t, ChannelGone(restError(http.StatusForbidden, discordgo.ErrCodeCannotSendMessagesToThisUser)), "the user blocked direct messages")
+	assert.True(t, ChannelGone(restError(http.StatusForbidden, discordgo.ErrCodeMissingAccess)))
+	assert.False(t, ChannelDeleted(restError(http.StatusForbidden, discordgo.ErrCodeMissingAccess)), "access can be given back")
+	assert.True(t, ChannelDeleted(restError(http.StatusNotFound, discordgo.ErrCodeUnknownChannel)))
+
+	// Missing permissions may only concern one message; the next is tried.
+	_, err = d.ChannelMessageSend("c1", "again")
+	require.NoError(t, err)
+}
+
+func TestDeletedChannelDropsItsQueue(t *testing.T) {
+	release := make(chan struct{})
+	gone := restError(http.StatusNotFound, discordgo.ErrCodeUnknownChannel)
+	s := &fakeSession{
+		errs:  map[string][]error{"c1": {gone}},
+		block: map[string]chan struct{}{"c1": release},
+	}
+	d := newTestDispatcher(s)
+	failures := 0
+	d.OnFailure(func(string, error) { failures++ })
+
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "first"}))
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "second"}))
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "third"}))
+	close(release)
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Empty(t, s.messages("c1"))
+	assert.Equal(t, 1, failures, "a gone channel is reported once")
+}
+
+func TestFilesAreRewoundForRetries(t *testing.T) {
+	s := &fakeSession{errs: map[string][]error{"c1": {restError(http.StatusBadGateway, 0)}}}
+	d := newTestDispatcher(s)
+
+	_, err := d.ChannelMessageSendComplex("c1", &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "chart.png", Reader: strings.NewReader("png")}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"png", "png"}, s.bodies)
+
+	// A reader that cannot be rewound is not retried.
+	s.errs["c1"] = []error{restError(http.StatusBadGateway, 0)}
+	_, err = d.ChannelMessageSendComplex("c1", &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "chart.png", Reader: io.MultiReader(strings.NewReader("png"))}},
+	})
+	assert.Error(t, err)
+}
+
+func TestCloseDrainsAndRejectsNewMessages(t *testing.T) {
+	s := &fakeSession{}
+	d := newTestDispatcher(s)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "m"}))
+	}
+	require.NoError(t, d.Close(context.Background()))
+	assert.Len(t, s.messages("c1"), 10)
+
+	assert.ErrorIs(t, d.Enqueue("c1", &discordgo.MessageSend{}), ErrClosed)
+	_, err := d.ChannelMessageSend("c1", "late")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestCloseGivesUpAfterDeadline(t *testing.T) {
+	fail := restError(http.StatusServiceUnavailable, 0)
+	s := &fakeSession{errs: map[string][]error{"c1": {fail}}}
+	d := newTestDispatcher(s)
+	d.backoff = func(int) time.Duration { return time.Hour }
+
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "retrying"}))
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{Content: "waiting"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := d.Close(ctx)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorContains(t, err, "dropped 1 queued messages")
+	assert.Empty(t, s.messages("c1"))
+}
+
+func TestQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	s := &fakeSession{block: map[string]chan struct{}{"c1": release}}
+	d := newTestDispatcher(s)
+	d.queueSize = 2
+
+	// The first message is taken off the queue and blocks in the session.
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{}))
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.queues["c1"].jobs) == 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{}))
+	require.NoError(t, d.Enqueue("c1", &discordgo.MessageSend{}))
+	assert.ErrorIs(t, d.Enqueue("c1", &discordgo.MessageSend{}), ErrQueueFull)
+	close(release)
+	require.NoError(t, d.Close(context.Background()))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 500*time.Millisecond, backoff(1))
+	assert.Equal(t, 4*time.Second, backoff(4))
+	assert.Equal(t, retryMax, backoff(10))
+	assert.Equal(t, retryMax, backoff(100))
+}
@@ -0,0 +1,136 @@
+// Package ratelimit implements token buckets keyed by arbitrary strings,
+// used to throttle commands per user, channel and guild.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// forgotten.
+const sweepInterval = time.Minute
+
+// Rate allows Burst uses at once and refills one use every Every. The zero
+// Rate is unlimited.
+type Rate struct {
+	Burst int
+	Every time.Duration
+}
+
+func (r Rate) unlimited() bool {
+	return r.Burst <= 0 || r.Every <= 0
+}
+
+// Bucket names one bucket and the rate it refills at.
+type Bucket struct {
+	Key  string
+	Rate Rate
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   Rate
+}
+
+// refill brings the token count up to now.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += float64(elapsed) / float64(b.rate.Every)
+	if max := float64(b.rate.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+// wait is how long until the bucket has a whole token.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.rate.Every))
+}
+
+// Limiter holds the buckets. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is replaceable for tests.
+	now func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the limiter's clock, for tests.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+// Allow takes one token from every bucket, or from none of them if any is
+// empty. When it refuses, it returns the index of the first empty bucket
+// and how long until that bucket has a token again.
+func (l *Limiter) Allow(buckets ...Bucket) (ok bool, denied int, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	found := make([]*bucket, len(buckets))
+	for i, spec := range buckets {
+		if spec.Rate.unlimited() {
+			continue
+		}
+		b, exists := l.buckets[spec.Key]
+		if !exists || b.rate != spec.Rate {
+			b = &bucket{tokens: float64(spec.Rate.Burst), last: now, rate: spec.Rate}
+			l.buckets[spec.Key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			return false, i, b.wait()
+		}
+		found[i] = b
+	}
+	for _, b := range found {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return true, -1, 0
+}
+
+// sweep drops buckets that are full again, since a new bucket starts full
+// anyway. It runs at most once per sweepInterval.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets currently tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
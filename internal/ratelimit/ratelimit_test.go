@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2025, 2, 4, 12, 0, 0, 0, time.UTC)
+	l := New()
+	l.SetClock(func() time.Time { return now })
+	return l, &now
+}
+
+func TestBurstAndRefill(t *testing.T) {
+	l, now := newTestLimiter()
+	rate := Rate{Burst: 3, Every: 10 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		ok, _, _ := l.Allow(Bucket{Key: "u", Rate: rate})
+		assert.True(t, ok, "use %d", i)
+	}
+	ok, denied, wait := l.Allow(Bucket{Key: "u", Rate: rate})
+	assert.False(t, ok)
+	assert.Equal(t, 0, denied)
+	assert.Equal(t, 10*time.Second, wait)
+
+	*now = now.Add(4 * time.Second)
+	_, _, wait = l.Allow(Bucket{Key: "u", Rate: rate})
+	assert.Equal(t, 6*time.Second, wait)
+
+	*now = now.Add(6 * time.Second)
+	ok, _, _ = l.Allow(Bucket{Key: "u", Rate: rate})
+	assert.True(t, ok)
+	ok, _, _ = l.Allow(Bucket{Key: "u", Rate: rate})
+	assert.False(t, ok, "only one token refilled")
+
+	// Other keys have their own buckets.
+	ok, _, _ = l.Allow(Bucket{Key: "v", Rate: rate})
+	assert.True(t, ok)
+}
+
+func TestAllOrNothing(t *testing.T) {
+	l, _ := newTestLimiter()
+	user := Rate{Burst: 5, Every: time.Second}
+	channel := Rate{Burst: 1, Every: time.Minute}
+
+	ok, _, _ := l.Allow(Bucket{"user", user}, Bucket{"channel", channel})
+	assert.True(t, ok)
+	ok, denied, _ := l.Allow(Bucket{"user", user}, Bucket{"channel", channel})
+	assert.False(t, ok)
+	assert.Equal(t, 1, denied)
+
+	// The refused call did not take a token from the user bucket.
+	for i := 0; i < 4; i++ {
+		ok, _, _ = l.Allow(Bucket{"user", user})
+		assert.True(t, ok)
+	}
+	ok, _, _ = l.Allow(Bucket{"user", user})
+	assert.False(t, ok)
+}
+
+func TestZeroRateIsUnlimited(t *testing.T) {
+	l, _ := newTestLimiter()
+	for i := 0; i < 100; i++ {
+		ok, _, _ := l.Allow(Bucket{Key: "u"})
+		assert.True(t, ok)
+	}
+	assert.Zero(t, l.Len())
+}
+
+func TestSweepForgetsFullBuckets(t *testing.T) {
+	l, now := newTestLimiter()
+	rate := Rate{Burst: 2, Every: time.Second}
+	l.Allow(Bucket{"a", rate})
+	l.Allow(Bucket{"b", Rate{Burst: 2, Every: time.Hour}})
+	assert.Equal(t, 2, l.Len())
+
+	*now = now.Add(2 * sweepInterval)
+	l.Allow(Bucket{"c", rate})
+	assert.Equal(t, 2, l.Len(), "a refilled and was dropped; b is still draining")
+}
+
+func TestConcurrentUse(t *testing.T) {
+	l := New()
+	rate := Rate{Burst: 50, Every: time.Hour}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _, _ := l.Allow(Bucket{"k", rate}); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, allowed)
+}
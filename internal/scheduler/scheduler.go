@@ -0,0 +1,234 @@
+// Package scheduler runs recurring background jobs, such as posting digests
+// and pruning old stats, on intervals or cron schedules.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTimeout bounds a run of a job that sets no timeout.
+	DefaultTimeout = 5 * time.Minute
+
+	// tickInterval is how often due jobs are looked for.
+	tickInterval = time.Second
+)
+
+var (
+	ErrDuplicateJob = errors.New("job already added")
+	ErrInvalidJob   = errors.New("job needs a name, a schedule and a run function")
+)
+
+// Job is work that runs on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Jitter delays every run by a random duration up to Jitter, so that
+	// instances started together do not all run the job at once.
+	Jitter time.Duration
+	// Timeout bounds a run by cancelling its context; zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// Store keeps the last run of every job, so schedules carry on from it
+// after a restart.
+type Store interface {
+	SaveRun(ctx context.Context, run *models.JobRun) error
+	ListRuns(ctx context.Context) ([]*models.JobRun, error)
+}
+
+// Status describes a job for the jobs command.
+type Status struct {
+	Name     string
+	Schedule string
+	Next     time.Time
+	Running  bool
+	// Last is the last run, with a zero LastRun if the job has not run.
+	Last models.JobRun
+	// Skipped counts the runs skipped since the start because the one
+	// before was still running.
+	Skipped int
+}
+
+type entry struct {
+	job     Job
+	next    time.Time
+	running bool
+	last    models.JobRun
+	skipped int
+}
+
+// Scheduler runs jobs when they are due. A job never runs twice at once:
+// a run that comes due while the last one is still going is skipped.
+type Scheduler struct {
+	store Store
+
+	mu   sync.Mutex
+	jobs []*entry
+	wg   sync.WaitGroup
+
+	now func() time.Time
+	// jitter returns a random duration from 0 up to max.
+	jitter func(max time.Duration) time.Duration
+}
+
+func New(store Store) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		now:    time.Now,
+		jitter: func(max time.Duration) time.Duration { return time.Duration(rand.Int63n(int64(max))) },
+	}
+}
+
+// Add registers a job. Jobs must be added before Run.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return ErrInvalidJob
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.jobs {
+		if e.job.Name == job.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+		}
+	}
+	e := &entry{job: job, last: models.JobRun{Name: job.Name}}
+	e.next = s.schedule(job, s.now())
+	s.jobs = append(s.jobs, e)
+	return nil
+}
+
+// Run runs jobs as they come due until ctx is cancelled, and then waits for
+// the running ones, whose contexts are cancelled too, to return.
+func (s *Scheduler) Run(ctx context.Context, logger *zap.SugaredLogger) {
+	s.load(ctx, logger)
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		s.runDue(ctx, logger)
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// load schedules every job from its last stored run. A job whose run came
+// due while the bot was down runs once right away.
+func (s *Scheduler) load(ctx context.Context, logger *zap.SugaredLogger) {
+	runs, err := s.store.ListRuns(ctx)
+	if err != nil {
+		logger.Warnw("Failed to load job runs, scheduling jobs from now", "error", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range runs {
+		for _, e := range s.jobs {
+			if e.job.Name == run.Name {
+				e.last = *run
+				e.next = s.schedule(e.job, run.LastRun)
+			}
+		}
+	}
+}
+
+// schedule returns the job's next run after t, with its jitter.
+func (s *Scheduler) schedule(job Job, t time.Time) time.Time {
+	next := job.Schedule.Next(t)
+	if next.IsZero() || job.Jitter <= 0 {
+		return next
+	}
+	return next.Add(s.jitter(job.Jitter))
+}
+
+func (s *Scheduler) runDue(ctx context.Context, logger *zap.SugaredLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, e := range s.jobs {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		e.next = s.schedule(e.job, now)
+		if e.running {
+			e.skipped++
+			logger.Warnw("Skipped job run, the last one is still running", "job", e.job.Name)
+			continue
+		}
+		e.running = true
+		s.wg.Add(1)
+		go s.run(ctx, e, logger)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry, logger *zap.SugaredLogger) {
+	defer s.wg.Done()
+
+	start := s.now()
+	err := call(ctx, e.job)
+	run := models.JobRun{Name: e.job.Name, LastRun: start, Duration: s.now().Sub(start)}
+	if err != nil {
+		run.Error = err.Error()
+		logger.Errorw("Job failed", "job", run.Name, "duration", run.Duration, "error", err)
+	} else {
+		logger.Infow("Job finished", "job", run.Name, "duration", run.Duration)
+	}
+
+	s.mu.Lock()
+	e.running = false
+	e.last = run
+	s.mu.Unlock()
+
+	// The run is recorded even when ctx was cancelled by a shutdown.
+	if err := s.store.SaveRun(context.Background(), &run); err != nil {
+		logger.Errorw("Failed to save job run", "job", run.Name, "error", err)
+	}
+}
+
+// call runs the job within its timeout, turning a panic into an error so a
+// broken job does not take the bot down.
+func call(ctx context.Context, job Job) (err error) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// Status returns the state of every job, in the order they were added.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, len(s.jobs))
+	for i, e := range s.jobs {
+		out[i] = Status{
+			Name:     e.job.Name,
+			Schedule: e.job.Schedule.String(),
+			Next:     e.next,
+			Running:  e.running,
+			Last:     e.last,
+			Skipped:  e.skipped,
+		}
+	}
+	return out
+}
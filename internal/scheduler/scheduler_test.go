@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+	"go.uber.org/zap"
+)
+
+func TestParse(t *testing.T) {
+	// Wednesday.
+	at := time.Date(2025, 2, 5, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 1m30s", at.Add(90 * time.Second)},
+		{"* * * * *", time.Date(2025, 2, 5, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 2, 5, 10, 30, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2025, 2, 6, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 2, 5, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2025, 2, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 mar *", time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+		// With both day fields set, either matches: the 10th or a Friday.
+		{"0 0 10 * fri", time.Date(2025, 2, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 2, 6, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, s.Next(at), tt.spec)
+		assert.Equal(t, tt.spec, s.String())
+	}
+
+	for _, spec := range []string{"", "@every", "@every -1m", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "0 0 30 2 *", "@often"} {
+		_, err := Parse(spec)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, spec)
+	}
+}
+
+// clock is a time tests move by hand.
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestScheduler(jobs *store.MockJobStore, start time.Time) (*Scheduler, *clock) {
+	c := &clock{t: start}
+	s := New(jobs)
+	s.now = c.Now
+	return s, c
+}
+
+func TestRunDueRunsJobsOnSchedule(t *testing.T) {
+	jobs := &store.MockJobStore{}
+	start := time.Date(2025, 2, 5, 10, 0, 0, 0, time.UTC)
+	s, c := newTestScheduler(jobs, start)
+	logger := zap.NewNop().Sugar()
+
+	var mu sync.Mutex
+	runs := 0
+	require.NoError(t, s.Add(Job{
+		Name:     "count",
+		Schedule: Every(time.Minute),
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs++
+			return nil
+		},
+	}))
+	assert.ErrorIs(t, s.Add(Job{Name: "count", Schedule: Every(time.Minute), Run: func(context.Context) error { return nil }}), ErrDuplicateJob)
+	assert.ErrorIs(t, s.Add(Job{Name: "empty"}), ErrInvalidJob)
+
+	s.runDue(context.Background(), logger)
+	s.wg.Wait()
+	assert.Equal(t, 0, runs, "not due yet")
+
+	c.Add(time.Minute)
+	s.runDue(context.Background(), logger)
+	s.wg.Wait()
+	c.Add(30 * time.Second)
+	s.runDue(context.Background(), logger)
+	s.wg.Wait()
+	assert.Equal(t, 1, runs)
+
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, "@every 1m0s", status[0].Schedule)
+	assert.Equal(t, start.Add(time.Minute), status[0].Last.LastRun)
+	assert.Equal(t, start.Add(2*time.Minute), status[0].Next)
+	assert.Empty(t, status[0].Last.Error)
+	assert.Equal(t, start.Add(time.Minute), jobs.Runs["count"].LastRun, "runs are persisted")
+}
+
+func TestOverlappingRunsAreSkipped(t *testing.T) {
+	s, c := newTestScheduler(&store.MockJobStore{}, time.Date(2025, 2, 5, 10, 0, 0, 0, time.UTC))
+	logger := zap.NewNop().Sugar()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	require.NoError(t, s.Add(Job{
+		Name:     "slow",
+		Schedule: Every(time.Minute),
+		Run: func(ctx context.Context) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}))
+
+	c.Add(time.Minute)
+	s.runDue(context.Background(), logger)
+	<-started
+	c.Add(time.Minute)
+	s.runDue(context.Background(), logger)
+
+	status := s.Status()[0]
+	assert.True(t, status.Running)
+	assert.Equal(t, 1, status.Skipped)
+
+	close(release)
+	s.wg.Wait()
+	assert.Len(t, started, 0, "the job ran once")
+	assert.False(t, s.Status()[0].Running)
+}
+
+func TestRunsAreBoundedAndRecovered(t *testing.T) {
+	jobs := &store.MockJobStore{}
+	s, c := newTestScheduler(jobs, time.Date(2025, 2, 5, 10, 0, 0, 0, time.UTC))
+	logger := zap.NewNop().Sugar()
+
+	require.NoError(t, s.Add(Job{
+		Name:     "stuck",
+		Schedule: Every(time.Minute),
+		Timeout:  10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+	require.NoError(t, s.Add(Job{
+		Name:     "broken",
+		Schedule: Every(time.Minute),
+		Run:      func(ctx context.Context) error { panic("boom") },
+	}))
+
+	c.Add(time.Minute)
+	s.runDue(context.Background(), logger)
+	s.wg.Wait()
+
+	assert.Equal(t, "context deadline exceeded", jobs.Runs["stuck"].Error)
+	assert.Equal(t, "panic: boom", jobs.Runs["broken"].Error)
+}
+
+func TestLoadCarriesOnFromTheLastRun(t *testing.T) {
+	daily, err := Parse("30 3 * * *")
+	require.NoError(t, err)
+	now := time.Date(2025, 2, 5, 3, 0, 0, 0, time.UTC)
+	noop := func(context.Context) error { return nil }
+
+	jobs := &store.MockJobStore{Runs: map[string]*models.JobRun{
+		"recent": {Name: "recent", LastRun: time.Date(2025, 2, 4, 3, 30, 0, 0, time.UTC), Duration: time.Second},
+		"missed": {Name: "missed", LastRun: time.Date(2025, 2, 3, 3, 30, 0, 0, time.UTC)},
+	}}
+	s, _ := newTestScheduler(jobs, now)
+	for _, name := range []string{"recent", "missed", "new"} {
+		require.NoError(t, s.Add(Job{Name: name, Schedule: daily, Run: noop}))
+	}
+	s.load(context.Background(), zap.NewNop().Sugar())
+
+	status := s.Status()
+	assert.Equal(t, time.Date(2025, 2, 5, 3, 30, 0, 0, time.UTC), status[0].Next)
+	assert.Equal(t, time.Second, status[0].Last.Duration)
+	assert.Equal(t, time.Date(2025, 2, 4, 3, 30, 0, 0, time.UTC), status[1].Next, "a missed run is due at once")
+	assert.Equal(t, time.Date(2025, 2, 5, 3, 30, 0, 0, time.UTC), status[2].Next)
+	assert.True(t, status[2].Last.LastRun.IsZero())
+}
+
+func TestJitterDelaysRuns(t *testing.T) {
+	start := time.Date(2025, 2, 5, 10, 0, 0, 0, time.UTC)
+	s, _ := newTestScheduler(&store.MockJobStore{}, start)
+	s.jitter = func(max time.Duration) time.Duration { return max / 2 }
+
+	require.NoError(t, s.Add(Job{
+		Name:     "jittered",
+		Schedule: Every(time.Hour),
+		Jitter:   10 * time.Minute,
+		Run:      func(context.Context) error { return nil },
+	}))
+	assert.Equal(t, start.Add(time.Hour+5*time.Minute), s.Status()[0].Next)
+}
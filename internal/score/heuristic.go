@@ -0,0 +1,198 @@
+package score
+
+import (
+	"math"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+const (
+	// newAccountAge is how long an account counts as new after the stream
+	// reported its creation.
+	newAccountAge = 24 * time.Hour
+	// rapidWindow and rapidEdits: an editor making rapidEdits edits within
+	// rapidWindow gets the full rapid signal.
+	rapidWindow = 2 * time.Minute
+	rapidEdits  = 5
+	// largeRemoval, in bytes, is how much removed text gets the full
+	// removal signal.
+	largeRemoval = 2000
+	// A page of at least minBlankedSize bytes cut to blankedLeft of its size
+	// or less counts as blanked.
+	minBlankedSize = 500
+	blankedLeft    = 0.1
+	// maxTracked bounds the accounts and editors remembered for the new
+	// account and rapid signals.
+	maxTracked = 10000
+)
+
+// sectionComment matches the "/* Section */" prefix MediaWiki adds to
+// summaries of section edits.
+var sectionComment = regexp.MustCompile(`^/\*.*?\*/`)
+
+// badWords are English words that good faith summaries rarely contain.
+var badWords = map[string]bool{
+	"lol": true, "lmao": true, "haha": true, "hahaha": true, "poop": true, "stupid": true,
+	"idiot": true, "dumb": true, "sucks": true, "fuck": true, "shit": true, "penis": true,
+}
+
+type editor struct {
+	lang string
+	user string
+}
+
+// Heuristic scores edits by combining a handful of signals seen in the
+// stream, each scaled by its weight. A signal with weight w raises the score
+// by w of the way still left to 1, so signals add up without ever passing 1.
+type Heuristic struct {
+	weights Weights
+	// accounts holds when accounts created recently were created.
+	accounts map[editor]int64
+	// edits holds each editor's edit times within rapidWindow.
+	edits map[editor][]int64
+}
+
+func NewHeuristic(weights Weights) *Heuristic {
+	return &Heuristic{
+		weights:  weights,
+		accounts: make(map[editor]int64),
+		edits:    make(map[editor][]int64),
+	}
+}
+
+// Score rates edits and page creations. Other events score 0, though
+// account creations are remembered for the new account signal.
+func (h *Heuristic) Score(lang string, event *models.RecentChangeEvent) float64 {
+	who := editor{lang: lang, user: event.User}
+	if event.Type == "log" && event.LogType == "newusers" {
+		h.trackAccount(who, event.Timestamp)
+		return 0
+	}
+	if event.Type != "edit" && event.Type != "new" {
+		return 0
+	}
+
+	w := h.weights
+	left := 1.0
+	signal := func(weight, strength float64) {
+		left *= 1 - weight*strength
+	}
+	if anonymous(event.User) {
+		signal(w.Anonymous, 1)
+	}
+	if removed := -event.ByteDelta(); removed > 0 {
+		signal(w.Removal, math.Min(float64(removed)/largeRemoval, 1))
+	}
+	if blanked(event) {
+		signal(w.Blanked, 1)
+	}
+	comment := strings.TrimSpace(sectionComment.ReplaceAllString(event.Comment, ""))
+	if comment == "" {
+		signal(w.EmptyComment, 1)
+	} else if badComment(comment) {
+		signal(w.BadComment, 1)
+	}
+	if created, ok := h.accounts[who]; ok {
+		if event.Timestamp-created < int64(newAccountAge.Seconds()) {
+			signal(w.NewAccount, 1)
+		} else {
+			delete(h.accounts, who)
+		}
+	}
+	if n := h.trackEdit(who, event.Timestamp); n > 1 {
+		signal(w.Rapid, math.Min(float64(n-1)/(rapidEdits-1), 1))
+	}
+	return math.Round((1-left)*100) / 100
+}
+
+func (h *Heuristic) trackAccount(who editor, timestamp int64) {
+	if len(h.accounts) >= maxTracked {
+		for k, created := range h.accounts {
+			if timestamp-created >= int64(newAccountAge.Seconds()) {
+				delete(h.accounts, k)
+			}
+		}
+		if len(h.accounts) >= maxTracked {
+			return
+		}
+	}
+	h.accounts[who] = timestamp
+}
+
+// trackEdit records an edit and returns how many edits the editor made
+// within rapidWindow, including this one.
+func (h *Heuristic) trackEdit(who editor, timestamp int64) int {
+	since := timestamp - int64(rapidWindow.Seconds())
+	recent := h.edits[who]
+	kept := recent[:0]
+	for _, t := range recent {
+		if t > since {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 && len(h.edits) >= maxTracked {
+		for k, times := range h.edits {
+			if times[len(times)-1] <= since {
+				delete(h.edits, k)
+			}
+		}
+		if len(h.edits) >= maxTracked {
+			return 1
+		}
+	}
+	h.edits[who] = append(kept, timestamp)
+	return len(h.edits[who])
+}
+
+// anonymous reports whether user is an IP address or a temporary account,
+// whose names start with a tilde.
+func anonymous(user string) bool {
+	return net.ParseIP(user) != nil || strings.HasPrefix(user, "~")
+}
+
+func blanked(event *models.RecentChangeEvent) bool {
+	before, after := event.Length.Old, event.Length.New
+	if before > 0 && after == 0 {
+		return true
+	}
+	return before >= minBlankedSize && float64(after) <= float64(before)*blankedLeft
+}
+
+// badComment reports whether a summary shouts, repeats a character five
+// times or more, or contains a bad word.
+func badComment(comment string) bool {
+	letters, upper, run := 0, 0, 0
+	var prev rune
+	for _, r := range comment {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+		if r == prev && !unicode.IsSpace(r) {
+			run++
+		} else {
+			run = 1
+		}
+		if run >= 5 {
+			return true
+		}
+		prev = r
+	}
+	if letters >= 8 && float64(upper) > 0.8*float64(letters) {
+		return true
+	}
+	words := strings.FieldsFunc(strings.ToLower(comment), func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, word := range words {
+		if badWords[word] {
+			return true
+		}
+	}
+	return false
+}
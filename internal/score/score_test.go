@@ -0,0 +1,79 @@
+package score
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+// labelled is a hand-labelled example: the events before it, in stream
+// order, and the event to score.
+type labelled struct {
+	Name      string                      `json:"name"`
+	Vandalism bool                        `json:"vandalism"`
+	History   []*models.RecentChangeEvent `json:"history"`
+	Event     *models.RecentChangeEvent   `json:"event"`
+}
+
+func TestHeuristicLabelledFixtures(t *testing.T) {
+	data, err := os.ReadFile("testdata/labelled.json")
+	require.NoError(t, err)
+	var cases []labelled
+	require.NoError(t, json.Unmarshal(data, &cases))
+	require.NotEmpty(t, cases)
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			h := NewHeuristic(DefaultWeights)
+			for _, e := range c.History {
+				h.Score("en", e)
+			}
+			got := h.Score("en", c.Event)
+			assert.Equal(t, c.Vandalism, got > Suspicious, "score %.2f", got)
+		})
+	}
+}
+
+func TestHeuristicSignals(t *testing.T) {
+	h := NewHeuristic(DefaultWeights)
+	blanking := &models.RecentChangeEvent{Type: "edit", User: "203.0.113.5", Length: models.EventLength{Old: 4200}}
+	assert.Equal(t, 0.94, h.Score("en", blanking))
+
+	// The same editor on another wiki is someone else as far as rapid
+	// editing goes.
+	assert.Equal(t, 0.94, h.Score("de", blanking))
+	assert.Equal(t, 0.95, h.Score("en", blanking))
+
+	// Zero weights switch signals off.
+	quiet := NewHeuristic(Weights{Blanked: 1})
+	assert.Equal(t, 0.0, quiet.Score("en", &models.RecentChangeEvent{Type: "edit", User: "203.0.113.5", Comment: "LOL"}))
+	assert.Equal(t, 1.0, quiet.Score("en", blanking))
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWeights, w)
+
+	w, err = ParseWeights(" anon=0.1, Blanked=1 ,rapid=0")
+	require.NoError(t, err)
+	assert.Equal(t, 0.1, w.Anonymous)
+	assert.Equal(t, 1.0, w.Blanked)
+	assert.Equal(t, 0.0, w.Rapid)
+	assert.Equal(t, DefaultWeights.Removal, w.Removal)
+
+	for input, msg := range map[string]string{
+		"ip=0.5":    `unknown signal "ip"`,
+		"anon":      `"anon" is not a weight between 0 and 1`,
+		"anon=1.5":  `"anon=1.5" is not a weight between 0 and 1`,
+		"anon=high": `"anon=high" is not a weight between 0 and 1`,
+	} {
+		_, err := ParseWeights(input)
+		require.ErrorIs(t, err, ErrInvalidWeights, input)
+		assert.Contains(t, err.Error(), msg, input)
+	}
+}
@@ -0,0 +1,155 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+// PersonalKeys lists the settings users can change for themselves, in
+// display order.
+var PersonalKeys = []string{KeyTimezone, KeyStatsDays}
+
+type PersonalStore interface {
+	Get(ctx context.Context, userID string) (*models.UserSettings, error)
+	Save(ctx context.Context, us *models.UserSettings) error
+	Delete(ctx context.Context, userID string) error
+}
+
+// Personal keeps users' personal settings in memory in front of the store,
+// like Service does for guilds.
+type Personal struct {
+	store PersonalStore
+
+	mu    sync.RWMutex
+	cache map[string]models.UserSettings
+
+	writeMu sync.Mutex
+}
+
+func NewPersonal(s PersonalStore) *Personal {
+	return &Personal{
+		store: s,
+		cache: make(map[string]models.UserSettings),
+	}
+}
+
+// Get returns the user's settings. Users without stored settings get
+// zero-valued settings, which means "use the defaults".
+func (p *Personal) Get(ctx context.Context, userID string) (models.UserSettings, error) {
+	p.mu.RLock()
+	us, ok := p.cache[userID]
+	p.mu.RUnlock()
+	if ok {
+		return us, nil
+	}
+
+	stored, err := p.store.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		stored = &models.UserSettings{UserID: userID}
+	} else if err != nil {
+		return models.UserSettings{UserID: userID}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Another goroutine may have stored a newer value while we were loading.
+	if cached, ok := p.cache[userID]; ok {
+		return cached, nil
+	}
+	p.cache[userID] = *stored
+	return *stored, nil
+}
+
+// Set parses value for key and stores it. An empty value resets the key.
+func (p *Personal) Set(ctx context.Context, userID, key, value string) (models.UserSettings, error) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	us, err := p.Get(ctx, userID)
+	if err != nil {
+		return models.UserSettings{}, err
+	}
+	if err := ApplyPersonal(&us, key, value); err != nil {
+		return models.UserSettings{}, err
+	}
+	us.UserID = userID
+	if err := p.store.Save(ctx, &us); err != nil {
+		return models.UserSettings{}, err
+	}
+
+	p.mu.Lock()
+	p.cache[userID] = us
+	p.mu.Unlock()
+	return us, nil
+}
+
+// Reset removes all stored settings for the user.
+func (p *Personal) Reset(ctx context.Context, userID string) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if err := p.store.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.cache[userID] = models.UserSettings{UserID: userID}
+	p.mu.Unlock()
+	return nil
+}
+
+// ApplyPersonal parses value and assigns it to key. An empty value resets
+// the key.
+func ApplyPersonal(us *models.UserSettings, key, value string) error {
+	value = strings.TrimSpace(value)
+	switch key {
+	case KeyTimezone:
+		if err := checkTimezone(value); err != nil {
+			return err
+		}
+		us.Timezone = value
+	case KeyStatsDays:
+		local, err := parseStatsDays(value)
+		if err != nil {
+			return err
+		}
+		us.LocalStatsDays = local
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	return nil
+}
+
+// PersonalValue formats the current value of key for display.
+func PersonalValue(us models.UserSettings, key string) string {
+	switch key {
+	case KeyTimezone:
+		return us.Timezone
+	case KeyStatsDays:
+		if us.LocalStatsDays {
+			return StatsDaysLocal
+		}
+	}
+	return ""
+}
+
+// PersonalLocation returns the user's timezone, or UTC when none is set.
+func PersonalLocation(us models.UserSettings) *time.Location {
+	return zone(us.Timezone)
+}
+
+// PersonalStatsLocation returns the zone whose days !stats dates refer to
+// in the user's direct messages.
+func PersonalStatsLocation(us models.UserSettings) *time.Location {
+	if !us.LocalStatsDays {
+		return time.UTC
+	}
+	return PersonalLocation(us)
+}
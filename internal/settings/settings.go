@@ -0,0 +1,460 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/sitematrix"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+const (
+	KeyLang             = "lang"
+	KeyPrefix           = "prefix"
+	KeyTimezone         = "timezone"
+	KeyStatsDays        = "stats_days"
+	KeyFeedChannels     = "feed_channels"
+	KeyDisabledCommands = "disabled_commands"
+)
+
+// Keys lists the settings that can be changed with !config, in display order.
+var Keys = []string{KeyLang, KeyPrefix, KeyTimezone, KeyStatsDays, KeyFeedChannels, KeyDisabledCommands}
+
+const (
+	// StatsDaysUTC reads !stats dates as UTC days, the default.
+	StatsDaysUTC = "utc"
+	// StatsDaysLocal reads !stats dates as days in the guild's timezone.
+	StatsDaysLocal = "local"
+)
+
+const (
+	// MaxAliases limits how many custom aliases a guild can define.
+	MaxAliases = 50
+	// MaxRoleGrants limits how many roles can be granted one command.
+	MaxRoleGrants = 25
+)
+
+var (
+	ErrUnknownKey   = errors.New("unknown setting")
+	ErrInvalidValue = errors.New("invalid value")
+	ErrUnknownAlias = errors.New("unknown alias")
+	ErrTooManyAlias = errors.New("too many aliases")
+	ErrUnknownGrant = errors.New("unknown grant")
+	ErrTooManyGrant = errors.New("too many grants")
+
+	channelRe   = regexp.MustCompile(`^(?:<#)?(\d+)>?$`)
+	roleRe      = regexp.MustCompile(`^(?:<@&)?(\d+)>?$`)
+	aliasNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+)
+
+type Store interface {
+	Get(ctx context.Context, guildID string) (*models.GuildSettings, error)
+	Save(ctx context.Context, gs *models.GuildSettings) error
+	Delete(ctx context.Context, guildID string) error
+}
+
+// Service keeps per-guild settings in memory in front of the store. It is
+// safe for concurrent use by Discord handler goroutines; reads are served
+// from the cache and writes for all guilds are serialized.
+type Service struct {
+	store Store
+
+	mu    sync.RWMutex
+	cache map[string]*models.GuildSettings
+
+	writeMu sync.Mutex
+}
+
+func New(s Store) *Service {
+	return &Service{
+		store: s,
+		cache: make(map[string]*models.GuildSettings),
+	}
+}
+
+// Get returns a copy of the guild's settings. Guilds without stored settings
+// get zero-valued settings, which means "use the defaults".
+func (s *Service) Get(ctx context.Context, guildID string) (models.GuildSettings, error) {
+	s.mu.RLock()
+	gs, ok := s.cache[guildID]
+	s.mu.RUnlock()
+	if ok {
+		return clone(gs), nil
+	}
+
+	gs, err := s.load(ctx, guildID)
+	if err != nil {
+		return models.GuildSettings{GuildID: guildID}, err
+	}
+	return clone(gs), nil
+}
+
+// Update applies fn to the guild's settings and persists the result. The
+// cache is only updated once the store accepted the change.
+func (s *Service) Update(ctx context.Context, guildID string, fn func(gs *models.GuildSettings) error) (models.GuildSettings, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	current, err := s.load(ctx, guildID)
+	if err != nil {
+		return models.GuildSettings{}, err
+	}
+
+	next := clone(current)
+	if err := fn(&next); err != nil {
+		return models.GuildSettings{}, err
+	}
+	next.GuildID = guildID
+
+	if err := s.store.Save(ctx, &next); err != nil {
+		return models.GuildSettings{}, err
+	}
+
+	s.mu.Lock()
+	saved := clone(&next)
+	s.cache[guildID] = &saved
+	s.mu.Unlock()
+
+	return next, nil
+}
+
+// Set parses value for key and stores it.
+func (s *Service) Set(ctx context.Context, guildID, key, value string) (models.GuildSettings, error) {
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		return Apply(gs, key, value)
+	})
+}
+
+// ResetKey restores a single setting to its default.
+func (s *Service) ResetKey(ctx context.Context, guildID, key string) (models.GuildSettings, error) {
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		return Apply(gs, key, "")
+	})
+}
+
+// Reset removes all stored settings for the guild.
+func (s *Service) Reset(ctx context.Context, guildID string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.store.Delete(ctx, guildID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cache[guildID] = &models.GuildSettings{GuildID: guildID}
+	s.mu.Unlock()
+	return nil
+}
+
+// SetAlias makes name expand to expansion in the guild. Names are stored
+// lower-case; checking that expansion starts with a real command is up to
+// the caller.
+func (s *Service) SetAlias(ctx context.Context, guildID, name, expansion string) (models.GuildSettings, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "!"))
+	if !aliasNameRe.MatchString(name) {
+		return models.GuildSettings{}, fmt.Errorf("%w: alias names may only contain letters, digits, - and _", ErrInvalidValue)
+	}
+	expansion = strings.TrimSpace(expansion)
+	if expansion == "" {
+		return models.GuildSettings{}, fmt.Errorf("%w: alias needs a command to expand to", ErrInvalidValue)
+	}
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		if gs.Aliases == nil {
+			gs.Aliases = make(map[string]string)
+		}
+		if _, exists := gs.Aliases[name]; !exists && len(gs.Aliases) >= MaxAliases {
+			return fmt.Errorf("%w: a server can have at most %d aliases", ErrTooManyAlias, MaxAliases)
+		}
+		gs.Aliases[name] = expansion
+		return nil
+	})
+}
+
+// RemoveAlias deletes a custom alias.
+func (s *Service) RemoveAlias(ctx context.Context, guildID, name string) (models.GuildSettings, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "!"))
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		if _, ok := gs.Aliases[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAlias, name)
+		}
+		delete(gs.Aliases, name)
+		return nil
+	})
+}
+
+// GrantRole lets members with the role run command. command must be the
+// command's canonical name; role may be an ID or a role mention.
+func (s *Service) GrantRole(ctx context.Context, guildID, command, role string) (models.GuildSettings, error) {
+	roleID, err := ParseRole(role)
+	if err != nil {
+		return models.GuildSettings{}, err
+	}
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		roles := gs.RoleGrants[command]
+		for _, r := range roles {
+			if r == roleID {
+				return nil
+			}
+		}
+		if len(roles) >= MaxRoleGrants {
+			return fmt.Errorf("%w: a command can be granted to at most %d roles", ErrTooManyGrant, MaxRoleGrants)
+		}
+		if gs.RoleGrants == nil {
+			gs.RoleGrants = make(map[string][]string)
+		}
+		gs.RoleGrants[command] = append(roles, roleID)
+		sort.Strings(gs.RoleGrants[command])
+		return nil
+	})
+}
+
+// RevokeRole removes a grant made with GrantRole.
+func (s *Service) RevokeRole(ctx context.Context, guildID, command, role string) (models.GuildSettings, error) {
+	roleID, err := ParseRole(role)
+	if err != nil {
+		return models.GuildSettings{}, err
+	}
+	return s.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+		roles := gs.RoleGrants[command]
+		for i, r := range roles {
+			if r != roleID {
+				continue
+			}
+			roles = append(roles[:i:i], roles[i+1:]...)
+			if len(roles) == 0 {
+				delete(gs.RoleGrants, command)
+			} else {
+				gs.RoleGrants[command] = roles
+			}
+			return nil
+		}
+		return fmt.Errorf("%w: <@&%s> has no grant for %s", ErrUnknownGrant, roleID, command)
+	})
+}
+
+func (s *Service) load(ctx context.Context, guildID string) (*models.GuildSettings, error) {
+	s.mu.RLock()
+	gs, ok := s.cache[guildID]
+	s.mu.RUnlock()
+	if ok {
+		return gs, nil
+	}
+
+	gs, err := s.store.Get(ctx, guildID)
+	if errors.Is(err, store.ErrNotFound) {
+		gs = &models.GuildSettings{GuildID: guildID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another goroutine may have stored a newer value while we were loading.
+	if cached, ok := s.cache[guildID]; ok {
+		return cached, nil
+	}
+	s.cache[guildID] = gs
+	return gs, nil
+}
+
+// Apply parses value and assigns it to key. An empty value resets the key.
+func Apply(gs *models.GuildSettings, key, value string) error {
+	value = strings.TrimSpace(value)
+	switch key {
+	case KeyLang:
+		value = sitematrix.Normalize(value)
+		if value != "" && !sitematrix.Default().Valid(value) {
+			return fmt.Errorf("%w: unknown language code %q", ErrInvalidValue, value)
+		}
+		gs.Lang = value
+	case KeyPrefix:
+		if len(value) > 5 || strings.ContainsAny(value, " \t\n") {
+			return fmt.Errorf("%w: prefix must be at most 5 characters without spaces", ErrInvalidValue)
+		}
+		gs.Prefix = value
+	case KeyTimezone:
+		if err := checkTimezone(value); err != nil {
+			return err
+		}
+		gs.Timezone = value
+	case KeyStatsDays:
+		local, err := parseStatsDays(value)
+		if err != nil {
+			return err
+		}
+		gs.LocalStatsDays = local
+	case KeyFeedChannels:
+		channels, err := parseChannels(value)
+		if err != nil {
+			return err
+		}
+		gs.FeedChannels = channels
+	case KeyDisabledCommands:
+		commands := splitList(value)
+		sort.Strings(commands)
+		gs.DisabledCommands = commands
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	return nil
+}
+
+// Value formats the current value of key for display.
+func Value(gs models.GuildSettings, key string) string {
+	switch key {
+	case KeyLang:
+		return gs.Lang
+	case KeyPrefix:
+		return gs.Prefix
+	case KeyTimezone:
+		return gs.Timezone
+	case KeyStatsDays:
+		if gs.LocalStatsDays {
+			return StatsDaysLocal
+		}
+		return ""
+	case KeyFeedChannels:
+		mentions := make([]string, len(gs.FeedChannels))
+		for i, id := range gs.FeedChannels {
+			mentions[i] = "<#" + id + ">"
+		}
+		return strings.Join(mentions, ", ")
+	case KeyDisabledCommands:
+		return strings.Join(gs.DisabledCommands, ", ")
+	}
+	return ""
+}
+
+// CommandEnabled reports whether name is allowed to run in the guild.
+func CommandEnabled(gs models.GuildSettings, name string) bool {
+	for _, c := range gs.DisabledCommands {
+		if strings.EqualFold(c, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Location returns the guild's timezone, or UTC when none is set. Stored
+// names were validated when set, but a name the running system no longer
+// knows also falls back to UTC.
+func Location(gs models.GuildSettings) *time.Location {
+	return zone(gs.Timezone)
+}
+
+// StatsLocation returns the zone whose days !stats dates refer to.
+func StatsLocation(gs models.GuildSettings) *time.Location {
+	if !gs.LocalStatsDays {
+		return time.UTC
+	}
+	return Location(gs)
+}
+
+// RoleGranted reports whether any of roles was granted command.
+func RoleGranted(gs models.GuildSettings, command string, roles []string) bool {
+	for _, granted := range gs.RoleGrants[command] {
+		for _, r := range roles {
+			if r == granted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseRole accepts a role ID or a role mention and returns the ID.
+func ParseRole(value string) (string, error) {
+	match := roleRe.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", fmt.Errorf("%w: %q is not a role", ErrInvalidValue, value)
+	}
+	return match[1], nil
+}
+
+func checkTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidValue, name)
+	}
+	return nil
+}
+
+func parseStatsDays(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", StatsDaysUTC:
+		return false, nil
+	case StatsDaysLocal:
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: stats days must be %s or %s", ErrInvalidValue, StatsDaysUTC, StatsDaysLocal)
+}
+
+func zone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func parseChannels(value string) ([]string, error) {
+	var channels []string
+	for _, item := range splitList(value) {
+		match := channelRe.FindStringSubmatch(item)
+		if match == nil {
+			return nil, fmt.Errorf("%w: %q is not a channel", ErrInvalidValue, item)
+		}
+		channels = append(channels, match[1])
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+func splitList(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	seen := make(map[string]bool, len(fields))
+	var out []string
+	for _, f := range fields {
+		f = strings.TrimPrefix(f, "!")
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+func clone(gs *models.GuildSettings) models.GuildSettings {
+	cp := *gs
+	cp.FeedChannels = append([]string(nil), gs.FeedChannels...)
+	cp.DisabledCommands = append([]string(nil), gs.DisabledCommands...)
+	if gs.Aliases != nil {
+		cp.Aliases = make(map[string]string, len(gs.Aliases))
+		for k, v := range gs.Aliases {
+			cp.Aliases[k] = v
+		}
+	}
+	if gs.RoleGrants != nil {
+		cp.RoleGrants = make(map[string][]string, len(gs.RoleGrants))
+		for k, v := range gs.RoleGrants {
+			cp.RoleGrants[k] = append([]string(nil), v...)
+		}
+	}
+	return cp
+}
@@ -0,0 +1,172 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/store"
+)
+
+func TestServiceSetAndReset(t *testing.T) {
+	mockStore := &store.MockSettingsStore{}
+	svc := New(mockStore)
+	ctx := context.Background()
+
+	gs, err := svc.Get(ctx, "guild1")
+	require.NoError(t, err)
+	assert.Equal(t, models.GuildSettings{GuildID: "guild1"}, gs)
+
+	_, err = svc.Set(ctx, "guild1", KeyTimezone, "Europe/Berlin")
+	require.NoError(t, err)
+	_, err = svc.Set(ctx, "guild1", KeyFeedChannels, "<#123>, 456")
+	require.NoError(t, err)
+
+	gs, err = svc.Get(ctx, "guild1")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", gs.Timezone)
+	assert.Equal(t, []string{"123", "456"}, gs.FeedChannels)
+	assert.Equal(t, "Europe/Berlin", mockStore.Settings["guild1"].Timezone)
+
+	_, err = svc.Set(ctx, "guild1", KeyTimezone, "Mars/Olympus")
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	_, err = svc.Set(ctx, "guild1", "colour", "blue")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = svc.ResetKey(ctx, "guild1", KeyTimezone)
+	require.NoError(t, err)
+	gs, _ = svc.Get(ctx, "guild1")
+	assert.Empty(t, gs.Timezone)
+	assert.Equal(t, []string{"123", "456"}, gs.FeedChannels)
+
+	require.NoError(t, svc.Reset(ctx, "guild1"))
+	gs, _ = svc.Get(ctx, "guild1")
+	assert.Equal(t, models.GuildSettings{GuildID: "guild1"}, gs)
+}
+
+func TestLocations(t *testing.T) {
+	gs := models.GuildSettings{}
+	assert.Equal(t, time.UTC, Location(gs))
+	assert.Equal(t, time.UTC, StatsLocation(gs))
+
+	require.NoError(t, Apply(&gs, KeyTimezone, "Asia/Tokyo"))
+	assert.Equal(t, "Asia/Tokyo", Location(gs).String())
+	assert.Equal(t, time.UTC, StatsLocation(gs), "stats stay on UTC days until enabled")
+
+	require.NoError(t, Apply(&gs, KeyStatsDays, "Local"))
+	assert.Equal(t, StatsDaysLocal, Value(gs, KeyStatsDays))
+	assert.Equal(t, "Asia/Tokyo", StatsLocation(gs).String())
+
+	assert.ErrorIs(t, Apply(&gs, KeyStatsDays, "eastern"), ErrInvalidValue)
+	require.NoError(t, Apply(&gs, KeyStatsDays, ""))
+	assert.False(t, gs.LocalStatsDays)
+	assert.Empty(t, Value(gs, KeyStatsDays))
+}
+
+func TestServiceGetReturnsCopy(t *testing.T) {
+	svc := New(&store.MockSettingsStore{})
+	ctx := context.Background()
+
+	_, err := svc.Set(ctx, "guild1", KeyDisabledCommands, "stats")
+	require.NoError(t, err)
+
+	gs, _ := svc.Get(ctx, "guild1")
+	gs.DisabledCommands[0] = "recent"
+
+	gs, _ = svc.Get(ctx, "guild1")
+	assert.Equal(t, []string{"stats"}, gs.DisabledCommands)
+}
+
+func TestServiceConcurrentUpdates(t *testing.T) {
+	svc := New(&store.MockSettingsStore{})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			guildID := fmt.Sprintf("guild%d", i%5)
+			_, err := svc.Update(ctx, guildID, func(gs *models.GuildSettings) error {
+				gs.DisabledCommands = append(gs.DisabledCommands, fmt.Sprintf("cmd%d", i))
+				return nil
+			})
+			assert.NoError(t, err)
+			_, err = svc.Get(ctx, guildID)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := 0; i < 5; i++ {
+		gs, err := svc.Get(ctx, fmt.Sprintf("guild%d", i))
+		require.NoError(t, err)
+		total += len(gs.DisabledCommands)
+	}
+	assert.Equal(t, 50, total, "no update may be lost")
+}
+
+func TestServiceRoleGrants(t *testing.T) {
+	svc := New(&store.MockSettingsStore{})
+	ctx := context.Background()
+
+	_, err := svc.GrantRole(ctx, "guild1", "watch", "<@&200>")
+	require.NoError(t, err)
+	_, err = svc.GrantRole(ctx, "guild1", "watch", "100")
+	require.NoError(t, err)
+	gs, err := svc.GrantRole(ctx, "guild1", "watch", "100")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"100", "200"}, gs.RoleGrants["watch"])
+
+	assert.True(t, RoleGranted(gs, "watch", []string{"300", "200"}))
+	assert.False(t, RoleGranted(gs, "watch", []string{"300"}))
+	assert.False(t, RoleGranted(gs, "feed", []string{"200"}))
+
+	_, err = svc.GrantRole(ctx, "guild1", "watch", "moderators")
+	assert.ErrorIs(t, err, ErrInvalidValue)
+
+	_, err = svc.RevokeRole(ctx, "guild1", "watch", "100")
+	require.NoError(t, err)
+	gs, err = svc.RevokeRole(ctx, "guild1", "watch", "200")
+	require.NoError(t, err)
+	assert.NotContains(t, gs.RoleGrants, "watch")
+
+	_, err = svc.RevokeRole(ctx, "guild1", "watch", "200")
+	assert.ErrorIs(t, err, ErrUnknownGrant)
+}
+
+func TestPersonalSetAndReset(t *testing.T) {
+	mockStore := &store.MockUserSettingsStore{}
+	personal := NewPersonal(mockStore)
+	ctx := context.Background()
+
+	us, err := personal.Get(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, models.UserSettings{UserID: "user1"}, us)
+
+	_, err = personal.Set(ctx, "user1", KeyTimezone, "Asia/Almaty")
+	require.NoError(t, err)
+	us, err = personal.Set(ctx, "user1", KeyStatsDays, "LOCAL")
+	require.NoError(t, err)
+	assert.Equal(t, models.UserSettings{UserID: "user1", Timezone: "Asia/Almaty", LocalStatsDays: true}, us)
+	assert.Equal(t, "Asia/Almaty", mockStore.Settings["user1"].Timezone)
+	assert.Equal(t, "Asia/Almaty", PersonalStatsLocation(us).String())
+
+	// Guild-only settings cannot be set personally.
+	_, err = personal.Set(ctx, "user1", KeyPrefix, "?")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = personal.Set(ctx, "user1", KeyTimezone, "Mars/Olympus")
+	assert.ErrorIs(t, err, ErrInvalidValue)
+
+	require.NoError(t, personal.Reset(ctx, "user1"))
+	us, err = personal.Get(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, PersonalLocation(us))
+	assert.Empty(t, mockStore.Settings)
+}
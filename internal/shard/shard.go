@@ -0,0 +1,314 @@
+// Package shard runs the bot's gateway connections. Discord caps how many
+// guilds one connection may serve, so large bots split their guilds into
+// shards, each with its own session; a guild belongs to shard
+// (guild ID >> 22) % shard count. A process can run every shard or only
+// some of them, with other processes running the rest.
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// identifyInterval is how long Discord wants between two identifies in the
+// same rate limit bucket.
+const identifyInterval = 5 * time.Second
+
+var ErrInvalidConfig = errors.New("invalid shard configuration")
+
+// Shard states reported by Status.
+const (
+	StateIdle         = "idle"
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+	StateFailed       = "failed"
+)
+
+// Config says how many shards the bot has and which of them this process
+// runs.
+type Config struct {
+	// Count is the total number of shards. Zero uses the count Discord
+	// recommends for the bot.
+	Count int
+	// IDs are the shards this process runs. Empty means all of them.
+	IDs []int
+}
+
+// ParseIDs parses a selection of shards such as "0-3" or "0,2,4-5". An
+// empty selection means all shards.
+func ParseIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("%w: %q is not a shard ID", ErrInvalidConfig, part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || last < first {
+				return nil, fmt.Errorf("%w: %q is not a shard range", ErrInvalidConfig, part)
+			}
+		}
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// For returns the shard that serves guildID when the bot has count shards.
+// Direct messages, which have no guild, are served by shard 0.
+func For(guildID string, count int) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil || count <= 1 {
+		return 0
+	}
+	return int((id >> 22) % uint64(count))
+}
+
+// Status describes one shard.
+type Status struct {
+	ID    int    `json:"id"`
+	State string `json:"state"`
+	// Since is when the shard entered State.
+	Since   time.Time     `json:"since"`
+	Guilds  int           `json:"guilds"`
+	Latency time.Duration `json:"latency"`
+	// Error is why the shard last failed to connect.
+	Error string `json:"error,omitempty"`
+}
+
+type shard struct {
+	id      int
+	session *discordgo.Session
+	state   string
+	since   time.Time
+	err     error
+}
+
+// Manager holds one session per shard this process runs.
+type Manager struct {
+	count  int
+	shards []*shard
+	// concurrency is how many shards may identify at once.
+	concurrency int
+
+	// open, wait and now are replaceable for tests.
+	open func(*discordgo.Session) error
+	wait func(time.Duration)
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// New creates the sessions for the shards cfg selects. They connect when
+// Open is called.
+func New(token string, cfg Config) (*Manager, error) {
+	count, concurrency := cfg.Count, 1
+	if count == 0 {
+		if len(cfg.IDs) > 0 {
+			// Processes running different shards must agree on the count,
+			// which Discord's recommendation does not guarantee.
+			return nil, fmt.Errorf("%w: running selected shards needs a shard count", ErrInvalidConfig)
+		}
+		var err error
+		if count, concurrency, err = recommended(token); err != nil {
+			return nil, err
+		}
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("%w: shard count must be at least 1", ErrInvalidConfig)
+	}
+
+	ids, err := selectIDs(cfg.IDs, count)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		count:       count,
+		concurrency: concurrency,
+		open:        (*discordgo.Session).Open,
+		wait:        time.Sleep,
+		now:         time.Now,
+	}
+	for _, id := range ids {
+		s, err := discordgo.New("Bot " + token)
+		if err != nil {
+			return nil, err
+		}
+		s.ShardID = id
+		s.ShardCount = count
+		sh := &shard{id: id, session: s, state: StateIdle, since: m.now()}
+		s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) { m.setState(sh, StateConnected, nil) })
+		s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) { m.setState(sh, StateDisconnected, nil) })
+		m.shards = append(m.shards, sh)
+	}
+	return m, nil
+}
+
+// recommended asks Discord how many shards the bot should have and how many
+// may identify at once.
+func recommended(token string) (int, int, error) {
+	s, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return 0, 0, err
+	}
+	gw, err := s.GatewayBot()
+	if err != nil {
+		return 0, 0, fmt.Errorf("getting the recommended shard count: %w", err)
+	}
+	concurrency := gw.SessionStartLimit.MaxConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return gw.Shards, concurrency, nil
+}
+
+func selectIDs(ids []int, count int) ([]int, error) {
+	if len(ids) == 0 {
+		all := make([]int, count)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+	seen := make(map[int]bool, len(ids))
+	var out []int
+	for _, id := range ids {
+		if id < 0 || id >= count {
+			return nil, fmt.Errorf("%w: shard %d is out of range for %d shards", ErrInvalidConfig, id, count)
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// Count returns the total number of shards, including those other
+// processes run.
+func (m *Manager) Count() int {
+	return m.count
+}
+
+// Runs reports whether this process runs shard id.
+func (m *Manager) Runs(id int) bool {
+	for _, sh := range m.shards {
+		if sh.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Serves reports whether this process runs the shard that serves guildID.
+// With several processes, only that one posts for the guild.
+func (m *Manager) Serves(guildID string) bool {
+	return m.Runs(For(guildID, m.count))
+}
+
+// Session returns a session for REST calls, which do not depend on the
+// shard.
+func (m *Manager) Session() *discordgo.Session {
+	return m.shards[0].session
+}
+
+// Sessions returns the session of every shard this process runs.
+func (m *Manager) Sessions() []*discordgo.Session {
+	sessions := make([]*discordgo.Session, len(m.shards))
+	for i, sh := range m.shards {
+		sessions[i] = sh.session
+	}
+	return sessions
+}
+
+// AddHandler registers handler with every shard's session.
+func (m *Manager) AddHandler(handler interface{}) {
+	for _, sh := range m.shards {
+		sh.session.AddHandler(handler)
+	}
+}
+
+// Open connects the shards in order, waiting between identifies as Discord
+// requires. If a shard fails to connect, the ones already open are closed
+// again.
+func (m *Manager) Open() error {
+	for i, sh := range m.shards {
+		if i > 0 && i%m.concurrency == 0 {
+			m.wait(identifyInterval)
+		}
+		m.setState(sh, StateConnecting, nil)
+		if err := m.open(sh.session); err != nil {
+			m.setState(sh, StateFailed, err)
+			for _, opened := range m.shards[:i] {
+				opened.session.Close()
+			}
+			return fmt.Errorf("shard %d: %w", sh.id, err)
+		}
+		m.setState(sh, StateConnected, nil)
+	}
+	return nil
+}
+
+// Close disconnects every shard.
+func (m *Manager) Close() {
+	for _, sh := range m.shards {
+		if err := sh.session.Close(); err != nil {
+			log.Printf("Failed to close shard %d: %v", sh.id, err)
+		}
+		m.setState(sh, StateDisconnected, nil)
+	}
+}
+
+func (m *Manager) setState(sh *shard, state string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sh.state == state {
+		return
+	}
+	sh.state = state
+	sh.since = m.now()
+	if err != nil {
+		sh.err = err
+	}
+	log.Printf("Shard %d of %d is %s", sh.id, m.count, state)
+}
+
+// Status reports on every shard this process runs.
+func (m *Manager) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]Status, len(m.shards))
+	for i, sh := range m.shards {
+		st := Status{ID: sh.id, State: sh.state, Since: sh.since}
+		if sh.err != nil {
+			st.Error = sh.err.Error()
+		}
+		if sh.state == StateConnected {
+			st.Latency = sh.session.HeartbeatLatency()
+		}
+		if state := sh.session.State; state != nil {
+			state.RLock()
+			st.Guilds = len(state.Guilds)
+			state.RUnlock()
+		}
+		statuses[i] = st
+	}
+	return statuses
+}
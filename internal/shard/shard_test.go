@@ -0,0 +1,124 @@
+package shard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDs(t *testing.T) {
+	ids, err := ParseIDs("0, 2,4-6")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 4, 5, 6}, ids)
+
+	ids, err = ParseIDs("")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	for _, bad := range []string{"a", "-1", "3-1", "1-x"} {
+		_, err := ParseIDs(bad)
+		assert.ErrorIs(t, err, ErrInvalidConfig, bad)
+	}
+}
+
+func TestFor(t *testing.T) {
+	// The shard is the timestamp part of the snowflake modulo the count.
+	const guildID = "197038439483310086"
+	assert.Equal(t, int((uint64(197038439483310086)>>22)%16), For(guildID, 16))
+	assert.Equal(t, 0, For(guildID, 1))
+	assert.Equal(t, 0, For("", 4), "direct messages are on shard 0")
+}
+
+func TestServes(t *testing.T) {
+	m, err := New("token", Config{Count: 2, IDs: []int{1}})
+	require.NoError(t, err)
+	// Snowflakes with 1 and 2 in their timestamp part.
+	assert.True(t, m.Serves("4194304"))
+	assert.False(t, m.Serves("8388608"))
+	assert.False(t, m.Serves(""), "direct messages are on shard 0")
+}
+
+func TestNewSelectsShards(t *testing.T) {
+	m, err := New("token", Config{Count: 8, IDs: []int{5, 2, 5}})
+	require.NoError(t, err)
+	assert.Equal(t, 8, m.Count())
+	require.Len(t, m.Sessions(), 2)
+	assert.Equal(t, 2, m.Sessions()[0].ShardID)
+	assert.Equal(t, 5, m.Sessions()[1].ShardID)
+	assert.Equal(t, 8, m.Sessions()[1].ShardCount)
+	assert.True(t, m.Runs(5))
+	assert.False(t, m.Runs(0))
+
+	m, err = New("token", Config{Count: 3})
+	require.NoError(t, err)
+	assert.Len(t, m.Sessions(), 3)
+
+	_, err = New("token", Config{Count: 4, IDs: []int{4}})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = New("token", Config{IDs: []int{0}})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = New("token", Config{Count: -1})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestOpenSpacesIdentifies(t *testing.T) {
+	m, err := New("token", Config{Count: 5})
+	require.NoError(t, err)
+	m.concurrency = 2
+	var events []string
+	m.open = func(s *discordgo.Session) error {
+		events = append(events, "open")
+		return nil
+	}
+	m.wait = func(d time.Duration) {
+		assert.Equal(t, identifyInterval, d)
+		events = append(events, "wait")
+	}
+
+	require.NoError(t, m.Open())
+	assert.Equal(t, []string{"open", "open", "wait", "open", "open", "wait", "open"}, events)
+	for _, st := range m.Status() {
+		assert.Equal(t, StateConnected, st.State)
+	}
+}
+
+func TestOpenFailureIsReported(t *testing.T) {
+	m, err := New("token", Config{Count: 3})
+	require.NoError(t, err)
+	m.wait = func(time.Duration) {}
+	m.open = func(s *discordgo.Session) error {
+		if s.ShardID == 1 {
+			return errors.New("authentication failed")
+		}
+		return nil
+	}
+
+	err = m.Open()
+	assert.ErrorContains(t, err, "shard 1: authentication failed")
+	statuses := m.Status()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, StateFailed, statuses[1].State)
+	assert.Equal(t, "authentication failed", statuses[1].Error)
+	assert.Equal(t, StateIdle, statuses[2].State)
+}
+
+func TestStatusRecordsStateChanges(t *testing.T) {
+	m, err := New("token", Config{Count: 2, IDs: []int{1}})
+	require.NoError(t, err)
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+
+	sh := m.shards[0]
+	m.setState(sh, StateDisconnected, nil)
+	clock = clock.Add(time.Minute)
+	m.setState(sh, StateConnected, nil)
+
+	st := m.Status()[0]
+	assert.Equal(t, 1, st.ID)
+	assert.Equal(t, StateConnected, st.State)
+	assert.Equal(t, clock, st.Since)
+}
@@ -0,0 +1,128 @@
+package sitematrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type apiResponse struct {
+	SiteMatrix map[string]json.RawMessage `json:"sitematrix"`
+}
+
+type apiLanguage struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	LocalName string    `json:"localname"`
+	Site      []apiSite `json:"site"`
+}
+
+type apiSite struct {
+	URL      string `json:"url"`
+	Code     string `json:"code"`
+	SiteName string `json:"sitename"`
+	Closed   bool   `json:"closed"`
+	Private  bool   `json:"private"`
+	Fishbowl bool   `json:"fishbowl"`
+}
+
+// ParseAPI builds a Matrix from a saved response of
+// https://meta.wikimedia.org/w/api.php?action=sitematrix&format=json.
+// Closed wikis are skipped, as are languages without any open wiki. Public
+// special wikis are keyed by the first label of their host name, which is
+// what ingestion uses as the language of their events (commons, meta, www).
+func ParseAPI(r io.Reader) (*Matrix, error) {
+	var resp apiResponse
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.SiteMatrix == nil {
+		return nil, fmt.Errorf("sitematrix: response has no sitematrix object")
+	}
+
+	m := &Matrix{}
+	for key, raw := range resp.SiteMatrix {
+		if key == "count" {
+			continue
+		}
+		if key == "specials" {
+			specials, err := parseSpecials(raw)
+			if err != nil {
+				return nil, err
+			}
+			m.Languages = append(m.Languages, specials...)
+			continue
+		}
+		var l apiLanguage
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, fmt.Errorf("sitematrix: language %s: %w", key, err)
+		}
+
+		var projects []string
+		for _, site := range l.Site {
+			if !site.Closed {
+				projects = append(projects, site.Code)
+			}
+		}
+		if l.Code == "" || len(projects) == 0 {
+			continue
+		}
+		sort.Strings(projects)
+
+		m.Languages = append(m.Languages, Language{
+			Code: l.Code,
+			// The API's "name" is the autonym and "localname" the English name.
+			Name:      l.Name,
+			LocalName: l.LocalName,
+			Projects:  projects,
+		})
+	}
+
+	m.index()
+	return m, nil
+}
+
+func parseSpecials(raw json.RawMessage) ([]Language, error) {
+	var sites []apiSite
+	if err := json.Unmarshal(raw, &sites); err != nil {
+		return nil, fmt.Errorf("sitematrix: specials: %w", err)
+	}
+
+	byCode := make(map[string]*Language)
+	var order []string
+	for _, site := range sites {
+		if site.Closed || site.Private || site.Fishbowl {
+			continue
+		}
+		u, err := url.Parse(site.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		code := strings.SplitN(u.Hostname(), ".", 2)[0]
+
+		l, ok := byCode[code]
+		if !ok {
+			l = &Language{Code: code}
+			byCode[code] = l
+			order = append(order, code)
+		}
+		l.Projects = append(l.Projects, site.Code)
+		names := []string{site.SiteName}
+		if l.LocalName != "" {
+			names = append([]string{l.LocalName}, names...)
+		}
+		l.LocalName = strings.Join(names, ", ")
+		l.Name = l.LocalName
+	}
+
+	out := make([]Language, 0, len(order))
+	for _, code := range order {
+		l := byCode[code]
+		sort.Strings(l.Projects)
+		out = append(out, *l)
+	}
+	return out, nil
+}
@@ -0,0 +1,192 @@
+// Package sitematrix holds an embedded snapshot of the Wikimedia site matrix
+// so language codes can be validated without calling the API at runtime.
+// The snapshot is rebuilt with cmd/sitematrix from a saved API response.
+package sitematrix
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//go:embed sitematrix.json
+var snapshot []byte
+
+type Language struct {
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	LocalName string   `json:"localname"`
+	Projects  []string `json:"projects"`
+}
+
+type Matrix struct {
+	Languages []Language `json:"languages"`
+
+	byCode map[string]int
+}
+
+var defaultMatrix = mustLoad(snapshot)
+
+// Default returns the embedded snapshot.
+func Default() *Matrix {
+	return defaultMatrix
+}
+
+// Load decodes a snapshot written by Write.
+func Load(data []byte) (*Matrix, error) {
+	var m Matrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m.index()
+	return &m, nil
+}
+
+func mustLoad(data []byte) *Matrix {
+	m, err := Load(data)
+	if err != nil {
+		panic(fmt.Sprintf("sitematrix: invalid embedded snapshot: %v", err))
+	}
+	return m
+}
+
+func (m *Matrix) index() {
+	sort.Slice(m.Languages, func(i, j int) bool {
+		return m.Languages[i].Code < m.Languages[j].Code
+	})
+	m.byCode = make(map[string]int, len(m.Languages))
+	for i, l := range m.Languages {
+		m.byCode[l.Code] = i
+	}
+}
+
+// Write encodes the matrix in the snapshot format.
+func (m *Matrix) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(m)
+}
+
+// Normalize lower-cases and trims a user supplied code.
+func Normalize(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// Lookup returns the language for code.
+func (m *Matrix) Lookup(code string) (Language, bool) {
+	i, ok := m.byCode[Normalize(code)]
+	if !ok {
+		return Language{}, false
+	}
+	return m.Languages[i], true
+}
+
+// Valid reports whether code is a known language code.
+func (m *Matrix) Valid(code string) bool {
+	_, ok := m.Lookup(code)
+	return ok
+}
+
+// Codes returns all known codes in sorted order.
+func (m *Matrix) Codes() []string {
+	codes := make([]string, len(m.Languages))
+	for i, l := range m.Languages {
+		codes[i] = l.Code
+	}
+	return codes
+}
+
+// Suggest returns up to n codes that are closest to input. Codes and
+// English names are both considered, so "german" suggests "de".
+func (m *Matrix) Suggest(input string, n int) []string {
+	input = Normalize(input)
+	if input == "" || n <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		code string
+		dist int
+		// extra is how much longer the matched name is than input, so
+		// "russ" suggests Russian before Russia Buriat.
+		extra int
+	}
+	var candidates []candidate
+	for _, l := range m.Languages {
+		c := candidate{code: l.Code, dist: levenshtein(input, l.Code)}
+		if name := strings.ToLower(l.LocalName); name != "" {
+			if name == input || strings.HasPrefix(name, input) {
+				c.dist, c.extra = 0, len(name)-len(input)
+			} else if d := levenshtein(input, name); d < c.dist {
+				c.dist = d
+			}
+		}
+		if strings.HasPrefix(l.Code, input) && c.dist > 1 {
+			c.dist = 1
+		}
+		if c.dist <= maxDistance(input) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		if candidates[i].extra != candidates[j].extra {
+			return candidates[i].extra < candidates[j].extra
+		}
+		return candidates[i].code < candidates[j].code
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	out := make([]string, len(candidates))
+	for i, c := range candidates {
+		out[i] = c.code
+	}
+	return out
+}
+
+func maxDistance(input string) int {
+	if len(input) <= 3 {
+		return 1
+	}
+	return 2
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,56 @@
+package sitematrix
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPI(t *testing.T) {
+	f, err := os.Open("testdata/api.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	m, err := ParseAPI(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"commons", "de", "en", "www"}, m.Codes())
+
+	de, ok := m.Lookup("DE")
+	require.True(t, ok)
+	assert.Equal(t, "German", de.LocalName)
+	assert.Equal(t, []string{"wiki"}, de.Projects, "closed wikis are skipped")
+
+	assert.False(t, m.Valid("aa"), "languages without open wikis are skipped")
+	assert.False(t, m.Valid("office"), "private wikis are skipped")
+
+	var buf bytes.Buffer
+	require.NoError(t, m.Write(&buf))
+	reloaded, err := Load(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, m.Languages, reloaded.Languages)
+}
+
+func TestDefaultSnapshot(t *testing.T) {
+	m := Default()
+	// Every open Wikipedia is in the snapshot, well over 300 of them.
+	assert.Greater(t, len(m.Codes()), 300)
+	for _, code := range []string{"en", "es", "ru", "commons", "zh-min-nan", "ace", "ady", "ang", "bcl", "ilo", "min", "nds-nl"} {
+		assert.True(t, m.Valid(code), code)
+	}
+	assert.False(t, m.Valid("banana"))
+	assert.False(t, m.Valid(""))
+}
+
+func TestSuggest(t *testing.T) {
+	m := Default()
+
+	assert.Contains(t, m.Suggest("eng", 3), "en")
+	assert.Equal(t, "de", m.Suggest("german", 3)[0])
+	assert.Equal(t, "ru", m.Suggest("russ", 3)[0])
+	assert.Len(t, m.Suggest("e", 5), 5)
+	assert.Empty(t, m.Suggest("qqqqqqqq", 3))
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type AlertStore struct {
+	db *sql.DB
+}
+
+// Save adds the alert, or updates the threshold of the channel's existing
+// alert of the same kind and target.
+func (s *AlertStore) Save(ctx context.Context, a *models.Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO alerts (guild_id, channel_id, created_by, kind, lang, target, threshold)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (channel_id, kind, lang, target) DO UPDATE
+	SET threshold = $7
+	RETURNING id, created_at;
+	`
+	return s.db.QueryRowContext(ctx, query, a.GuildID, a.ChannelID, a.CreatedBy, a.Kind, a.Lang, a.Target, a.Threshold).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (s *AlertStore) Delete(ctx context.Context, channelID string, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM alerts WHERE channel_id = $1 AND id = $2;`
+	res, err := s.db.ExecContext(ctx, query, channelID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *AlertStore) ListByChannel(ctx context.Context, channelID string) ([]*models.Alert, error) {
+	query := `
+	SELECT id, guild_id, channel_id, created_by, kind, lang, target, threshold, created_at
+	FROM alerts WHERE channel_id = $1
+	ORDER BY id;
+	`
+	return s.list(ctx, query, channelID)
+}
+
+func (s *AlertStore) ListAll(ctx context.Context) ([]*models.Alert, error) {
+	query := `
+	SELECT id, guild_id, channel_id, created_by, kind, lang, target, threshold, created_at
+	FROM alerts;
+	`
+	return s.list(ctx, query)
+}
+
+func (s *AlertStore) list(ctx context.Context, query string, args ...any) ([]*models.Alert, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*models.Alert
+	for rows.Next() {
+		var a models.Alert
+		err := rows.Scan(&a.ID, &a.GuildID, &a.ChannelID, &a.CreatedBy, &a.Kind, &a.Lang, &a.Target, &a.Threshold, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type DigestStore struct {
+	db *sql.DB
+}
+
+const digestColumns = `id, guild_id, channel_id, created_by, period, langs, hour, minute, next_run, created_at`
+
+// Save creates the channel's digest or replaces its schedule and languages.
+func (s *DigestStore) Save(ctx context.Context, d *models.Digest) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO digests (guild_id, channel_id, created_by, period, langs, hour, minute, next_run)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (channel_id) DO UPDATE
+	SET created_by = $3, period = $4, langs = $5, hour = $6, minute = $7, next_run = $8
+	RETURNING id, created_at;
+	`
+	return s.db.QueryRowContext(ctx, query,
+		d.GuildID, d.ChannelID, d.CreatedBy, d.Period, pq.Array(d.Langs), d.Hour, d.Minute, d.NextRun,
+	).Scan(&d.ID, &d.CreatedAt)
+}
+
+func (s *DigestStore) Get(ctx context.Context, channelID string) (*models.Digest, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT ` + digestColumns + ` FROM digests WHERE channel_id = $1;`
+	var d models.Digest
+	err := s.db.QueryRowContext(ctx, query, channelID).Scan(&d.ID, &d.GuildID, &d.ChannelID, &d.CreatedBy,
+		&d.Period, pq.Array(&d.Langs), &d.Hour, &d.Minute, &d.NextRun, &d.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *DigestStore) Delete(ctx context.Context, channelID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM digests WHERE channel_id = $1;`
+	res, err := s.db.ExecContext(ctx, query, channelID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Due returns the digests whose next run is at or before now, the longest
+// overdue first.
+func (s *DigestStore) Due(ctx context.Context, now time.Time) ([]*models.Digest, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT ` + digestColumns + ` FROM digests WHERE next_run <= $1 ORDER BY next_run, id;`
+	rows, err := s.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var digests []*models.Digest
+	for rows.Next() {
+		var d models.Digest
+		err := rows.Scan(&d.ID, &d.GuildID, &d.ChannelID, &d.CreatedBy,
+			&d.Period, pq.Array(&d.Langs), &d.Hour, &d.Minute, &d.NextRun, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, &d)
+	}
+	return digests, rows.Err()
+}
+
+// Claim moves the digest's next run from due to next and reports whether
+// it did. Only one of several instances finding the same digest due can
+// claim it, so a digest is posted once however many instances run.
+func (s *DigestStore) Claim(ctx context.Context, id int64, due, next time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE digests SET next_run = $3 WHERE id = $1 AND next_run = $2;`
+	res, err := s.db.ExecContext(ctx, query, id, due, next)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
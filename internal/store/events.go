@@ -4,14 +4,43 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/vlkhvnn/TestON/internal/models"
 )
 
+// EventRetention is how long events are kept after the newest 100 of their
+// language, so feeds can backfill the edits they missed over a restart.
+const EventRetention = 6 * time.Hour
+
 type EventStore struct {
 	db *sql.DB
 }
 
+// eventColumns are read by scanEvents, in order.
+const eventColumns = `event_id, title, username, comment, timestamp, wiki, server_name, length_old, length_new,
+	event_type, namespace, rev_old, rev_new, log_id, score`
+
+func scanEvents(rows *sql.Rows) ([]*models.RecentChangeEvent, error) {
+	var events []*models.RecentChangeEvent
+	for rows.Next() {
+		var e models.RecentChangeEvent
+		var eventID string
+
+		err := rows.Scan(&eventID, &e.Title, &e.User, &e.Comment, &e.Timestamp, &e.Wiki, &e.ServerName,
+			&e.Length.Old, &e.Length.New, &e.Type, &e.Namespace, &e.Revision.Old, &e.Revision.New, &e.LogID, &e.Score)
+		if err != nil {
+			return nil, err
+		}
+
+		e.ID = json.Number(eventID)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+// Add stores the event, or returns ErrConflict if it is stored already,
+// which happens when several processes ingest the stream.
 func (s *EventStore) Add(ctx context.Context, lang string, event *models.RecentChangeEvent) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -19,21 +48,32 @@ func (s *EventStore) Add(ctx context.Context, lang string, event *models.RecentC
 	eventID := event.ID.String()
 
 	query := `
-	INSERT INTO events (event_id, lang, title, username, comment, timestamp, wiki, server_name)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	INSERT INTO events (event_id, lang, title, username, comment, timestamp, wiki, server_name, length_old, length_new,
+		event_type, namespace, rev_old, rev_new, log_id, score)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (wiki, event_id) DO NOTHING;
 	`
-	_, err := s.db.ExecContext(ctx, query, eventID, lang, event.Title, event.User, event.Comment, event.Timestamp, event.Wiki, event.ServerName)
+	res, err := s.db.ExecContext(ctx, query, eventID, lang, event.Title, event.User, event.Comment, event.Timestamp, event.Wiki, event.ServerName,
+		event.Length.Old, event.Length.New, event.Type, event.Namespace, event.Revision.Old, event.Revision.New, event.LogID, event.Score)
 	if err != nil {
 		return err
 	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
 
 	cleanupQuery := `
-	DELETE FROM events WHERE event_id IN (
+	DELETE FROM events WHERE lang = $1 AND timestamp < $2 AND event_id NOT IN (
 		SELECT event_id FROM events WHERE lang = $1
-		ORDER BY timestamp DESC OFFSET 100
+		ORDER BY timestamp DESC LIMIT 100
 	);
 	`
-	_, err = s.db.ExecContext(ctx, cleanupQuery, lang)
+	cutoff := event.Timestamp - int64(EventRetention/time.Second)
+	_, err = s.db.ExecContext(ctx, cleanupQuery, lang, cutoff)
 
 	return err
 }
@@ -43,7 +83,7 @@ func (s *EventStore) GetRecent(ctx context.Context, lang string, limit int) ([]*
 	defer cancel()
 
 	query := `
-	SELECT event_id, title, username, comment, timestamp, wiki, server_name
+	SELECT ` + eventColumns + `
 	FROM events WHERE lang = $1
 	ORDER BY timestamp DESC
 	LIMIT $2;
@@ -54,18 +94,9 @@ func (s *EventStore) GetRecent(ctx context.Context, lang string, limit int) ([]*
 	}
 	defer rows.Close()
 
-	var events []*models.RecentChangeEvent
-	for rows.Next() {
-		var e models.RecentChangeEvent
-		var eventID string
-
-		err := rows.Scan(&eventID, &e.Title, &e.User, &e.Comment, &e.Timestamp, &e.Wiki, &e.ServerName)
-		if err != nil {
-			return nil, err
-		}
-
-		e.ID = json.Number(eventID)
-		events = append(events, &e)
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(events) == 0 {
@@ -74,3 +105,55 @@ func (s *EventStore) GetRecent(ctx context.Context, lang string, limit int) ([]*
 
 	return events, nil
 }
+
+// GetSince returns up to limit events for lang at or after timestamp, oldest
+// first.
+func (s *EventStore) GetSince(ctx context.Context, lang string, timestamp int64, limit int) ([]*models.RecentChangeEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT ` + eventColumns + `
+	FROM events WHERE lang = $1 AND timestamp >= $2
+	ORDER BY timestamp ASC, id ASC
+	LIMIT $3;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, timestamp, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetPage returns up to limit events for lang, newest first, skipping the
+// newest offset events.
+func (s *EventStore) GetPage(ctx context.Context, lang string, offset, limit int) ([]*models.RecentChangeEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT ` + eventColumns + `
+	FROM events WHERE lang = $1
+	ORDER BY timestamp DESC, id DESC
+	OFFSET $2 LIMIT $3;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+func (s *EventStore) Count(ctx context.Context, lang string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM events WHERE lang = $1;`
+	err := s.db.QueryRowContext(ctx, query, lang).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type FeedStore struct {
+	db *sql.DB
+}
+
+// Save creates the channel's feed or replaces its language and filters.
+// The cursor of an existing feed is kept.
+func (s *FeedStore) Save(ctx context.Context, f *models.Feed) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO feeds (guild_id, channel_id, created_by, lang, filters, cursor_timestamp, cursor_event_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (channel_id) DO UPDATE
+	SET created_by = $3, lang = $4, filters = $5,
+		cursor_timestamp = CASE WHEN feeds.lang = $4 THEN feeds.cursor_timestamp ELSE $6 END,
+		cursor_event_id = CASE WHEN feeds.lang = $4 THEN feeds.cursor_event_id ELSE $7 END
+	RETURNING id, cursor_timestamp, cursor_event_id, created_at;
+	`
+	return s.db.QueryRowContext(ctx, query,
+		f.GuildID, f.ChannelID, f.CreatedBy, f.Lang, f.Filters, f.CursorTimestamp, f.CursorEventID,
+	).Scan(&f.ID, &f.CursorTimestamp, &f.CursorEventID, &f.CreatedAt)
+}
+
+func (s *FeedStore) Delete(ctx context.Context, channelID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM feeds WHERE channel_id = $1;`
+	res, err := s.db.ExecContext(ctx, query, channelID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *FeedStore) ListAll(ctx context.Context) ([]*models.Feed, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT id, guild_id, channel_id, created_by, lang, filters, cursor_timestamp, cursor_event_id, created_at
+	FROM feeds;
+	`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []*models.Feed
+	for rows.Next() {
+		var f models.Feed
+		err := rows.Scan(&f.ID, &f.GuildID, &f.ChannelID, &f.CreatedBy, &f.Lang, &f.Filters,
+			&f.CursorTimestamp, &f.CursorEventID, &f.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, &f)
+	}
+	return feeds, rows.Err()
+}
+
+func (s *FeedStore) UpdateCursor(ctx context.Context, channelID string, timestamp int64, eventID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	UPDATE feeds SET cursor_timestamp = $2, cursor_event_id = $3
+	WHERE channel_id = $1;
+	`
+	_, err := s.db.ExecContext(ctx, query, channelID, timestamp, eventID)
+	return err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type JobStore struct {
+	db *sql.DB
+}
+
+// SaveRun records the job's last run, replacing the one before.
+func (s *JobStore) SaveRun(ctx context.Context, run *models.JobRun) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO job_runs (name, last_run, duration_ms, error)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (name) DO UPDATE
+	SET last_run = $2, duration_ms = $3, error = $4;
+	`
+	_, err := s.db.ExecContext(ctx, query, run.Name, run.LastRun, run.Duration.Milliseconds(), run.Error)
+	return err
+}
+
+func (s *JobStore) ListRuns(ctx context.Context) ([]*models.JobRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT name, last_run, duration_ms, error FROM job_runs ORDER BY name;`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		var ms int64
+		if err := rows.Scan(&run.Name, &run.LastRun, &ms, &run.Error); err != nil {
+			return nil, err
+		}
+		run.Duration = time.Duration(ms) * time.Millisecond
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -37,3 +37,50 @@ func (s *LangStore) GetUserLang(ctx context.Context, userID string) (string, err
 
 	return lang, nil
 }
+
+func (s *LangStore) DeleteUserLang(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_languages WHERE user_id = $1;`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (s *LangStore) SetChannelLang(ctx context.Context, guildID, channelID, lang string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO channel_languages (channel_id, guild_id, lang)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (channel_id) DO UPDATE SET lang = $3;
+	`
+	_, err := s.db.ExecContext(ctx, query, channelID, guildID, lang)
+	return err
+}
+
+func (s *LangStore) GetChannelLang(ctx context.Context, channelID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT lang FROM channel_languages WHERE channel_id = $1;`
+	var lang string
+	err := s.db.QueryRowContext(ctx, query, channelID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return lang, nil
+}
+
+func (s *LangStore) DeleteChannelLang(ctx context.Context, channelID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM channel_languages WHERE channel_id = $1;`
+	_, err := s.db.ExecContext(ctx, query, channelID)
+	return err
+}
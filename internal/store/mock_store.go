@@ -2,6 +2,10 @@ package store
 
 import (
 	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/vlkhvnn/TestON/internal/models"
 )
@@ -11,6 +15,11 @@ type MockEventStore struct {
 }
 
 func (m *MockEventStore) Add(ctx context.Context, lang string, event *models.RecentChangeEvent) error {
+	for _, e := range m.RecentEvents {
+		if e.ID != "" && e.ID == event.ID && e.Wiki == event.Wiki {
+			return ErrConflict
+		}
+	}
 	m.RecentEvents = append(m.RecentEvents, event)
 	return nil
 }
@@ -25,8 +34,37 @@ func (m *MockEventStore) GetRecent(ctx context.Context, lang string, limit int)
 	return m.RecentEvents[:limit], nil
 }
 
+func (m *MockEventStore) GetSince(ctx context.Context, lang string, timestamp int64, limit int) ([]*models.RecentChangeEvent, error) {
+	var events []*models.RecentChangeEvent
+	for _, e := range m.RecentEvents {
+		if e.Timestamp >= timestamp {
+			events = append(events, e)
+		}
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (m *MockEventStore) GetPage(ctx context.Context, lang string, offset, limit int) ([]*models.RecentChangeEvent, error) {
+	if offset >= len(m.RecentEvents) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(m.RecentEvents) {
+		end = len(m.RecentEvents)
+	}
+	return m.RecentEvents[offset:end], nil
+}
+
+func (m *MockEventStore) Count(ctx context.Context, lang string) (int, error) {
+	return len(m.RecentEvents), nil
+}
+
 type MockLangStore struct {
-	Langs map[string]string
+	Langs        map[string]string
+	ChannelLangs map[string]string
 }
 
 func (m *MockLangStore) SetUserLang(ctx context.Context, userID, lang string) error {
@@ -45,8 +83,42 @@ func (m *MockLangStore) GetUserLang(ctx context.Context, userID string) (string,
 	return lang, nil
 }
 
+func (m *MockLangStore) DeleteUserLang(ctx context.Context, userID string) error {
+	delete(m.Langs, userID)
+	return nil
+}
+
+func (m *MockLangStore) SetChannelLang(ctx context.Context, guildID, channelID, lang string) error {
+	if m.ChannelLangs == nil {
+		m.ChannelLangs = make(map[string]string)
+	}
+	m.ChannelLangs[channelID] = lang
+	return nil
+}
+
+func (m *MockLangStore) GetChannelLang(ctx context.Context, channelID string) (string, error) {
+	lang, ok := m.ChannelLangs[channelID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return lang, nil
+}
+
+func (m *MockLangStore) DeleteChannelLang(ctx context.Context, channelID string) error {
+	delete(m.ChannelLangs, channelID)
+	return nil
+}
+
 type MockStatStore struct {
 	Stats map[string]int
+	// Articles holds per-article counts keyed by "lang_date_title".
+	Articles map[string]int
+	// Hours holds hourly counts keyed by language, then by hour in UTC.
+	Hours map[string]map[time.Time]int
+	// Editors holds per-editor counts keyed by "lang_date_user".
+	Editors map[string]int
+	// Created holds the new pages keyed by "lang_date", oldest first.
+	Created map[string][]models.NewPage
 }
 
 func (m *MockStatStore) IncrementByLang(ctx context.Context, lang string, date string) error {
@@ -69,3 +141,519 @@ func (m *MockStatStore) Get(ctx context.Context, lang string, date string) (int,
 	}
 	return count, nil
 }
+
+func (m *MockStatStore) GetRange(ctx context.Context, lang string, from, to string) ([]models.DailyCount, error) {
+	var counts []models.DailyCount
+	for key, count := range m.Stats {
+		keyLang, date, ok := strings.Cut(key, "_")
+		if ok && keyLang == lang && date >= from && date <= to {
+			counts = append(counts, models.DailyCount{Date: date, Count: count})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Date < counts[j].Date })
+	return counts, nil
+}
+
+func (m *MockStatStore) IncrementHour(ctx context.Context, lang string, hour time.Time) error {
+	if m.Hours == nil {
+		m.Hours = make(map[string]map[time.Time]int)
+	}
+	if m.Hours[lang] == nil {
+		m.Hours[lang] = make(map[time.Time]int)
+	}
+	m.Hours[lang][hour.UTC()]++
+	return nil
+}
+
+func (m *MockStatStore) GetHours(ctx context.Context, lang string, from, to time.Time) ([]models.HourlyCount, error) {
+	var counts []models.HourlyCount
+	for hour, count := range m.Hours[lang] {
+		if !hour.Before(from) && hour.Before(to) {
+			counts = append(counts, models.HourlyCount{Hour: hour, Count: count})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Hour.Before(counts[j].Hour) })
+	return counts, nil
+}
+
+func (m *MockStatStore) IncrementArticle(ctx context.Context, lang, date, title string) error {
+	if m.Articles == nil {
+		m.Articles = make(map[string]int)
+	}
+	m.Articles[lang+"_"+date+"_"+title]++
+	return nil
+}
+
+func (m *MockStatStore) TopArticles(ctx context.Context, lang string, from, to string, limit int) ([]models.ArticleCount, error) {
+	totals := make(map[string]int)
+	for key, count := range m.Articles {
+		parts := strings.SplitN(key, "_", 3)
+		if len(parts) == 3 && parts[0] == lang && parts[1] >= from && parts[1] <= to {
+			totals[parts[2]] += count
+		}
+	}
+	articles := make([]models.ArticleCount, 0, len(totals))
+	for title, count := range totals {
+		articles = append(articles, models.ArticleCount{Title: title, Count: count})
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if articles[i].Count != articles[j].Count {
+			return articles[i].Count > articles[j].Count
+		}
+		return articles[i].Title < articles[j].Title
+	})
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+func (m *MockStatStore) IncrementEditor(ctx context.Context, lang, date, user string) error {
+	if m.Editors == nil {
+		m.Editors = make(map[string]int)
+	}
+	m.Editors[lang+"_"+date+"_"+user]++
+	return nil
+}
+
+func (m *MockStatStore) TopEditors(ctx context.Context, lang string, from, to string, limit int) ([]models.EditorCount, error) {
+	totals := make(map[string]int)
+	for key, count := range m.Editors {
+		parts := strings.SplitN(key, "_", 3)
+		if len(parts) == 3 && parts[0] == lang && parts[1] >= from && parts[1] <= to {
+			totals[parts[2]] += count
+		}
+	}
+	editors := make([]models.EditorCount, 0, len(totals))
+	for user, count := range totals {
+		editors = append(editors, models.EditorCount{User: user, Count: count})
+	}
+	sort.Slice(editors, func(i, j int) bool {
+		if editors[i].Count != editors[j].Count {
+			return editors[i].Count > editors[j].Count
+		}
+		return editors[i].User < editors[j].User
+	})
+	if len(editors) > limit {
+		editors = editors[:limit]
+	}
+	return editors, nil
+}
+
+func (m *MockStatStore) AddNewPage(ctx context.Context, lang, date, title, user string) error {
+	if m.Created == nil {
+		m.Created = make(map[string][]models.NewPage)
+	}
+	key := lang + "_" + date
+	for _, p := range m.Created[key] {
+		if p.Title == title {
+			return nil
+		}
+	}
+	m.Created[key] = append(m.Created[key], models.NewPage{Title: title, User: user})
+	return nil
+}
+
+func (m *MockStatStore) NewPages(ctx context.Context, lang string, from, to string, limit int) ([]models.NewPage, int, error) {
+	var dates []string
+	for key := range m.Created {
+		keyLang, date, ok := strings.Cut(key, "_")
+		if ok && keyLang == lang && date >= from && date <= to {
+			dates = append(dates, date)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	var pages []models.NewPage
+	total := 0
+	for _, date := range dates {
+		created := m.Created[lang+"_"+date]
+		total += len(created)
+		for i := len(created) - 1; i >= 0; i-- {
+			if len(pages) < limit {
+				pages = append(pages, created[i])
+			}
+		}
+	}
+	return pages, total, nil
+}
+
+// Prune deletes everything older than before at once; limit is ignored.
+func (m *MockStatStore) Prune(ctx context.Context, before time.Time, limit int) (int64, error) {
+	date := before.UTC().Format("2006-01-02")
+	var deleted int64
+	for _, counts := range []map[string]int{m.Articles, m.Editors} {
+		for key := range counts {
+			if parts := strings.SplitN(key, "_", 3); len(parts) == 3 && parts[1] < date {
+				delete(counts, key)
+				deleted++
+			}
+		}
+	}
+	for key, pages := range m.Created {
+		if _, keyDate, _ := strings.Cut(key, "_"); keyDate < date {
+			delete(m.Created, key)
+			deleted += int64(len(pages))
+		}
+	}
+	for _, hours := range m.Hours {
+		for hour := range hours {
+			if hour.Before(before) {
+				delete(hours, hour)
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+type MockSettingsStore struct {
+	mu       sync.Mutex
+	Settings map[string]*models.GuildSettings
+}
+
+func (m *MockSettingsStore) Get(ctx context.Context, guildID string) (*models.GuildSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	gs, ok := m.Settings[guildID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *gs
+	return &cp, nil
+}
+
+func (m *MockSettingsStore) Save(ctx context.Context, gs *models.GuildSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Settings == nil {
+		m.Settings = make(map[string]*models.GuildSettings)
+	}
+	cp := *gs
+	m.Settings[gs.GuildID] = &cp
+	return nil
+}
+
+func (m *MockSettingsStore) Delete(ctx context.Context, guildID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Settings, guildID)
+	return nil
+}
+
+type MockUserSettingsStore struct {
+	mu       sync.Mutex
+	Settings map[string]*models.UserSettings
+}
+
+func (m *MockUserSettingsStore) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	us, ok := m.Settings[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *us
+	return &cp, nil
+}
+
+func (m *MockUserSettingsStore) Save(ctx context.Context, us *models.UserSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Settings == nil {
+		m.Settings = make(map[string]*models.UserSettings)
+	}
+	cp := *us
+	m.Settings[us.UserID] = &cp
+	return nil
+}
+
+func (m *MockUserSettingsStore) Delete(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Settings, userID)
+	return nil
+}
+
+type MockWatchStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	Watches []*models.Watch
+}
+
+func (m *MockWatchStore) Add(ctx context.Context, w *models.Watch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.Watches {
+		if existing.ChannelID == w.ChannelID && existing.Kind == w.Kind && existing.Lang == w.Lang && existing.Target == w.Target {
+			return ErrConflict
+		}
+	}
+	m.nextID++
+	w.ID = m.nextID
+	w.CreatedAt = time.Now()
+	cp := *w
+	m.Watches = append(m.Watches, &cp)
+	return nil
+}
+
+func (m *MockWatchStore) Delete(ctx context.Context, channelID, kind, lang, target string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, w := range m.Watches {
+		if w.ChannelID == channelID && w.Kind == kind && w.Lang == lang && w.Target == target {
+			m.Watches = append(m.Watches[:i], m.Watches[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MockWatchStore) ListByChannel(ctx context.Context, channelID string) ([]*models.Watch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*models.Watch
+	for _, w := range m.Watches {
+		if w.ChannelID == channelID {
+			cp := *w
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *MockWatchStore) ListAll(ctx context.Context) ([]*models.Watch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*models.Watch, 0, len(m.Watches))
+	for _, w := range m.Watches {
+		cp := *w
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+type MockAlertStore struct {
+	mu     sync.Mutex
+	nextID int64
+	Alerts []*models.Alert
+}
+
+func (m *MockAlertStore) Save(ctx context.Context, a *models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.Alerts {
+		if existing.ChannelID == a.ChannelID && existing.Kind == a.Kind && existing.Lang == a.Lang && existing.Target == a.Target {
+			existing.Threshold = a.Threshold
+			a.ID = existing.ID
+			a.CreatedAt = existing.CreatedAt
+			return nil
+		}
+	}
+	m.nextID++
+	a.ID = m.nextID
+	a.CreatedAt = time.Now()
+	cp := *a
+	m.Alerts = append(m.Alerts, &cp)
+	return nil
+}
+
+func (m *MockAlertStore) Delete(ctx context.Context, channelID string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, a := range m.Alerts {
+		if a.ChannelID == channelID && a.ID == id {
+			m.Alerts = append(m.Alerts[:i], m.Alerts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MockAlertStore) ListByChannel(ctx context.Context, channelID string) ([]*models.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*models.Alert
+	for _, a := range m.Alerts {
+		if a.ChannelID == channelID {
+			cp := *a
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *MockAlertStore) ListAll(ctx context.Context) ([]*models.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*models.Alert, 0, len(m.Alerts))
+	for _, a := range m.Alerts {
+		cp := *a
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+type MockFeedStore struct {
+	mu     sync.Mutex
+	nextID int64
+	Feeds  map[string]*models.Feed
+}
+
+func (m *MockFeedStore) Save(ctx context.Context, f *models.Feed) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Feeds == nil {
+		m.Feeds = make(map[string]*models.Feed)
+	}
+	if existing, ok := m.Feeds[f.ChannelID]; ok {
+		f.ID = existing.ID
+		if existing.Lang == f.Lang {
+			f.CursorTimestamp = existing.CursorTimestamp
+			f.CursorEventID = existing.CursorEventID
+		}
+		f.CreatedAt = existing.CreatedAt
+	} else {
+		m.nextID++
+		f.ID = m.nextID
+		f.CreatedAt = time.Now()
+	}
+	cp := *f
+	m.Feeds[f.ChannelID] = &cp
+	return nil
+}
+
+func (m *MockFeedStore) Delete(ctx context.Context, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Feeds[channelID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.Feeds, channelID)
+	return nil
+}
+
+func (m *MockFeedStore) ListAll(ctx context.Context) ([]*models.Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*models.Feed, 0, len(m.Feeds))
+	for _, f := range m.Feeds {
+		cp := *f
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (m *MockFeedStore) UpdateCursor(ctx context.Context, channelID string, timestamp int64, eventID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.Feeds[channelID]; ok {
+		f.CursorTimestamp = timestamp
+		f.CursorEventID = eventID
+	}
+	return nil
+}
+
+type MockDigestStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	Digests map[string]*models.Digest
+}
+
+func (m *MockDigestStore) Save(ctx context.Context, d *models.Digest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Digests == nil {
+		m.Digests = make(map[string]*models.Digest)
+	}
+	if existing, ok := m.Digests[d.ChannelID]; ok {
+		d.ID = existing.ID
+		d.CreatedAt = existing.CreatedAt
+	} else {
+		m.nextID++
+		d.ID = m.nextID
+		d.CreatedAt = time.Now()
+	}
+	cp := *d
+	m.Digests[d.ChannelID] = &cp
+	return nil
+}
+
+func (m *MockDigestStore) Get(ctx context.Context, channelID string) (*models.Digest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.Digests[channelID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (m *MockDigestStore) Delete(ctx context.Context, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Digests[channelID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.Digests, channelID)
+	return nil
+}
+
+func (m *MockDigestStore) Due(ctx context.Context, now time.Time) ([]*models.Digest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*models.Digest
+	for _, d := range m.Digests {
+		if !d.NextRun.After(now) {
+			cp := *d
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].NextRun.Equal(out[j].NextRun) {
+			return out[i].NextRun.Before(out[j].NextRun)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (m *MockDigestStore) Claim(ctx context.Context, id int64, due, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.Digests {
+		if d.ID == id && d.NextRun.Equal(due) {
+			d.NextRun = next
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type MockJobStore struct {
+	mu   sync.Mutex
+	Runs map[string]*models.JobRun
+}
+
+func (m *MockJobStore) SaveRun(ctx context.Context, run *models.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Runs == nil {
+		m.Runs = make(map[string]*models.JobRun)
+	}
+	cp := *run
+	m.Runs[run.Name] = &cp
+	return nil
+}
+
+func (m *MockJobStore) ListRuns(ctx context.Context) ([]*models.JobRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []*models.JobRun
+	for _, run := range m.Runs {
+		cp := *run
+		runs = append(runs, &cp)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Name < runs[j].Name })
+	return runs, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type SettingsStore struct {
+	db *sql.DB
+}
+
+func (s *SettingsStore) Get(ctx context.Context, guildID string) (*models.GuildSettings, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT guild_id, lang, prefix, timezone, local_stats_days, feed_channels, disabled_commands, aliases, role_grants
+	FROM guild_settings WHERE guild_id = $1;
+	`
+	var gs models.GuildSettings
+	var aliases, grants []byte
+	err := s.db.QueryRowContext(ctx, query, guildID).Scan(
+		&gs.GuildID,
+		&gs.Lang,
+		&gs.Prefix,
+		&gs.Timezone,
+		&gs.LocalStatsDays,
+		pq.Array(&gs.FeedChannels),
+		pq.Array(&gs.DisabledCommands),
+		&aliases,
+		&grants,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(aliases, &gs.Aliases); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(grants, &gs.RoleGrants); err != nil {
+		return nil, err
+	}
+
+	return &gs, nil
+}
+
+func (s *SettingsStore) Save(ctx context.Context, gs *models.GuildSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO guild_settings (guild_id, lang, prefix, timezone, local_stats_days, feed_channels, disabled_commands, aliases, role_grants, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+	ON CONFLICT (guild_id) DO UPDATE
+	SET lang = $2, prefix = $3, timezone = $4, local_stats_days = $5, feed_channels = $6, disabled_commands = $7, aliases = $8, role_grants = $9, updated_at = NOW();
+	`
+	aliases := gs.Aliases
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	aliasesJSON, err := json.Marshal(aliases)
+	if err != nil {
+		return err
+	}
+	grants := gs.RoleGrants
+	if grants == nil {
+		grants = map[string][]string{}
+	}
+	grantsJSON, err := json.Marshal(grants)
+	if err != nil {
+		return err
+	}
+	// A nil slice would be written as NULL, which the columns do not allow.
+	feedChannels := gs.FeedChannels
+	if feedChannels == nil {
+		feedChannels = []string{}
+	}
+	disabled := gs.DisabledCommands
+	if disabled == nil {
+		disabled = []string{}
+	}
+
+	_, err = s.db.ExecContext(ctx, query,
+		gs.GuildID,
+		gs.Lang,
+		gs.Prefix,
+		gs.Timezone,
+		gs.LocalStatsDays,
+		pq.Array(feedChannels),
+		pq.Array(disabled),
+		aliasesJSON,
+		grantsJSON,
+	)
+	return err
+}
+
+func (s *SettingsStore) Delete(ctx context.Context, guildID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM guild_settings WHERE guild_id = $1;`
+	_, err := s.db.ExecContext(ctx, query, guildID)
+	return err
+}
@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/vlkhvnn/TestON/internal/models"
 )
 
 type StatStore struct {
@@ -38,3 +41,253 @@ func (s *StatStore) Get(ctx context.Context, lang string, date string) (int, err
 
 	return count, nil
 }
+
+// GetRange returns the daily counts for lang between from and to inclusive,
+// oldest first. Days without changes are left out.
+func (s *StatStore) GetRange(ctx context.Context, lang string, from, to string) ([]models.DailyCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT to_char(date, 'YYYY-MM-DD'), count FROM stats
+	WHERE lang = $1 AND date BETWEEN $2 AND $3
+	ORDER BY date;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.DailyCount
+	for rows.Next() {
+		var c models.DailyCount
+		if err := rows.Scan(&c.Date, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// IncrementHour counts one change in the hour starting at hour, which must
+// be truncated to the hour. Hourly counts let stats be summed over the days
+// of any timezone.
+func (s *StatStore) IncrementHour(ctx context.Context, lang string, hour time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO stats_hourly (lang, hour, count)
+	VALUES ($1, $2, 1)
+	ON CONFLICT (lang, hour) DO UPDATE
+	SET count = stats_hourly.count + 1;
+	`
+	_, err := s.db.ExecContext(ctx, query, lang, hour.UTC())
+	return err
+}
+
+// GetHours returns the hourly counts for lang from from up to but not
+// including to, oldest first. Hours without changes are left out.
+func (s *StatStore) GetHours(ctx context.Context, lang string, from, to time.Time) ([]models.HourlyCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT hour, count FROM stats_hourly
+	WHERE lang = $1 AND hour >= $2 AND hour < $3
+	ORDER BY hour;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.HourlyCount
+	for rows.Next() {
+		var c models.HourlyCount
+		if err := rows.Scan(&c.Hour, &c.Count); err != nil {
+			return nil, err
+		}
+		c.Hour = c.Hour.UTC()
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (s *StatStore) IncrementArticle(ctx context.Context, lang, date, title string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO article_stats (lang, date, title, count)
+	VALUES ($1, $2, $3, 1)
+	ON CONFLICT (lang, date, title) DO UPDATE
+	SET count = article_stats.count + 1;
+	`
+	_, err := s.db.ExecContext(ctx, query, lang, date, title)
+	return err
+}
+
+// TopArticles returns the most changed articles for lang between from and
+// to inclusive, most changed first.
+func (s *StatStore) TopArticles(ctx context.Context, lang string, from, to string, limit int) ([]models.ArticleCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT title, SUM(count) AS total FROM article_stats
+	WHERE lang = $1 AND date BETWEEN $2 AND $3
+	GROUP BY title
+	ORDER BY total DESC, title
+	LIMIT $4;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var articles []models.ArticleCount
+	for rows.Next() {
+		var a models.ArticleCount
+		if err := rows.Scan(&a.Title, &a.Count); err != nil {
+			return nil, err
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
+
+func (s *StatStore) IncrementEditor(ctx context.Context, lang, date, user string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO editor_stats (lang, date, username, count)
+	VALUES ($1, $2, $3, 1)
+	ON CONFLICT (lang, date, username) DO UPDATE
+	SET count = editor_stats.count + 1;
+	`
+	_, err := s.db.ExecContext(ctx, query, lang, date, user)
+	return err
+}
+
+// TopEditors returns the editors with the most changes for lang between
+// from and to inclusive, most active first.
+func (s *StatStore) TopEditors(ctx context.Context, lang string, from, to string, limit int) ([]models.EditorCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	SELECT username, SUM(count) AS total FROM editor_stats
+	WHERE lang = $1 AND date BETWEEN $2 AND $3
+	GROUP BY username
+	ORDER BY total DESC, username
+	LIMIT $4;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var editors []models.EditorCount
+	for rows.Next() {
+		var e models.EditorCount
+		if err := rows.Scan(&e.User, &e.Count); err != nil {
+			return nil, err
+		}
+		editors = append(editors, e)
+	}
+	return editors, rows.Err()
+}
+
+// AddNewPage records a page created on date. A page recreated on the same
+// day is recorded once.
+func (s *StatStore) AddNewPage(ctx context.Context, lang, date, title, user string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO new_pages (lang, date, title, username)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (lang, date, title) DO NOTHING;
+	`
+	_, err := s.db.ExecContext(ctx, query, lang, date, title, user)
+	return err
+}
+
+// NewPages returns up to limit of the pages created on lang between from and
+// to inclusive, newest first, and how many were created in total.
+func (s *StatStore) NewPages(ctx context.Context, lang string, from, to string, limit int) ([]models.NewPage, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM new_pages WHERE lang = $1 AND date BETWEEN $2 AND $3;`
+	if err := s.db.QueryRowContext(ctx, countQuery, lang, from, to).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+	SELECT title, username FROM new_pages
+	WHERE lang = $1 AND date BETWEEN $2 AND $3
+	ORDER BY id DESC
+	LIMIT $4;
+	`
+	rows, err := s.db.QueryContext(ctx, query, lang, from, to, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var pages []models.NewPage
+	for rows.Next() {
+		var p models.NewPage
+		if err := rows.Scan(&p.Title, &p.User); err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, total, rows.Err()
+}
+
+// Prune deletes up to limit rows from each of the per-article, per-editor,
+// new page and hourly stats older than before, and returns how many it
+// deleted. Daily totals are kept. Call it until it returns 0 to delete all
+// of them without holding long locks.
+func (s *StatStore) Prune(ctx context.Context, before time.Time, limit int) (int64, error) {
+	date := before.UTC().Format("2006-01-02")
+	queries := []struct {
+		query string
+		arg   any
+	}{
+		{`DELETE FROM article_stats WHERE id IN (SELECT id FROM article_stats WHERE date < $1 LIMIT $2);`, date},
+		{`DELETE FROM editor_stats WHERE id IN (SELECT id FROM editor_stats WHERE date < $1 LIMIT $2);`, date},
+		{`DELETE FROM new_pages WHERE id IN (SELECT id FROM new_pages WHERE date < $1 LIMIT $2);`, date},
+		{`DELETE FROM stats_hourly WHERE ctid IN (SELECT ctid FROM stats_hourly WHERE hour < $1 LIMIT $2);`, before},
+	}
+
+	var deleted int64
+	for _, q := range queries {
+		n, err := s.prune(ctx, q.query, q.arg, limit)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func (s *StatStore) prune(ctx context.Context, query string, before any, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -11,6 +11,7 @@ import (
 
 var (
 	ErrNotFound          = errors.New("record not found")
+	ErrConflict          = errors.New("record already exists")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -18,21 +19,84 @@ type Storage struct {
 	Event interface {
 		Add(ctx context.Context, lang string, event *models.RecentChangeEvent) error
 		GetRecent(ctx context.Context, lang string, limit int) ([]*models.RecentChangeEvent, error)
+		GetSince(ctx context.Context, lang string, timestamp int64, limit int) ([]*models.RecentChangeEvent, error)
+		GetPage(ctx context.Context, lang string, offset, limit int) ([]*models.RecentChangeEvent, error)
+		Count(ctx context.Context, lang string) (int, error)
 	}
 	Stat interface {
 		IncrementByLang(ctx context.Context, lang string, date string) error
 		Get(ctx context.Context, lang string, date string) (int, error)
+		GetRange(ctx context.Context, lang string, from, to string) ([]models.DailyCount, error)
+		IncrementHour(ctx context.Context, lang string, hour time.Time) error
+		GetHours(ctx context.Context, lang string, from, to time.Time) ([]models.HourlyCount, error)
+		IncrementArticle(ctx context.Context, lang, date, title string) error
+		TopArticles(ctx context.Context, lang string, from, to string, limit int) ([]models.ArticleCount, error)
+		IncrementEditor(ctx context.Context, lang, date, user string) error
+		TopEditors(ctx context.Context, lang string, from, to string, limit int) ([]models.EditorCount, error)
+		AddNewPage(ctx context.Context, lang, date, title, user string) error
+		NewPages(ctx context.Context, lang string, from, to string, limit int) ([]models.NewPage, int, error)
+		Prune(ctx context.Context, before time.Time, limit int) (int64, error)
 	}
 	Lang interface {
 		SetUserLang(ctx context.Context, userID, lang string) error
 		GetUserLang(ctx context.Context, userID string) (string, error)
+		DeleteUserLang(ctx context.Context, userID string) error
+		SetChannelLang(ctx context.Context, guildID, channelID, lang string) error
+		GetChannelLang(ctx context.Context, channelID string) (string, error)
+		DeleteChannelLang(ctx context.Context, channelID string) error
+	}
+	Settings interface {
+		Get(ctx context.Context, guildID string) (*models.GuildSettings, error)
+		Save(ctx context.Context, gs *models.GuildSettings) error
+		Delete(ctx context.Context, guildID string) error
+	}
+	UserSettings interface {
+		Get(ctx context.Context, userID string) (*models.UserSettings, error)
+		Save(ctx context.Context, us *models.UserSettings) error
+		Delete(ctx context.Context, userID string) error
+	}
+	Watch interface {
+		Add(ctx context.Context, w *models.Watch) error
+		Delete(ctx context.Context, channelID, kind, lang, target string) error
+		ListByChannel(ctx context.Context, channelID string) ([]*models.Watch, error)
+		ListAll(ctx context.Context) ([]*models.Watch, error)
+	}
+	Alert interface {
+		Save(ctx context.Context, a *models.Alert) error
+		Delete(ctx context.Context, channelID string, id int64) error
+		ListByChannel(ctx context.Context, channelID string) ([]*models.Alert, error)
+		ListAll(ctx context.Context) ([]*models.Alert, error)
+	}
+	Feed interface {
+		Save(ctx context.Context, f *models.Feed) error
+		Delete(ctx context.Context, channelID string) error
+		ListAll(ctx context.Context) ([]*models.Feed, error)
+		UpdateCursor(ctx context.Context, channelID string, timestamp int64, eventID string) error
+	}
+	Digest interface {
+		Save(ctx context.Context, d *models.Digest) error
+		Get(ctx context.Context, channelID string) (*models.Digest, error)
+		Delete(ctx context.Context, channelID string) error
+		Due(ctx context.Context, now time.Time) ([]*models.Digest, error)
+		Claim(ctx context.Context, id int64, due, next time.Time) (bool, error)
+	}
+	Job interface {
+		SaveRun(ctx context.Context, run *models.JobRun) error
+		ListRuns(ctx context.Context) ([]*models.JobRun, error)
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Event: &EventStore{db: db},
-		Stat:  &StatStore{db: db},
-		Lang:  &LangStore{db: db},
+		Event:        &EventStore{db: db},
+		Stat:         &StatStore{db: db},
+		Lang:         &LangStore{db: db},
+		Settings:     &SettingsStore{db: db},
+		UserSettings: &UserSettingsStore{db: db},
+		Watch:        &WatchStore{db: db},
+		Feed:         &FeedStore{db: db},
+		Alert:        &AlertStore{db: db},
+		Digest:       &DigestStore{db: db},
+		Job:          &JobStore{db: db},
 	}
 }
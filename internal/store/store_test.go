@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -26,8 +28,17 @@ func initTestDB(t *testing.T, db *sql.DB) {
 		comment TEXT,
 		timestamp BIGINT NOT NULL,
 		wiki TEXT NOT NULL,
-		server_name TEXT NOT NULL
+		server_name TEXT NOT NULL,
+		length_old BIGINT NOT NULL DEFAULT 0,
+		length_new BIGINT NOT NULL DEFAULT 0,
+		event_type TEXT NOT NULL DEFAULT '',
+		namespace INT NOT NULL DEFAULT 0,
+		rev_old BIGINT NOT NULL DEFAULT 0,
+		rev_new BIGINT NOT NULL DEFAULT 0,
+		log_id BIGINT NOT NULL DEFAULT 0,
+		score DOUBLE PRECISION NOT NULL DEFAULT 0
 	);
+	CREATE UNIQUE INDEX IF NOT EXISTS events_wiki_event_id_idx ON events (wiki, event_id);
 	`
 	_, err := db.Exec(eventsTable)
 	require.NoError(t, err, "failed to create events table")
@@ -52,6 +63,171 @@ func initTestDB(t *testing.T, db *sql.DB) {
 	`
 	_, err = db.Exec(userLangTable)
 	require.NoError(t, err, "failed to create user_languages table")
+
+	channelLangTable := `
+	CREATE TABLE IF NOT EXISTS channel_languages (
+		channel_id TEXT PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		lang TEXT NOT NULL
+	);
+	`
+	_, err = db.Exec(channelLangTable)
+	require.NoError(t, err, "failed to create channel_languages table")
+
+	guildSettingsTable := `
+	CREATE TABLE IF NOT EXISTS guild_settings (
+		guild_id TEXT PRIMARY KEY,
+		lang TEXT NOT NULL DEFAULT '',
+		prefix TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		local_stats_days BOOLEAN NOT NULL DEFAULT FALSE,
+		feed_channels TEXT[] NOT NULL DEFAULT '{}',
+		disabled_commands TEXT[] NOT NULL DEFAULT '{}',
+		aliases JSONB NOT NULL DEFAULT '{}',
+		role_grants JSONB NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err = db.Exec(guildSettingsTable)
+	require.NoError(t, err, "failed to create guild_settings table")
+
+	watchesTable := `
+	CREATE TABLE IF NOT EXISTS watches (
+		id SERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		lang TEXT NOT NULL,
+		target TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		UNIQUE(channel_id, kind, lang, target)
+	);
+	`
+	_, err = db.Exec(watchesTable)
+	require.NoError(t, err, "failed to create watches table")
+
+	feedsTable := `
+	CREATE TABLE IF NOT EXISTS feeds (
+		id SERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL UNIQUE,
+		created_by TEXT NOT NULL,
+		lang TEXT NOT NULL,
+		filters TEXT NOT NULL DEFAULT '',
+		cursor_timestamp BIGINT NOT NULL DEFAULT 0,
+		cursor_event_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err = db.Exec(feedsTable)
+	require.NoError(t, err, "failed to create feeds table")
+
+	articleStatsTable := `
+	CREATE TABLE IF NOT EXISTS article_stats (
+		id SERIAL PRIMARY KEY,
+		lang TEXT NOT NULL,
+		date DATE NOT NULL,
+		title TEXT NOT NULL,
+		count INT NOT NULL DEFAULT 0,
+		UNIQUE(lang, date, title)
+	);
+	`
+	_, err = db.Exec(articleStatsTable)
+	require.NoError(t, err, "failed to create article_stats table")
+
+	statsHourlyTable := `
+	CREATE TABLE IF NOT EXISTS stats_hourly (
+		lang TEXT NOT NULL,
+		hour TIMESTAMP WITH TIME ZONE NOT NULL,
+		count INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (lang, hour)
+	);
+	`
+	_, err = db.Exec(statsHourlyTable)
+	require.NoError(t, err, "failed to create stats_hourly table")
+
+	userSettingsTable := `
+	CREATE TABLE IF NOT EXISTS user_settings (
+		user_id TEXT PRIMARY KEY,
+		timezone TEXT NOT NULL DEFAULT '',
+		local_stats_days BOOLEAN NOT NULL DEFAULT FALSE,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err = db.Exec(userSettingsTable)
+	require.NoError(t, err, "failed to create user_settings table")
+
+	alertsTable := `
+	CREATE TABLE IF NOT EXISTS alerts (
+		id SERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		lang TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		threshold DOUBLE PRECISION NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		UNIQUE(channel_id, kind, lang, target)
+	);
+	`
+	_, err = db.Exec(alertsTable)
+	require.NoError(t, err, "failed to create alerts table")
+
+	editorStatsTable := `
+	CREATE TABLE IF NOT EXISTS editor_stats (
+		id SERIAL PRIMARY KEY,
+		lang TEXT NOT NULL,
+		date DATE NOT NULL,
+		username TEXT NOT NULL,
+		count INT NOT NULL DEFAULT 0,
+		UNIQUE(lang, date, username)
+	);
+	`
+	_, err = db.Exec(editorStatsTable)
+	require.NoError(t, err, "failed to create editor_stats table")
+
+	newPagesTable := `
+	CREATE TABLE IF NOT EXISTS new_pages (
+		id SERIAL PRIMARY KEY,
+		lang TEXT NOT NULL,
+		date DATE NOT NULL,
+		title TEXT NOT NULL,
+		username TEXT NOT NULL,
+		UNIQUE(lang, date, title)
+	);
+	`
+	_, err = db.Exec(newPagesTable)
+	require.NoError(t, err, "failed to create new_pages table")
+
+	digestsTable := `
+	CREATE TABLE IF NOT EXISTS digests (
+		id SERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL UNIQUE,
+		created_by TEXT NOT NULL,
+		period TEXT NOT NULL,
+		langs TEXT[] NOT NULL,
+		hour INT NOT NULL,
+		minute INT NOT NULL,
+		next_run TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err = db.Exec(digestsTable)
+	require.NoError(t, err, "failed to create digests table")
+
+	jobRunsTable := `
+	CREATE TABLE IF NOT EXISTS job_runs (
+		name TEXT PRIMARY KEY,
+		last_run TIMESTAMP WITH TIME ZONE NOT NULL,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	`
+	_, err = db.Exec(jobRunsTable)
+	require.NoError(t, err, "failed to create job_runs table")
 }
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -64,6 +240,18 @@ func setupTestDB(t *testing.T) *sql.DB {
 		"TRUNCATE TABLE events RESTART IDENTITY CASCADE;",
 		"TRUNCATE TABLE stats RESTART IDENTITY CASCADE;",
 		"TRUNCATE TABLE user_languages RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE channel_languages RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE guild_settings RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE watches RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE feeds RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE article_stats RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE stats_hourly RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE user_settings RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE alerts RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE editor_stats RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE new_pages RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE digests RESTART IDENTITY CASCADE;",
+		"TRUNCATE TABLE job_runs RESTART IDENTITY CASCADE;",
 	}
 	for _, q := range cleanQueries {
 		_, err := db.Exec(q)
@@ -91,6 +279,10 @@ func TestEventStore_AddAndGetRecent(t *testing.T) {
 		Timestamp:  now,
 		Wiki:       "enwiki",
 		ServerName: "en.wikipedia.org",
+		Namespace:  4,
+		Length:     models.EventLength{Old: 100, New: 142},
+		Revision:   models.Revision{Old: 7, New: 9},
+		Score:      0.42,
 	}
 
 	err := eventStore.Add(ctx, "en", event)
@@ -100,6 +292,11 @@ func TestEventStore_AddAndGetRecent(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, events, 1)
 	assert.Equal(t, "Test Page", events[0].Title)
+	assert.Equal(t, int64(42), events[0].ByteDelta())
+	assert.Equal(t, "edit", events[0].Type)
+	assert.Equal(t, 4, events[0].Namespace)
+	assert.Equal(t, models.Revision{Old: 7, New: 9}, events[0].Revision)
+	assert.Equal(t, 0.42, events[0].Score)
 }
 
 func TestStatStore_IncrementAndGet(t *testing.T) {
@@ -122,6 +319,210 @@ func TestStatStore_IncrementAndGet(t *testing.T) {
 	assert.Equal(t, 2, count)
 }
 
+func TestStatStore_GetRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	statStore := &StatStore{db: db}
+	ctx := context.Background()
+
+	for _, date := range []string{"2025-02-01", "2025-02-03", "2025-02-03", "2025-02-05"} {
+		require.NoError(t, statStore.IncrementByLang(ctx, "en", date))
+	}
+	require.NoError(t, statStore.IncrementByLang(ctx, "de", "2025-02-02"))
+
+	counts, err := statStore.GetRange(ctx, "en", "2025-02-01", "2025-02-04")
+	require.NoError(t, err)
+	assert.Equal(t, []models.DailyCount{
+		{Date: "2025-02-01", Count: 1},
+		{Date: "2025-02-03", Count: 2},
+	}, counts)
+}
+
+func TestStatStore_Hours(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	statStore := &StatStore{db: db}
+	ctx := context.Background()
+
+	base := time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC)
+	for _, h := range []int{0, 1, 1, 23, 24} {
+		require.NoError(t, statStore.IncrementHour(ctx, "en", base.Add(time.Duration(h)*time.Hour)))
+	}
+	require.NoError(t, statStore.IncrementHour(ctx, "de", base))
+
+	counts, err := statStore.GetHours(ctx, "en", base.Add(time.Hour), base.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []models.HourlyCount{
+		{Hour: base.Add(time.Hour), Count: 2},
+		{Hour: base.Add(23 * time.Hour), Count: 1},
+	}, counts)
+}
+
+func TestStatStore_TopArticles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	statStore := &StatStore{db: db}
+	ctx := context.Background()
+
+	increments := []struct{ date, title string }{
+		{"2025-02-01", "Berlin"},
+		{"2025-02-02", "Berlin"},
+		{"2025-02-02", "Paris"},
+		{"2025-02-02", "Rome"},
+		{"2025-02-02", "Rome"},
+		{"2025-02-09", "Paris"},
+	}
+	for _, inc := range increments {
+		require.NoError(t, statStore.IncrementArticle(ctx, "en", inc.date, inc.title))
+	}
+
+	top, err := statStore.TopArticles(ctx, "en", "2025-02-01", "2025-02-07", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ArticleCount{
+		{Title: "Berlin", Count: 2},
+		{Title: "Rome", Count: 2},
+	}, top)
+}
+
+func TestStatStore_TopEditorsAndNewPages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	statStore := &StatStore{db: db}
+	ctx := context.Background()
+
+	for _, user := range []string{"Alice", "Bob", "Alice", "Carol", "Alice", "Bob"} {
+		require.NoError(t, statStore.IncrementEditor(ctx, "en", "2025-02-02", user))
+	}
+	require.NoError(t, statStore.IncrementEditor(ctx, "en", "2025-02-09", "Carol"))
+
+	top, err := statStore.TopEditors(ctx, "en", "2025-02-01", "2025-02-07", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []models.EditorCount{
+		{User: "Alice", Count: 3},
+		{User: "Bob", Count: 2},
+	}, top)
+
+	require.NoError(t, statStore.AddNewPage(ctx, "en", "2025-02-02", "First", "Alice"))
+	require.NoError(t, statStore.AddNewPage(ctx, "en", "2025-02-02", "Second", "Bob"))
+	require.NoError(t, statStore.AddNewPage(ctx, "en", "2025-02-02", "First", "Carol"))
+	require.NoError(t, statStore.AddNewPage(ctx, "en", "2025-02-03", "Third", "Alice"))
+
+	pages, total, err := statStore.NewPages(ctx, "en", "2025-02-01", "2025-02-02", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []models.NewPage{{Title: "Second", User: "Bob"}}, pages)
+}
+
+func TestStatStore_Prune(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	statStore := &StatStore{db: db}
+	ctx := context.Background()
+
+	for _, date := range []string{"2025-01-30", "2025-01-31", "2025-02-01"} {
+		require.NoError(t, statStore.IncrementByLang(ctx, "en", date))
+		require.NoError(t, statStore.IncrementArticle(ctx, "en", date, "Main Page"))
+		require.NoError(t, statStore.IncrementEditor(ctx, "en", date, "Alice"))
+		require.NoError(t, statStore.AddNewPage(ctx, "en", date, "New "+date, "Alice"))
+	}
+	before := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, statStore.IncrementHour(ctx, "en", before.Add(-time.Hour)))
+	require.NoError(t, statStore.IncrementHour(ctx, "en", before))
+
+	n, err := statStore.Prune(ctx, before, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n, "one row from each table")
+	n, err = statStore.Prune(ctx, before, 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	n, err = statStore.Prune(ctx, before, 100)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	articles, err := statStore.TopArticles(ctx, "en", "2025-01-01", "2025-02-28", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ArticleCount{{Title: "Main Page", Count: 1}}, articles)
+	hours, err := statStore.GetHours(ctx, "en", before.AddDate(0, 0, -1), before.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Len(t, hours, 1)
+	days, err := statStore.GetRange(ctx, "en", "2025-01-01", "2025-02-28")
+	require.NoError(t, err)
+	assert.Len(t, days, 3, "daily totals are kept")
+}
+
+func TestJobStore_SaveAndListRuns(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	jobStore := &JobStore{db: db}
+	ctx := context.Background()
+
+	at := time.Date(2025, 2, 5, 3, 30, 0, 0, time.UTC)
+	require.NoError(t, jobStore.SaveRun(ctx, &models.JobRun{Name: "prune_stats", LastRun: at, Duration: 1500 * time.Millisecond}))
+	require.NoError(t, jobStore.SaveRun(ctx, &models.JobRun{Name: "digests", LastRun: at, Error: "boom"}))
+	require.NoError(t, jobStore.SaveRun(ctx, &models.JobRun{Name: "prune_stats", LastRun: at.AddDate(0, 0, 1), Duration: 2 * time.Second}))
+
+	runs, err := jobStore.ListRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "digests", runs[0].Name)
+	assert.Equal(t, "boom", runs[0].Error)
+	assert.Equal(t, "prune_stats", runs[1].Name)
+	assert.True(t, runs[1].LastRun.Equal(at.AddDate(0, 0, 1)))
+	assert.Equal(t, 2*time.Second, runs[1].Duration)
+}
+
+func TestDigestStore_SaveClaimDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	digestStore := &DigestStore{db: db}
+	ctx := context.Background()
+
+	due := time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC)
+	d := &models.Digest{
+		GuildID:   "guild1",
+		ChannelID: "channel1",
+		CreatedBy: "user1",
+		Period:    models.DigestDaily,
+		Langs:     []string{"en", "de"},
+		Hour:      9,
+		NextRun:   due,
+	}
+	require.NoError(t, digestStore.Save(ctx, d))
+	assert.NotZero(t, d.ID)
+
+	got, err := digestStore.Get(ctx, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "de"}, got.Langs)
+	assert.True(t, got.NextRun.Equal(due))
+
+	dueNow, err := digestStore.Due(ctx, due.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, dueNow)
+	dueNow, err = digestStore.Due(ctx, due)
+	require.NoError(t, err)
+	require.Len(t, dueNow, 1)
+
+	next := due.AddDate(0, 0, 1)
+	claimed, err := digestStore.Claim(ctx, d.ID, dueNow[0].NextRun, next)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = digestStore.Claim(ctx, d.ID, dueNow[0].NextRun, next)
+	require.NoError(t, err)
+	assert.False(t, claimed, "a run is claimed once")
+
+	require.NoError(t, digestStore.Delete(ctx, "channel1"))
+	assert.ErrorIs(t, digestStore.Delete(ctx, "channel1"), ErrNotFound)
+	_, err = digestStore.Get(ctx, "channel1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
 func TestLangStore_SetAndGetUserLang(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -139,3 +540,311 @@ func TestLangStore_SetAndGetUserLang(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, expectedLang, lang)
 }
+
+func TestLangStore_ChannelLang(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	langStore := &LangStore{db: db}
+	ctx := context.Background()
+
+	require.NoError(t, langStore.SetChannelLang(ctx, "guild1", "channel1", "fr"))
+
+	lang, err := langStore.GetChannelLang(ctx, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, "fr", lang)
+
+	require.NoError(t, langStore.DeleteChannelLang(ctx, "channel1"))
+	_, err = langStore.GetChannelLang(ctx, "channel1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = langStore.GetUserLang(ctx, "guild1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSettingsStore_SaveGetDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	settingsStore := &SettingsStore{db: db}
+	ctx := context.Background()
+
+	_, err := settingsStore.Get(ctx, "guild1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	gs := &models.GuildSettings{
+		GuildID:          "guild1",
+		Lang:             "de",
+		Prefix:           "?",
+		Timezone:         "Europe/Berlin",
+		LocalStatsDays:   true,
+		FeedChannels:     []string{"123", "456"},
+		DisabledCommands: []string{"stats"},
+		Aliases:          map[string]string{"rc": "recent en"},
+		RoleGrants:       map[string][]string{"watch": {"789"}},
+	}
+	require.NoError(t, settingsStore.Save(ctx, gs))
+
+	got, err := settingsStore.Get(ctx, "guild1")
+	require.NoError(t, err)
+	assert.Equal(t, gs, got)
+
+	require.NoError(t, settingsStore.Delete(ctx, "guild1"))
+	_, err = settingsStore.Get(ctx, "guild1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSettingsStore_SaveEmptyLists(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	settingsStore := &SettingsStore{db: db}
+	ctx := context.Background()
+
+	// Settings copies hold nil rather than empty lists.
+	gs := &models.GuildSettings{GuildID: "guild1", Lang: "en", Prefix: "!"}
+	require.NoError(t, settingsStore.Save(ctx, gs))
+	gs.FeedChannels, gs.DisabledCommands = []string{}, []string{}
+	gs.Prefix = "?"
+	require.NoError(t, settingsStore.Save(ctx, gs))
+
+	got, err := settingsStore.Get(ctx, "guild1")
+	require.NoError(t, err)
+	assert.Equal(t, "?", got.Prefix)
+	assert.Empty(t, got.FeedChannels)
+	assert.Empty(t, got.DisabledCommands)
+}
+
+func TestUserSettingsStore_SaveGetDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userSettingsStore := &UserSettingsStore{db: db}
+	ctx := context.Background()
+
+	_, err := userSettingsStore.Get(ctx, "user1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	us := &models.UserSettings{UserID: "user1", Timezone: "Asia/Almaty", LocalStatsDays: true}
+	require.NoError(t, userSettingsStore.Save(ctx, us))
+
+	got, err := userSettingsStore.Get(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, us, got)
+
+	us.LocalStatsDays = false
+	require.NoError(t, userSettingsStore.Save(ctx, us))
+	got, err = userSettingsStore.Get(ctx, "user1")
+	require.NoError(t, err)
+	assert.False(t, got.LocalStatsDays)
+
+	require.NoError(t, userSettingsStore.Delete(ctx, "user1"))
+	_, err = userSettingsStore.Get(ctx, "user1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAlertStore_SaveListDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	alertStore := &AlertStore{db: db}
+	ctx := context.Background()
+
+	a := &models.Alert{
+		GuildID:   "guild1",
+		ChannelID: "channel1",
+		CreatedBy: "user1",
+		Kind:      models.AlertKindSpike,
+		Lang:      "en",
+		Threshold: 3,
+	}
+	require.NoError(t, alertStore.Save(ctx, a))
+	assert.NotZero(t, a.ID)
+
+	again := *a
+	again.Threshold = 5
+	require.NoError(t, alertStore.Save(ctx, &again))
+	assert.Equal(t, a.ID, again.ID, "saving the same alert updates it")
+
+	alerts, err := alertStore.ListByChannel(ctx, "channel1")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 5.0, alerts[0].Threshold)
+
+	assert.ErrorIs(t, alertStore.Delete(ctx, "channel2", a.ID), ErrNotFound)
+	require.NoError(t, alertStore.Delete(ctx, "channel1", a.ID))
+	all, err := alertStore.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestWatchStore_AddListDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	watchStore := &WatchStore{db: db}
+	ctx := context.Background()
+
+	w := &models.Watch{
+		GuildID:   "guild1",
+		ChannelID: "channel1",
+		CreatedBy: "user1",
+		Kind:      models.WatchKindPage,
+		Lang:      "en",
+		Target:    "Main Page",
+	}
+	require.NoError(t, watchStore.Add(ctx, w))
+	assert.NotZero(t, w.ID)
+
+	dup := *w
+	assert.ErrorIs(t, watchStore.Add(ctx, &dup), ErrConflict)
+
+	watches, err := watchStore.ListByChannel(ctx, "channel1")
+	require.NoError(t, err)
+	require.Len(t, watches, 1)
+	assert.Equal(t, "Main Page", watches[0].Target)
+
+	all, err := watchStore.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	require.NoError(t, watchStore.Delete(ctx, "channel1", models.WatchKindPage, "en", "Main Page"))
+	assert.ErrorIs(t, watchStore.Delete(ctx, "channel1", models.WatchKindPage, "en", "Main Page"), ErrNotFound)
+}
+
+func TestEventStore_GetSince(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	eventStore := &EventStore{db}
+	ctx := context.Background()
+
+	for i, ts := range []int64{100, 200, 300} {
+		event := &models.RecentChangeEvent{
+			ID:         json.Number(fmt.Sprint(i + 1)),
+			Title:      fmt.Sprintf("Page %d", i+1),
+			User:       "TestUser",
+			Timestamp:  ts,
+			Wiki:       "enwiki",
+			ServerName: "en.wikipedia.org",
+		}
+		require.NoError(t, eventStore.Add(ctx, "en", event))
+	}
+
+	events, err := eventStore.GetSince(ctx, "en", 200, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "Page 2", events[0].Title)
+	assert.Equal(t, "Page 3", events[1].Title)
+}
+
+func TestEventStore_AddRejectsDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	eventStore := &EventStore{db}
+	ctx := context.Background()
+
+	event := &models.RecentChangeEvent{ID: "1", Title: "Page", User: "TestUser", Timestamp: 100, Wiki: "enwiki"}
+	require.NoError(t, eventStore.Add(ctx, "en", event))
+	assert.ErrorIs(t, eventStore.Add(ctx, "en", event), ErrConflict)
+
+	other := *event
+	other.Wiki = "dewiki"
+	require.NoError(t, eventStore.Add(ctx, "de", &other), "IDs are per wiki")
+}
+
+func TestEventStore_AddKeepsRecentEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	eventStore := &EventStore{db}
+	ctx := context.Background()
+
+	// Far more than the newest 100, all within the retention window but
+	// the first.
+	start := int64(1700000000)
+	add := func(id int, ts int64) {
+		require.NoError(t, eventStore.Add(ctx, "en", &models.RecentChangeEvent{
+			ID: json.Number(fmt.Sprint(id)), Title: "Page", User: "TestUser", Timestamp: ts,
+		}))
+	}
+	add(0, start-int64(EventRetention/time.Second)-1)
+	for i := 1; i <= 150; i++ {
+		add(i, start+int64(i))
+	}
+
+	events, err := eventStore.GetSince(ctx, "en", 0, 1000)
+	require.NoError(t, err)
+	require.Len(t, events, 150)
+	assert.Equal(t, "1", events[0].ID.String())
+}
+
+func TestFeedStore_SaveCursorDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	feedStore := &FeedStore{db: db}
+	ctx := context.Background()
+
+	f := &models.Feed{GuildID: "guild1", ChannelID: "channel1", CreatedBy: "user1", Lang: "en"}
+	require.NoError(t, feedStore.Save(ctx, f))
+	assert.NotZero(t, f.ID)
+
+	require.NoError(t, feedStore.UpdateCursor(ctx, "channel1", 1700000000, "42"))
+
+	// Restarting the feed with new filters keeps its cursor.
+	restarted := &models.Feed{GuildID: "guild1", ChannelID: "channel1", CreatedBy: "user2", Lang: "en", Filters: "minor=false"}
+	require.NoError(t, feedStore.Save(ctx, restarted))
+	assert.Equal(t, f.ID, restarted.ID)
+	assert.Equal(t, int64(1700000000), restarted.CursorTimestamp)
+
+	feeds, err := feedStore.ListAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, "minor=false", feeds[0].Filters)
+	assert.Equal(t, "42", feeds[0].CursorEventID)
+
+	// Switching it to another language starts it over from now.
+	switched := &models.Feed{GuildID: "guild1", ChannelID: "channel1", CreatedBy: "user2", Lang: "de"}
+	require.NoError(t, feedStore.Save(ctx, switched))
+	assert.Zero(t, switched.CursorTimestamp)
+	assert.Empty(t, switched.CursorEventID)
+	feeds, err = feedStore.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "de", feeds[0].Lang)
+	assert.Zero(t, feeds[0].CursorTimestamp)
+
+	require.NoError(t, feedStore.Delete(ctx, "channel1"))
+	assert.ErrorIs(t, feedStore.Delete(ctx, "channel1"), ErrNotFound)
+}
+
+func TestEventStore_GetPageAndCount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	eventStore := &EventStore{db}
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		event := &models.RecentChangeEvent{
+			ID:         json.Number(fmt.Sprint(i)),
+			Title:      fmt.Sprintf("Page %d", i),
+			User:       "TestUser",
+			Timestamp:  int64(100 * i),
+			Wiki:       "enwiki",
+			ServerName: "en.wikipedia.org",
+		}
+		require.NoError(t, eventStore.Add(ctx, "en", event))
+	}
+
+	count, err := eventStore.Count(ctx, "en")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	events, err := eventStore.GetPage(ctx, "en", 2, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "Page 3", events[0].Title)
+	assert.Equal(t, "Page 2", events[1].Title)
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type UserSettingsStore struct {
+	db *sql.DB
+}
+
+func (s *UserSettingsStore) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT user_id, timezone, local_stats_days FROM user_settings WHERE user_id = $1;`
+	var us models.UserSettings
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&us.UserID, &us.Timezone, &us.LocalStatsDays)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &us, nil
+}
+
+func (s *UserSettingsStore) Save(ctx context.Context, us *models.UserSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO user_settings (user_id, timezone, local_stats_days, updated_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (user_id) DO UPDATE
+	SET timezone = $2, local_stats_days = $3, updated_at = NOW();
+	`
+	_, err := s.db.ExecContext(ctx, query, us.UserID, us.Timezone, us.LocalStatsDays)
+	return err
+}
+
+func (s *UserSettingsStore) Delete(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_settings WHERE user_id = $1;`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+type WatchStore struct {
+	db *sql.DB
+}
+
+func (s *WatchStore) Add(ctx context.Context, w *models.Watch) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	INSERT INTO watches (guild_id, channel_id, created_by, kind, lang, target)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at;
+	`
+	err := s.db.QueryRowContext(ctx, query, w.GuildID, w.ChannelID, w.CreatedBy, w.Kind, w.Lang, w.Target).Scan(&w.ID, &w.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *WatchStore) Delete(ctx context.Context, channelID, kind, lang, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+	DELETE FROM watches
+	WHERE channel_id = $1 AND kind = $2 AND lang = $3 AND target = $4;
+	`
+	res, err := s.db.ExecContext(ctx, query, channelID, kind, lang, target)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *WatchStore) ListByChannel(ctx context.Context, channelID string) ([]*models.Watch, error) {
+	query := `
+	SELECT id, guild_id, channel_id, created_by, kind, lang, target, created_at
+	FROM watches WHERE channel_id = $1
+	ORDER BY kind, lang, target;
+	`
+	return s.list(ctx, query, channelID)
+}
+
+func (s *WatchStore) ListAll(ctx context.Context) ([]*models.Watch, error) {
+	query := `
+	SELECT id, guild_id, channel_id, created_by, kind, lang, target, created_at
+	FROM watches;
+	`
+	return s.list(ctx, query)
+}
+
+func (s *WatchStore) list(ctx context.Context, query string, args ...any) ([]*models.Watch, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watches []*models.Watch
+	for rows.Next() {
+		var w models.Watch
+		err := rows.Scan(&w.ID, &w.GuildID, &w.ChannelID, &w.CreatedBy, &w.Kind, &w.Lang, &w.Target, &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		watches = append(watches, &w)
+	}
+	return watches, rows.Err()
+}
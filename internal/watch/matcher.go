@@ -0,0 +1,133 @@
+// Package watch matches ingested events against channel watchlists and
+// notifies the watching channels.
+package watch
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+)
+
+// Matcher indexes watches by kind, language and target so each event is
+// matched with a constant number of map lookups, however many titles are
+// watched. It is safe for concurrent use.
+type Matcher struct {
+	mu    sync.RWMutex
+	index map[key][]*models.Watch
+}
+
+type key struct {
+	kind   string
+	lang   string
+	target string
+}
+
+func NewMatcher() *Matcher {
+	return &Matcher{index: make(map[key][]*models.Watch)}
+}
+
+// Load replaces the matcher's contents with watches.
+func (m *Matcher) Load(watches []*models.Watch) {
+	index := make(map[key][]*models.Watch, len(watches))
+	for _, w := range watches {
+		k := keyFor(w)
+		index[k] = append(index[k], w)
+	}
+
+	m.mu.Lock()
+	m.index = index
+	m.mu.Unlock()
+}
+
+func (m *Matcher) Add(w *models.Watch) {
+	k := keyFor(w)
+	m.mu.Lock()
+	m.index[k] = append(m.index[k], w)
+	m.mu.Unlock()
+}
+
+func (m *Matcher) Remove(channelID, kind, lang, target string) {
+	k := key{kind: kind, lang: lang, target: NormalizeTitle(target)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	watches := m.index[k]
+	for i, w := range watches {
+		if w.ChannelID == channelID {
+			// Copy so slices handed out by Match are never modified.
+			next := make([]*models.Watch, 0, len(watches)-1)
+			next = append(next, watches[:i]...)
+			next = append(next, watches[i+1:]...)
+			if len(next) == 0 {
+				delete(m.index, k)
+			} else {
+				m.index[k] = next
+			}
+			return
+		}
+	}
+}
+
+// Len returns the number of indexed watches.
+func (m *Matcher) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n := 0
+	for _, watches := range m.index {
+		n += len(watches)
+	}
+	return n
+}
+
+// Match returns the watches that event in lang triggers, at most one per
+// channel. Page watches only match edits on the wiki wikilink.Host gives
+// for lang; user watches match the editor on every wiki of the language.
+// Page watches are checked before user watches, so a channel that watches
+// both the page and its editor is notified about the page.
+func (m *Matcher) Match(lang string, event *models.RecentChangeEvent) []*models.Watch {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []*models.Watch
+	seen := make(map[string]bool)
+	add := func(watches []*models.Watch) {
+		for _, w := range watches {
+			if !seen[w.ChannelID] {
+				seen[w.ChannelID] = true
+				matched = append(matched, w)
+			}
+		}
+	}
+
+	// A page watch names a page on the language's Wikipedia, or on the
+	// project wiki for codes such as commons, so the same title on
+	// en.wiktionary does not trigger an en watch.
+	if event.ServerName == "" || event.ServerName == wikilink.Host(lang, "") {
+		add(m.index[key{kind: models.WatchKindPage, lang: lang, target: NormalizeTitle(event.Title)}])
+	}
+
+	user := NormalizeTitle(event.User)
+	add(m.index[key{kind: models.WatchKindUser, lang: lang, target: user}])
+	add(m.index[key{kind: models.WatchKindUser, lang: models.WatchAllLangs, target: user}])
+	return matched
+}
+
+func keyFor(w *models.Watch) key {
+	return key{kind: w.Kind, lang: w.Lang, target: NormalizeTitle(w.Target)}
+}
+
+// NormalizeTitle puts a page title or user name in the form MediaWiki
+// uses: underscores become spaces, runs of whitespace collapse, and the
+// first letter is upper-cased.
+func NormalizeTitle(title string) string {
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " ")
+	r, size := utf8.DecodeRuneInString(title)
+	if r == utf8.RuneError {
+		return title
+	}
+	return string(unicode.ToUpper(r)) + title[size:]
+}
@@ -0,0 +1,105 @@
+package watch
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/wikilink"
+	"go.uber.org/zap"
+)
+
+const queueSize = 1000
+
+// Sender queues messages for delivery, such as *outbound.Dispatcher. The
+// notifier does not wait for them, so one slow channel does not delay the
+// notifications of others.
+type Sender interface {
+	Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error
+}
+
+// LocaleFunc returns the localizer for messages posted to a channel of a
+// guild.
+type LocaleFunc func(ctx context.Context, guildID, channelID string) i18n.Localizer
+
+type notification struct {
+	watch *models.Watch
+	lang  string
+	event *models.RecentChangeEvent
+}
+
+// Notifier posts a message to every channel watching an ingested event.
+// Handle only matches and enqueues, so ingestion is never blocked by
+// Discord; Run does the sending.
+type Notifier struct {
+	sender  Sender
+	matcher *Matcher
+	logger  *zap.SugaredLogger
+	queue   chan notification
+	locale  LocaleFunc
+}
+
+func NewNotifier(sender Sender, matcher *Matcher, logger *zap.SugaredLogger) *Notifier {
+	return &Notifier{
+		sender:  sender,
+		matcher: matcher,
+		logger:  logger,
+		queue:   make(chan notification, queueSize),
+		locale:  func(context.Context, string, string) i18n.Localizer { return i18n.Localizer{} },
+	}
+}
+
+// SetLocales makes notifications use the language fn returns for their
+// channel instead of English. It must be called before Run.
+func (n *Notifier) SetLocales(fn LocaleFunc) {
+	n.locale = fn
+}
+
+// Handle is called for every ingested event.
+func (n *Notifier) Handle(lang string, event *models.RecentChangeEvent) {
+	for _, w := range n.matcher.Match(lang, event) {
+		select {
+		case n.queue <- notification{watch: w, lang: lang, event: event}:
+		default:
+			n.logger.Warnw("Watch notification queue full, dropping notification",
+				"channel", w.ChannelID, "title", event.Title)
+		}
+	}
+}
+
+// Run sends queued notifications until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-n.queue:
+			loc := n.locale(ctx, msg.watch.GuildID, msg.watch.ChannelID)
+			content := formatNotification(loc, msg)
+			if err := n.sender.Enqueue(msg.watch.ChannelID, &discordgo.MessageSend{Content: content}); err != nil {
+				n.logger.Errorw("Failed to queue watch notification",
+					"channel", msg.watch.ChannelID, "error", err)
+			}
+		}
+	}
+}
+
+func formatNotification(loc i18n.Localizer, msg notification) string {
+	e := msg.event
+	links := wikilink.For(msg.lang, e)
+
+	var text string
+	if msg.watch.Kind == models.WatchKindUser {
+		text = loc.T("watch.notify_user", e.User, e.Title, links.Page, msg.lang)
+	} else {
+		text = loc.T("watch.notify_page", e.Title, links.Page, msg.lang, e.User)
+	}
+	if inline := links.Inline(loc); inline != "" {
+		text += " · " + inline
+	}
+	if e.Comment != "" {
+		text += "\n" + loc.T("watch.notify_comment", e.Comment)
+	}
+	return text
+}
@@ -0,0 +1,182 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+	"go.uber.org/zap"
+)
+
+func pageWatch(channelID, lang, title string) *models.Watch {
+	return &models.Watch{ChannelID: channelID, Kind: models.WatchKindPage, Lang: lang, Target: title}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	assert.Equal(t, "Main Page", NormalizeTitle("main_Page"))
+	assert.Equal(t, "Main page", NormalizeTitle("main_page"), "only the first letter is case-insensitive")
+	assert.Equal(t, "Main Page", NormalizeTitle("  Main   Page "))
+	assert.Equal(t, "Élysée", NormalizeTitle("élysée"))
+	assert.Equal(t, "", NormalizeTitle(""))
+}
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher()
+	m.Load([]*models.Watch{
+		pageWatch("c1", "en", "Main Page"),
+		pageWatch("c2", "en", "main_Page"),
+		pageWatch("c1", "de", "Berlin"),
+	})
+	assert.Equal(t, 3, m.Len())
+
+	matched := m.Match("en", &models.RecentChangeEvent{Title: "Main Page"})
+	require.Len(t, matched, 2)
+
+	assert.Empty(t, m.Match("de", &models.RecentChangeEvent{Title: "Main Page"}))
+	assert.Len(t, m.Match("de", &models.RecentChangeEvent{Title: "Berlin"}), 1)
+
+	m.Remove("c2", models.WatchKindPage, "en", "Main_Page")
+	matched = m.Match("en", &models.RecentChangeEvent{Title: "Main Page"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, "c1", matched[0].ChannelID)
+
+	m.Add(pageWatch("c3", "en", "Main Page"))
+	assert.Len(t, m.Match("en", &models.RecentChangeEvent{Title: "Main Page"}), 2)
+}
+
+func TestMatcherPageWatchesStayOnTheirWiki(t *testing.T) {
+	m := NewMatcher()
+	m.Load([]*models.Watch{
+		pageWatch("c1", "en", "Main Page"),
+		pageWatch("c2", "commons", "File:Example.jpg"),
+		{ChannelID: "c3", Kind: models.WatchKindUser, Lang: "en", Target: "Editor"},
+	})
+
+	assert.Len(t, m.Match("en", &models.RecentChangeEvent{Title: "Main Page", ServerName: "en.wikipedia.org"}), 1)
+	assert.Empty(t, m.Match("en", &models.RecentChangeEvent{Title: "Main Page", ServerName: "en.wiktionary.org"}))
+	assert.Empty(t, m.Match("en", &models.RecentChangeEvent{Title: "Main Page", ServerName: "en.wikibooks.org"}))
+	assert.Len(t, m.Match("commons", &models.RecentChangeEvent{Title: "File:Example.jpg", ServerName: "commons.wikimedia.org"}), 1)
+
+	matched := m.Match("en", &models.RecentChangeEvent{Title: "Main Page", User: "Editor", ServerName: "en.wiktionary.org"})
+	require.Len(t, matched, 1, "user watches follow the editor across projects")
+	assert.Equal(t, "c3", matched[0].ChannelID)
+}
+
+func TestMatcherUserWatches(t *testing.T) {
+	m := NewMatcher()
+	m.Load([]*models.Watch{
+		{ChannelID: "c1", Kind: models.WatchKindUser, Lang: models.WatchAllLangs, Target: "Editor"},
+		{ChannelID: "c2", Kind: models.WatchKindUser, Lang: "de", Target: "Editor"},
+		pageWatch("c2", "de", "Berlin"),
+	})
+
+	matched := m.Match("fr", &models.RecentChangeEvent{Title: "Paris", User: "Editor"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, "c1", matched[0].ChannelID)
+
+	matched = m.Match("de", &models.RecentChangeEvent{Title: "Berlin", User: "editor"})
+	require.Len(t, matched, 2, "one notification per channel")
+	assert.Equal(t, models.WatchKindPage, matched[0].Kind, "page watches win within a channel")
+	assert.Equal(t, "c2", matched[0].ChannelID)
+	assert.Equal(t, "c1", matched[1].ChannelID)
+}
+
+func TestMatcherManyWatches(t *testing.T) {
+	m := NewMatcher()
+	var watches []*models.Watch
+	for i := 0; i < 10000; i++ {
+		watches = append(watches, pageWatch(fmt.Sprintf("c%d", i%50), "en", fmt.Sprintf("Page %d", i)))
+	}
+	m.Load(watches)
+
+	matched := m.Match("en", &models.RecentChangeEvent{Title: "Page 4242"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, "c42", matched[0].ChannelID)
+}
+
+type mockSender struct {
+	mu   sync.Mutex
+	sent map[string][]string
+}
+
+func (s *mockSender) Enqueue(channelID string, m *discordgo.MessageSend, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = make(map[string][]string)
+	}
+	s.sent[channelID] = append(s.sent[channelID], m.Content)
+	return nil
+}
+
+func (s *mockSender) messages(channelID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent[channelID]...)
+}
+
+func TestNotifier(t *testing.T) {
+	m := NewMatcher()
+	m.Load([]*models.Watch{pageWatch("c1", "en", "Main Page")})
+
+	sender := &mockSender{}
+	n := NewNotifier(sender, m, zap.NewNop().Sugar())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Handle("en", &models.RecentChangeEvent{Title: "Other Page", User: "Someone"})
+	n.Handle("en", &models.RecentChangeEvent{
+		Title:      "Main Page",
+		User:       "Editor",
+		Comment:    "typo",
+		ServerName: "en.wikipedia.org",
+		Revision:   models.Revision{Old: 1, New: 2},
+	})
+
+	require.Eventually(t, func() bool {
+		return len(sender.messages("c1")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	msg := sender.messages("c1")[0]
+	assert.Contains(t, msg, "[Main Page](<https://en.wikipedia.org/wiki/Main_Page>)")
+	assert.Contains(t, msg, "**Editor** · [diff](<https://en.wikipedia.org/w/index.php?diff=2&oldid=1>)")
+	assert.Contains(t, msg, "Comment: typo")
+}
+
+func TestNotifierUsesTheChannelLanguage(t *testing.T) {
+	m := NewMatcher()
+	w := pageWatch("c1", "ru", "Москва")
+	w.GuildID = "g1"
+	m.Load([]*models.Watch{w})
+
+	sender := &mockSender{}
+	n := NewNotifier(sender, m, zap.NewNop().Sugar())
+	n.SetLocales(func(ctx context.Context, guildID, channelID string) i18n.Localizer {
+		if guildID == "g1" && channelID == "c1" {
+			return i18n.Default().Localizer("ru")
+		}
+		return i18n.Localizer{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Handle("ru", &models.RecentChangeEvent{Title: "Москва", User: "Editor", Comment: "правка", Revision: models.Revision{Old: 1, New: 2}})
+	require.Eventually(t, func() bool {
+		return len(sender.messages("c1")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	msg := sender.messages("c1")[0]
+	assert.Contains(t, msg, "изменил(а) **Editor** · [разница]")
+	assert.Contains(t, msg, "\nОписание: правка")
+}
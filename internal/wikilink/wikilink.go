@@ -0,0 +1,127 @@
+// Package wikilink builds links to pages, changes and editors on the wiki an
+// event came from. Events carry their wiki's host name, so Wiktionary,
+// Commons and Wikidata edits link to the right site; the language code is
+// only used for events stored before host names were recorded.
+package wikilink
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+// scriptPath is where index.php lives on every Wikimedia wiki.
+const scriptPath = "/w/index.php"
+
+// maxInlineHistory bounds the history link Inline adds. Log events link
+// their history by title, which a long non-ASCII title makes many times
+// longer once percent-encoded; such a link is left out, as a cut one would
+// be broken.
+const maxInlineHistory = 200
+
+// NamespaceSpecial is the namespace of special pages, which have no
+// history.
+const NamespaceSpecial = -1
+
+// projectHosts are the wikis whose host name does not start with a
+// language code, keyed by that first label.
+var projectHosts = map[string]string{
+	"commons": "commons.wikimedia.org",
+	"meta":    "meta.wikimedia.org",
+	"species": "species.wikimedia.org",
+	"www":     "www.wikidata.org",
+}
+
+// Host returns serverName, or the host for lang when it is empty.
+func Host(lang, serverName string) string {
+	if serverName != "" {
+		return serverName
+	}
+	if host, ok := projectHosts[lang]; ok {
+		return host
+	}
+	return lang + ".wikipedia.org"
+}
+
+// Page links to a page on host. Slashes stay unescaped so subpages and
+// Special:Contributions/<user> read naturally.
+func Page(host, title string) string {
+	path := strings.ReplaceAll(url.PathEscape(strings.ReplaceAll(title, " ", "_")), "%2F", "/")
+	return fmt.Sprintf("https://%s/wiki/%s", host, path)
+}
+
+// Contributions links to the changes an editor made on host.
+func Contributions(host, user string) string {
+	return Page(host, "Special:Contributions/"+user)
+}
+
+func index(host string, params url.Values) string {
+	return fmt.Sprintf("https://%s%s?%s", host, scriptPath, params.Encode())
+}
+
+// Links are the links for one event. Empty links do not apply to it: log
+// events have no diff, edits have no log entry, and events stored before
+// revision IDs were recorded only have Page, History and Contributions.
+type Links struct {
+	Page          string
+	Diff          string
+	History       string
+	Contributions string
+	Log           string
+}
+
+// For returns the links for an event ingested for lang.
+func For(lang string, e *models.RecentChangeEvent) Links {
+	host := Host(lang, e.ServerName)
+	l := Links{
+		Page:          Page(host, e.Title),
+		Contributions: Contributions(host, e.User),
+	}
+
+	switch {
+	case e.Type == "log":
+		if e.LogID != 0 {
+			l.Log = index(host, url.Values{"title": {"Special:Log"}, "logid": {fmt.Sprint(e.LogID)}})
+		}
+	case e.Revision.New != 0 && e.Revision.Old != 0:
+		l.Diff = index(host, url.Values{"diff": {fmt.Sprint(e.Revision.New)}, "oldid": {fmt.Sprint(e.Revision.Old)}})
+	case e.Revision.New != 0:
+		// A page creation has nothing to compare against; link the
+		// revision itself.
+		l.Diff = index(host, url.Values{"oldid": {fmt.Sprint(e.Revision.New)}})
+	}
+
+	switch {
+	case e.Namespace == NamespaceSpecial:
+	case e.Revision.New != 0:
+		// MediaWiki finds the page from the revision, which keeps the link
+		// short and right even if the page has been moved since.
+		l.History = index(host, url.Values{"oldid": {fmt.Sprint(e.Revision.New)}, "action": {"history"}})
+	default:
+		l.History = index(host, url.Values{"title": {e.Title}, "action": {"history"}})
+	}
+	return l
+}
+
+// Inline renders the change and history links as Markdown for plain
+// messages, labelled in loc's language, with link previews suppressed. It
+// is empty when the event has neither a diff nor a log entry, and leaves
+// out a history link longer than maxInlineHistory.
+func (l Links) Inline(loc i18n.Localizer) string {
+	var parts []string
+	switch {
+	case l.Diff != "":
+		parts = append(parts, fmt.Sprintf("[%s](<%s>)", loc.T("embed.diff"), l.Diff))
+	case l.Log != "":
+		parts = append(parts, fmt.Sprintf("[%s](<%s>)", loc.T("embed.log"), l.Log))
+	default:
+		return ""
+	}
+	if l.History != "" && len(l.History) <= maxInlineHistory {
+		parts = append(parts, fmt.Sprintf("[%s](<%s>)", loc.T("embed.history"), l.History))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,68 @@
+package wikilink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vlkhvnn/TestON/internal/i18n"
+	"github.com/vlkhvnn/TestON/internal/models"
+)
+
+func TestForEdit(t *testing.T) {
+	l := For("en", &models.RecentChangeEvent{
+		Type:       "edit",
+		Title:      "free/form",
+		User:       "Some Editor",
+		ServerName: "en.wiktionary.org",
+		Revision:   models.Revision{Old: 100, New: 105},
+	})
+	assert.Equal(t, Links{
+		Page:          "https://en.wiktionary.org/wiki/free/form",
+		Diff:          "https://en.wiktionary.org/w/index.php?diff=105&oldid=100",
+		History:       "https://en.wiktionary.org/w/index.php?action=history&oldid=105",
+		Contributions: "https://en.wiktionary.org/wiki/Special:Contributions/Some_Editor",
+	}, l)
+}
+
+func TestForNewPage(t *testing.T) {
+	l := For("www", &models.RecentChangeEvent{
+		Type:       "new",
+		Title:      "Q42",
+		ServerName: "www.wikidata.org",
+		Revision:   models.Revision{New: 7},
+	})
+	assert.Equal(t, "https://www.wikidata.org/wiki/Q42", l.Page)
+	assert.Equal(t, "https://www.wikidata.org/w/index.php?oldid=7", l.Diff)
+}
+
+func TestForLogEvent(t *testing.T) {
+	l := For("commons", &models.RecentChangeEvent{
+		Type:      "log",
+		Title:     "File:Cat.jpg",
+		Namespace: 6,
+		LogID:     9001,
+	})
+	assert.Equal(t, "https://commons.wikimedia.org/wiki/File:Cat.jpg", l.Page)
+	assert.Empty(t, l.Diff)
+	assert.Equal(t, "https://commons.wikimedia.org/w/index.php?logid=9001&title=Special%3ALog", l.Log)
+	assert.Equal(t, "https://commons.wikimedia.org/w/index.php?action=history&title=File%3ACat.jpg", l.History)
+	assert.Equal(t, "[log entry](<"+l.Log+">) · [history](<"+l.History+">)", l.Inline(i18n.Localizer{}))
+	ru := i18n.Default().Localizer("ru")
+	assert.Equal(t, "[запись журнала](<"+l.Log+">) · [история](<"+l.History+">)", l.Inline(ru))
+
+	long := For("ru", &models.RecentChangeEvent{Type: "log", Title: strings.Repeat("ж", 255), LogID: 1})
+	assert.Equal(t, "[log entry](<"+long.Log+">)", long.Inline(i18n.Localizer{}), "a long history link is left out")
+
+	special := For("en", &models.RecentChangeEvent{Type: "log", Title: "Special:Log/newusers", Namespace: NamespaceSpecial})
+	assert.Empty(t, special.History)
+	assert.Empty(t, special.Inline(i18n.Localizer{}))
+}
+
+func TestForStoredEventWithoutMetadata(t *testing.T) {
+	l := For("de", &models.RecentChangeEvent{Title: "Main Page", User: "U"})
+	assert.Equal(t, "https://de.wikipedia.org/wiki/Main_Page", l.Page)
+	assert.Empty(t, l.Diff)
+	assert.Equal(t, "https://de.wikipedia.org/w/index.php?action=history&title=Main+Page", l.History)
+	assert.Empty(t, l.Inline(i18n.Localizer{}))
+}
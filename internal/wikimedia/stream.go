@@ -3,11 +3,13 @@ package wikimedia
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/r3labs/sse/v2"
 	"github.com/vlkhvnn/TestON/internal/models"
+	"github.com/vlkhvnn/TestON/internal/score"
 	"github.com/vlkhvnn/TestON/internal/store"
 	"go.uber.org/zap"
 )
@@ -16,7 +18,17 @@ var (
 	wikiURL = "https://stream.wikimedia.org/v2/stream/recentchange"
 )
 
-func StartStream(ctx context.Context, eventStore *store.Storage, logger *zap.SugaredLogger) error {
+// EventHandler is called for every event that was stored successfully, by
+// this process or by another one ingesting the same stream. Handlers run on
+// the stream goroutine and must not block.
+type EventHandler func(lang string, event *models.RecentChangeEvent)
+
+// StartStream ingests the recent changes stream until ctx is cancelled.
+// Every event other than bot edits is given a score by scorer, if it is not
+// nil, then stored and passed to handlers. Only the process that stores an
+// event counts it in the stats, so several processes can ingest the stream
+// side by side.
+func StartStream(ctx context.Context, eventStore *store.Storage, scorer score.Scorer, logger *zap.SugaredLogger, handlers ...EventHandler) error {
 	client := sse.NewClient(wikiURL)
 	errCh := make(chan error, 1)
 
@@ -39,14 +51,28 @@ func StartStream(ctx context.Context, eventStore *store.Storage, logger *zap.Sug
 			}
 			lang := parts[0]
 
+			if scorer != nil {
+				event.Score = scorer.Score(lang, &event)
+			}
+
 			storageCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 
-			if err := eventStore.Event.Add(storageCtx, lang, &event); err != nil {
+			err := eventStore.Event.Add(storageCtx, lang, &event)
+			if err != nil && !errors.Is(err, store.ErrConflict) {
 				logger.Errorw("Error storing event", "error", err)
 				return
 			}
 
+			for _, handle := range handlers {
+				handle(lang, &event)
+			}
+
+			if err != nil {
+				// Another process stored the event and counts it.
+				return
+			}
+
 			t := time.Unix(event.Timestamp, 0).UTC()
 			dateStr := t.Format("2006-01-02")
 
@@ -54,6 +80,25 @@ func StartStream(ctx context.Context, eventStore *store.Storage, logger *zap.Sug
 				logger.Errorw("Error updating stats", "error", err)
 				return
 			}
+
+			if err := eventStore.Stat.IncrementHour(storageCtx, lang, t.Truncate(time.Hour)); err != nil {
+				logger.Errorw("Error updating hourly stats", "error", err)
+			}
+
+			if event.Type == "edit" || event.Type == "new" {
+				if err := eventStore.Stat.IncrementArticle(storageCtx, lang, dateStr, event.Title); err != nil {
+					logger.Errorw("Error updating article stats", "error", err)
+				}
+				if err := eventStore.Stat.IncrementEditor(storageCtx, lang, dateStr, event.User); err != nil {
+					logger.Errorw("Error updating editor stats", "error", err)
+				}
+			}
+
+			if event.Type == "new" {
+				if err := eventStore.Stat.AddNewPage(storageCtx, lang, dateStr, event.Title, event.User); err != nil {
+					logger.Errorw("Error recording new page", "error", err)
+				}
+			}
 		})
 		if err != nil {
 			errCh <- err